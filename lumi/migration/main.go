@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"
+
 	"github.com/Mahaveer86619/lumi/pkg/config"
 	"github.com/Mahaveer86619/lumi/pkg/db"
 	"github.com/Mahaveer86619/lumi/pkg/models"
 	"github.com/labstack/gommon/log"
+	"gorm.io/gorm"
 )
 
 var version string = "dev"
@@ -18,8 +21,21 @@ func main() {
 	tables := []interface{}{
 		&models.UserProfile{},
 		&models.WhatsAppSession{},
+		&models.SessionStatusChange{},
 		&models.RegisteredChat{},
 		&models.ChatMessage{},
+		&models.MediaFile{},
+		&models.OutboxMessage{},
+		&models.Contact{},
+		&models.Poll{},
+		&models.PollVote{},
+		&models.PendingPollVote{},
+		&models.ProcessedWebhook{},
+		&models.ChannelPost{},
+		&models.StatusPost{},
+		&models.StatusView{},
+		&models.Campaign{},
+		&models.CampaignRecipient{},
 	}
 
 	log.Info("Running AutoMigrate...")
@@ -27,5 +43,81 @@ func main() {
 		log.Fatal("Migration failed:", err)
 	}
 
+	if err := adoptBaselineSession(); err != nil {
+		log.Fatal("Migration failed:", err)
+	}
+	if err := scopeChatsBySession(); err != nil {
+		log.Fatal("Migration failed:", err)
+	}
+
 	log.Info("DB Migration completed successfully!")
 }
+
+// adoptBaselineSession maps WAHA_SESSION_NAME, the one session every user
+// shared before sessions were per user, to the oldest user. Without the row
+// that user would get a new, unpaired session and the paired one would be
+// abandoned.
+func adoptBaselineSession() error {
+	name := config.GConfig.WahaSessionName
+	if name == "" {
+		return nil
+	}
+
+	var adopted int64
+	if err := db.DB.Model(&models.WhatsAppSession{}).Where("waha_session_name = ?", name).Count(&adopted).Error; err != nil {
+		return err
+	}
+	if adopted > 0 {
+		return nil
+	}
+
+	var owner models.UserProfile
+	err := db.DB.Order("id asc").First(&owner).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var existing models.WhatsAppSession
+	err = db.DB.Where("user_id = ?", owner.ID).First(&existing).Error
+	if err == nil {
+		log.Warnf("User %s already has WAHA session %s; %s is left unassigned", owner.Username, existing.WahaSessionName, name)
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	log.Infof("Assigning WAHA session %s to user %s", name, owner.Username)
+	return db.DB.Create(&models.WhatsAppSession{UserID: owner.ID, WahaSessionName: name}).Error
+}
+
+// scopeChatsBySession drops the index that made a chat id unique across all
+// users, now that registered chats and their history belong to a session.
+// Rows from before are assigned to the only session, if there is just one;
+// otherwise they stay unassigned and have to be registered again.
+func scopeChatsBySession() error {
+	migrator := db.DB.Migrator()
+	if migrator.HasIndex(&models.RegisteredChat{}, "idx_registered_chats_chat_id") {
+		if err := migrator.DropIndex(&models.RegisteredChat{}, "idx_registered_chats_chat_id"); err != nil {
+			return err
+		}
+	}
+
+	var sessions []string
+	if err := db.DB.Model(&models.WhatsAppSession{}).Pluck("waha_session_name", &sessions).Error; err != nil {
+		return err
+	}
+	if len(sessions) != 1 {
+		return nil
+	}
+
+	for _, table := range []interface{}{&models.RegisteredChat{}, &models.ChatMessage{}} {
+		if err := db.DB.Model(table).Where("session_name = ''").Update("session_name", sessions[0]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/db/dbtest"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+)
+
+func TestAdoptBaselineSession(t *testing.T) {
+	tests := []struct {
+		name     string
+		users    []string
+		sessions []models.WhatsAppSession
+		want     map[uint]string // session name per user id after migrating
+	}{
+		{"no users", nil, nil, map[uint]string{}},
+		{"single user", []string{"alice"}, nil, map[uint]string{1: "default"}},
+		{"oldest user", []string{"alice", "bob"}, nil, map[uint]string{1: "default"}},
+		{"already adopted", []string{"alice", "bob"}, []models.WhatsAppSession{{UserID: 2, WahaSessionName: "default"}}, map[uint]string{2: "default"}},
+		{"owner has a session of their own", []string{"alice"}, []models.WhatsAppSession{{UserID: 1, WahaSessionName: "default_abc"}}, map[uint]string{1: "default_abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbtest.Open(t, &models.UserProfile{}, &models.WhatsAppSession{}, &models.RegisteredChat{}, &models.ChatMessage{})
+			previous := config.GConfig
+			config.GConfig = &config.Config{WahaSessionName: "default"}
+			t.Cleanup(func() { config.GConfig = previous })
+
+			for _, username := range tt.users {
+				user := models.UserProfile{Username: username, Password: "x", Email: username + "@example.com"}
+				if err := db.DB.Create(&user).Error; err != nil {
+					t.Fatalf("creating user: %v", err)
+				}
+			}
+			for _, session := range tt.sessions {
+				if err := db.DB.Create(&session).Error; err != nil {
+					t.Fatalf("creating session: %v", err)
+				}
+			}
+
+			if err := adoptBaselineSession(); err != nil {
+				t.Fatalf("adoptBaselineSession: %v", err)
+			}
+
+			var sessions []models.WhatsAppSession
+			db.DB.Find(&sessions)
+			got := make(map[uint]string)
+			for _, session := range sessions {
+				got[session.UserID] = session.WahaSessionName
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("sessions %v, want %v", got, tt.want)
+			}
+			for userID, name := range tt.want {
+				if got[userID] != name {
+					t.Errorf("user %d has session %q, want %q", userID, got[userID], name)
+				}
+			}
+		})
+	}
+}
+
+func TestBaselineChatsStayWithTheirSession(t *testing.T) {
+	dbtest.Open(t, &models.UserProfile{}, &models.WhatsAppSession{}, &models.RegisteredChat{}, &models.ChatMessage{})
+	previous := config.GConfig
+	config.GConfig = &config.Config{WahaSessionName: "default"}
+	t.Cleanup(func() { config.GConfig = previous })
+
+	alice := models.UserProfile{Username: "alice", Password: "x", Email: "alice@example.com"}
+	db.DB.Create(&alice)
+	db.DB.Create(&models.RegisteredChat{ChatID: "123@c.us", Name: "Friend", Type: "chat"})
+
+	if err := adoptBaselineSession(); err != nil {
+		t.Fatalf("adoptBaselineSession: %v", err)
+	}
+	if err := scopeChatsBySession(); err != nil {
+		t.Fatalf("scopeChatsBySession: %v", err)
+	}
+
+	// The user keeps the paired session, and with it the chats.
+	session, err := services.NewSessionService().GetOrCreateUserSession(alice.ID)
+	if err != nil || session.WahaSessionName != "default" {
+		t.Fatalf("GetOrCreateUserSession = %+v, %v; want the baseline session", session, err)
+	}
+	if !services.NewChatService(nil).IsChatAllowed("default", "123@c.us") {
+		t.Errorf("the baseline chat is not registered on the adopted session")
+	}
+}
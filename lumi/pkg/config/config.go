@@ -24,14 +24,30 @@ type Config struct {
 	GeminiAPIKey string
 
 	// ms
-	WahaServiceURL string
-	WahaAPIKey     string
+	WahaServiceURL    string
+	WahaAPIKey        string
+	WahaWebhookSecret string
+	WahaEventMode     string // EventModeWebhook or EventModeWebsocket
 
-	// default session
+	// session name prefix, suffixed with the masked user id
 	WahaSessionName     string
 	WahaBotSystemPrompt string
+
+	// media
+	MediaStorageDir string
+
+	// telegram, disabled while the token is empty
+	TelegramBotToken string
+	TelegramAPIURL   string
 }
 
+// How Lumi receives WAHA events: WAHA posting to /api/v1/webhook, or Lumi
+// reading them from WAHA's /ws socket when WAHA can't reach it.
+const (
+	EventModeWebhook   = "webhook"
+	EventModeWebsocket = "websocket"
+)
+
 var GConfig *Config
 
 func InitConfig() {
@@ -57,12 +73,21 @@ func InitConfig() {
 		GeminiAPIKey: getEnv("GEMINI_API_KEY"),
 
 		// ms
-		WahaServiceURL: getEnv("WAHA_SERVICE_URL"),
-		WahaAPIKey:     getEnv("WAHA_API_KEY"),
+		WahaServiceURL:    getEnv("WAHA_SERVICE_URL"),
+		WahaAPIKey:        getEnv("WAHA_API_KEY"),
+		WahaWebhookSecret: getEnv("WAHA_WEBHOOK_SECRET", ""),
+		WahaEventMode:     getEnv("WAHA_EVENT_MODE", EventModeWebhook),
 
 		// session
 		WahaSessionName: getEnv("WAHA_SESSION_NAME"),
 		WahaBotSystemPrompt: makeSystemPromptForBot(),
+
+		// media
+		MediaStorageDir: getEnv("MEDIA_STORAGE_DIR", "./data/media"),
+
+		// telegram
+		TelegramBotToken: getEnv("TELEGRAM_BOT_TOKEN", ""),
+		TelegramAPIURL:   getEnv("TELEGRAM_API_URL", "https://api.telegram.org"),
 	}
 }
 
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+type CampaignHandler struct {
+	campaignService *services.CampaignService
+	sessionService  *services.SessionService
+}
+
+func NewCampaignHandler(group *echo.Group, campaignService *services.CampaignService, sessionService *services.SessionService) *CampaignHandler {
+	handler := &CampaignHandler{
+		campaignService: campaignService,
+		sessionService:  sessionService,
+	}
+
+	group.POST("", handler.CreateCampaign)
+	group.GET("", handler.ListCampaigns)
+	group.GET("/:id", handler.GetCampaign)
+	group.GET("/:id/recipients", handler.ListRecipients)
+
+	group.POST("/:id/pause", handler.PauseCampaign)
+	group.POST("/:id/resume", handler.ResumeCampaign)
+	group.POST("/:id/cancel", handler.CancelCampaign)
+
+	return handler
+}
+
+// CreateCampaign starts sending a message template to the targeted chats,
+// throttled by the campaign's delays and daily cap.
+func (h *CampaignHandler) CreateCampaign(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	var req views.CreateCampaignRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Invalid payload"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	target := services.CampaignTarget{ChatIDs: req.Target.ChatIDs, Tags: req.Target.Tags, Type: req.Target.Type}
+	campaign, err := h.campaignService.Create(session.WahaSessionName, req.Name, req.Template, target, req.MinDelaySeconds, req.MaxDelaySeconds, req.DailyCap)
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, views.Success{StatusCode: http.StatusCreated, Message: "Campaign started", Data: views.NewCampaignResponse(*campaign)})
+}
+
+// ListCampaigns lists the user's campaigns, filtered by ?status=.
+func (h *CampaignHandler) ListCampaigns(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	campaigns, err := h.campaignService.List(session.WahaSessionName, c.QueryParam("status"))
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Campaigns fetched", Data: views.NewCampaignListResponse(campaigns)})
+}
+
+func (h *CampaignHandler) GetCampaign(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	campaign, err := h.campaignService.Get(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Campaign fetched", Data: views.NewCampaignResponse(*campaign)})
+}
+
+// ListRecipients lists who the campaign sends to and how delivery went,
+// filtered by ?status=.
+func (h *CampaignHandler) ListRecipients(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	recipients, err := h.campaignService.Recipients(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask(), c.QueryParam("status"))
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Campaign recipients fetched", Data: views.NewCampaignRecipientListResponse(recipients)})
+}
+
+func (h *CampaignHandler) PauseCampaign(c echo.Context) error {
+	return h.changeState(c, h.campaignService.Pause, "Campaign paused")
+}
+
+func (h *CampaignHandler) ResumeCampaign(c echo.Context) error {
+	return h.changeState(c, h.campaignService.Resume, "Campaign resumed")
+}
+
+func (h *CampaignHandler) CancelCampaign(c echo.Context) error {
+	return h.changeState(c, h.campaignService.Cancel, "Campaign cancelled")
+}
+
+func (h *CampaignHandler) changeState(c echo.Context, change func(sessionName string, id uint) (*models.CampaignProgress, error), message string) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	campaign, err := change(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if err != nil {
+		return campaignError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: message, Data: views.NewCampaignResponse(*campaign)})
+}
+
+func campaignError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidCampaign), errors.Is(err, services.ErrCampaignNoRecipients):
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	case errors.Is(err, services.ErrCampaignNotFound):
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: http.StatusNotFound, Message: err.Error()})
+	case errors.Is(err, services.ErrCampaignInvalidChange):
+		return c.JSON(http.StatusConflict, views.Failure{StatusCode: http.StatusConflict, Message: err.Error()})
+	}
+	return respondError(c, err, "")
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+)
+
+func TestCampaigns(t *testing.T) {
+	h := newHandlerTest(t, &models.Campaign{}, &models.CampaignRecipient{}, &models.Contact{})
+	NewChatHandler(h.group("/chats"), h.chatService, services.NewHistoryService(h.sessionService, h.chatService), h.sessionService, nil)
+	NewCampaignHandler(h.group("/campaigns"), services.NewCampaignService(h.chatService, h.outboxService), h.sessionService)
+	const alice, bob = 1, 2
+
+	if status := h.do(t, alice, http.MethodPost, "/chats/register", `{"chat_id":"15552223333@c.us","name":"Sam Doe","type":"chat"}`, nil); status != http.StatusOK {
+		t.Fatalf("register: status %d", status)
+	}
+	if status := h.do(t, alice, http.MethodPut, "/chats/register/15552223333@c.us/tags", `{"tags":["Friends"]}`, nil); status != http.StatusOK {
+		t.Fatalf("tags: status %d", status)
+	}
+
+	var campaign views.Campaign
+	if status := h.do(t, alice, http.MethodPost, "/campaigns", `{"name":"Hello","template":"Hi {{.FirstName}}","target":{"tags":["friends"]}}`, &campaign); status != http.StatusCreated {
+		t.Fatalf("create: status %d", status)
+	}
+	if campaign.Recipients != 1 || campaign.Status != models.CampaignRunning {
+		t.Errorf("created %+v, want a running campaign to one chat", campaign)
+	}
+	path := "/campaigns/" + campaign.ID.String()
+
+	tests := []struct {
+		name, method, path, body string
+		user                     uint
+		status                   int
+	}{
+		{"no template", http.MethodPost, "/campaigns", `{"name":"Empty","target":{"tags":["friends"]}}`, alice, http.StatusBadRequest},
+		{"nobody targeted", http.MethodPost, "/campaigns", `{"name":"Hello","template":"Hi","target":{"tags":["work"]}}`, alice, http.StatusBadRequest},
+		{"another user's tags", http.MethodPost, "/campaigns", `{"name":"Hello","template":"Hi","target":{"tags":["friends"]}}`, bob, http.StatusBadRequest},
+		{"an unregistered chat", http.MethodPost, "/campaigns", `{"name":"Hello","template":"Hi","target":{"chat_ids":["15559990000@c.us"]}}`, alice, http.StatusBadRequest},
+		{"another user's chat", http.MethodPost, "/campaigns", `{"name":"Hello","template":"Hi","target":{"chat_ids":["15552223333@c.us"]}}`, bob, http.StatusBadRequest},
+		{"get", http.MethodGet, path, "", alice, http.StatusOK},
+		{"get as another user", http.MethodGet, path, "", bob, http.StatusNotFound},
+		{"recipients", http.MethodGet, path + "/recipients", "", alice, http.StatusOK},
+		{"resume a running campaign", http.MethodPost, path + "/resume", "", alice, http.StatusConflict},
+		{"pause", http.MethodPost, path + "/pause", "", alice, http.StatusOK},
+		{"cancel", http.MethodPost, path + "/cancel", "", alice, http.StatusOK},
+		{"pause a cancelled campaign", http.MethodPost, path + "/pause", "", alice, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := h.do(t, tt.user, tt.method, tt.path, tt.body, nil); status != tt.status {
+				t.Errorf("status %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/services/bot"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+type ChannelHandler struct {
+	channelService *services.ChannelService
+	sessionService *services.SessionService
+	botService     *bot.BotService
+}
+
+func NewChannelHandler(group *echo.Group, channelService *services.ChannelService, sessionService *services.SessionService, botService *bot.BotService) *ChannelHandler {
+	handler := &ChannelHandler{
+		channelService: channelService,
+		sessionService: sessionService,
+		botService:     botService,
+	}
+
+	group.GET("", handler.ListChannels)
+	group.POST("", handler.CreateChannel)
+
+	group.POST("/:channelId/posts", handler.PublishPost)
+	group.POST("/:channelId/drafts", handler.DraftPost)
+
+	// Posts of all channels, drafts waiting for approval among them
+	group.GET("/posts", handler.ListPosts)
+	group.PATCH("/posts/:id", handler.UpdateDraft)
+	group.POST("/posts/:id/approve", handler.ApproveDraft)
+	group.DELETE("/posts/:id", handler.DiscardDraft)
+
+	return handler
+}
+
+// ListChannels lists the channels the user owns or follows; ?role= narrows
+// it to "owner", "admin", "subscriber" or "guest".
+func (h *ChannelHandler) ListChannels(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	role := strings.ToUpper(c.QueryParam("role"))
+	switch role {
+	case "", connModel.ChannelRoleOwner, connModel.ChannelRoleAdmin, connModel.ChannelRoleSubscriber, connModel.ChannelRoleGuest:
+	default:
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Unknown role " + c.QueryParam("role")})
+	}
+
+	channels, err := h.channelService.ListChannels(c.Request().Context(), userID, role)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Channels fetched", Data: views.NewChannelListResponse(channels)})
+}
+
+func (h *ChannelHandler) CreateChannel(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	var req views.CreateChannelRequest
+	if err := c.Bind(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Channel name is required"})
+	}
+
+	channel, err := h.channelService.CreateChannel(c.Request().Context(), userID, strings.TrimSpace(req.Name), req.Description, req.Picture)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusCreated, views.Success{StatusCode: http.StatusCreated, Message: "Channel created", Data: views.NewChannelResponse(*channel)})
+}
+
+// PublishPost queues a post written by hand for the channel.
+func (h *ChannelHandler) PublishPost(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	var req views.PublishChannelPostRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Invalid payload"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	post, item, err := h.channelService.Publish(c.Request().Context(), session.WahaSessionName, c.Param("channelId"), req.Text, req.Image)
+	if err != nil {
+		return channelError(c, err)
+	}
+
+	return c.JSON(http.StatusAccepted, views.Success{StatusCode: http.StatusAccepted, Message: "Post queued", Data: views.PublishChannelPostResponse{
+		Post:   views.NewChannelPostResponse(*post),
+		Outbox: views.NewOutboxMessageResponse(*item),
+	}})
+}
+
+// DraftPost has Lumi write a post from a prompt and keeps it as a draft
+// until someone approves it.
+func (h *ChannelHandler) DraftPost(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	var req views.DraftChannelPostRequest
+	if err := c.Bind(&req); err != nil || strings.TrimSpace(req.Prompt) == "" {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "A prompt is required"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	ctx := c.Request().Context()
+	channel, err := h.channelService.WritableChannel(ctx, session.WahaSessionName, c.Param("channelId"))
+	if err != nil {
+		return channelError(c, err)
+	}
+
+	text, err := h.botService.DraftChannelPost(ctx, *channel, req.Prompt)
+	if err != nil {
+		c.Logger().Errorf("channel draft: %v", err)
+		return c.JSON(http.StatusBadGateway, views.Failure{StatusCode: http.StatusBadGateway, Message: "Lumi could not write a draft"})
+	}
+
+	post, err := h.channelService.CreateDraft(session.WahaSessionName, channel.ID, req.Prompt, text, req.Image)
+	if err != nil {
+		return channelError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, views.Success{StatusCode: http.StatusCreated, Message: "Draft created", Data: views.NewChannelPostResponse(*post)})
+}
+
+// ListPosts lists channel posts, filtered by ?channel_id= and ?status=.
+func (h *ChannelHandler) ListPosts(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	posts, err := h.channelService.ListPosts(session.WahaSessionName, c.QueryParam("channel_id"), c.QueryParam("status"))
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Channel posts fetched", Data: views.NewChannelPostListResponse(posts)})
+}
+
+func (h *ChannelHandler) UpdateDraft(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	var req views.UpdateChannelDraftRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Invalid payload"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	post, err := h.channelService.UpdateDraft(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask(), req.Text)
+	if err != nil {
+		return channelError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Draft updated", Data: views.NewChannelPostResponse(*post)})
+}
+
+func (h *ChannelHandler) ApproveDraft(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	post, item, err := h.channelService.ApproveDraft(c.Request().Context(), session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if err != nil {
+		return channelError(c, err)
+	}
+
+	return c.JSON(http.StatusAccepted, views.Success{StatusCode: http.StatusAccepted, Message: "Draft approved and queued", Data: views.PublishChannelPostResponse{
+		Post:   views.NewChannelPostResponse(*post),
+		Outbox: views.NewOutboxMessageResponse(*item),
+	}})
+}
+
+func (h *ChannelHandler) DiscardDraft(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	post, err := h.channelService.DiscardDraft(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if err != nil {
+		return channelError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Draft discarded", Data: views.NewChannelPostResponse(*post)})
+}
+
+func channelError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrEmptyChannelPost):
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	case errors.Is(err, services.ErrChannelNotWritable):
+		return c.JSON(http.StatusForbidden, views.Failure{StatusCode: http.StatusForbidden, Message: err.Error()})
+	case errors.Is(err, services.ErrChannelPostNotFound):
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: http.StatusNotFound, Message: err.Error()})
+	case errors.Is(err, services.ErrChannelPostNotDraft):
+		return c.JSON(http.StatusConflict, views.Failure{StatusCode: http.StatusConflict, Message: err.Error()})
+	}
+	return respondError(c, err, "")
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+)
+
+func TestChannels(t *testing.T) {
+	h := newHandlerTest(t, &models.ChannelPost{})
+	NewChannelHandler(h.group("/channels"), services.NewChannelService(h.sessionService, h.outboxService), h.sessionService, nil)
+	const alice = 1
+
+	h.pair(t, alice)
+
+	var created views.Channel
+	if status := h.do(t, alice, http.MethodPost, "/channels", `{"name":"News","description":"Daily news"}`, &created); status != http.StatusCreated {
+		t.Fatalf("create: status %d", status)
+	}
+	if created.Role != "owner" || !created.CanPost {
+		t.Errorf("created %+v, want a channel alice owns", created)
+	}
+
+	tests := []struct {
+		name, method, path, body string
+		status                   int
+	}{
+		{"list", http.MethodGet, "/channels", "", http.StatusOK},
+		{"list owned", http.MethodGet, "/channels?role=owner", "", http.StatusOK},
+		{"unknown role", http.MethodGet, "/channels?role=king", "", http.StatusBadRequest},
+		{"nameless channel", http.MethodPost, "/channels", `{"name":" "}`, http.StatusBadRequest},
+		{"publish", http.MethodPost, "/channels/" + created.ID + "/posts", `{"text":"Good morning"}`, http.StatusAccepted},
+		{"publish nothing", http.MethodPost, "/channels/" + created.ID + "/posts", `{"text":""}`, http.StatusBadRequest},
+		{"approve an unknown draft", http.MethodPost, "/channels/posts/unknown/approve", "", http.StatusNotFound},
+		{"discard an unknown draft", http.MethodDelete, "/channels/posts/unknown", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := h.do(t, alice, tt.method, tt.path, tt.body, nil); status != tt.status {
+				t.Errorf("status %d, want %d", status, tt.status)
+			}
+		})
+	}
+
+	var posts []views.ChannelPost
+	if status := h.do(t, alice, http.MethodGet, "/channels/posts?status=published", "", &posts); status != http.StatusOK || len(posts) != 1 || posts[0].Text != "Good morning" {
+		t.Errorf("published posts: status %d, %+v; want the morning post", status, posts)
+	}
+}
@@ -1,20 +1,34 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/Mahaveer86619/lumi/pkg/models"
 	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
 	"github.com/Mahaveer86619/lumi/pkg/views"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type ChatHandler struct {
-	chatService *services.ChatService
+	chatService    *services.ChatService
+	historyService *services.HistoryService
+	sessionService *services.SessionService
+	telegram       messenger.Messenger // nil unless a Telegram bot is configured
 }
 
-func NewChatHandler(group *echo.Group, chatService *services.ChatService) *ChatHandler {
-	handler := &ChatHandler{chatService: chatService}
+func NewChatHandler(group *echo.Group, chatService *services.ChatService, historyService *services.HistoryService, sessionService *services.SessionService, telegram messenger.Messenger) *ChatHandler {
+	handler := &ChatHandler{
+		chatService:    chatService,
+		historyService: historyService,
+		sessionService: sessionService,
+		telegram:       telegram,
+	}
 
 	// Remote (from WAHA)
 	group.GET("/remote/chats", handler.GetRemoteChats)
@@ -24,14 +38,25 @@ func NewChatHandler(group *echo.Group, chatService *services.ChatService) *ChatH
 	group.GET("/registered", handler.GetRegisteredChats)
 	group.POST("/register", handler.RegisterChat)
 	group.DELETE("/register/:chatId", handler.UnregisterChat)
+	group.PATCH("/register/:chatId/settings", handler.UpdateChatSettings)
+	group.PUT("/register/:chatId/tags", handler.SetChatTags)
+
+	// History
+	group.GET("/register/:chatId/messages", handler.GetChatMessages)
+	group.POST("/register/:chatId/sync", handler.SyncChatHistory)
 
 	return handler
 }
 
 func (h *ChatHandler) GetRemoteChats(c echo.Context) error {
-	rawChats, err := h.chatService.GetRemoteChats()
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: 401, Message: "Unauthorized"})
+	}
+
+	rawChats, err := h.chatService.GetRemoteChats(c.Request().Context(), userID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{StatusCode: 500, Message: err.Error()})
+		return respondError(c, err, "")
 	}
 
 	var response []views.RemoteChatListResponse
@@ -90,17 +115,27 @@ func (h *ChatHandler) GetRemoteChats(c echo.Context) error {
 }
 
 func (h *ChatHandler) GetRemoteGroups(c echo.Context) error {
-	groups, err := h.chatService.GetRemoteGroups()
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: 401, Message: "Unauthorized"})
+	}
+
+	groups, err := h.chatService.GetRemoteGroups(c.Request().Context(), userID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{StatusCode: 500, Message: err.Error()})
+		return respondError(c, err, "")
 	}
 	return c.JSON(http.StatusOK, views.Success{StatusCode: 200, Data: groups})
 }
 
 func (h *ChatHandler) GetRegisteredChats(c echo.Context) error {
-	chats, err := h.chatService.GetRegisteredChats()
+	session, failure := h.sessionFor(c)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
+	chats, err := h.chatService.GetRegisteredChats(session.WahaSessionName)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{StatusCode: 500, Message: err.Error()})
+		return respondError(c, err, "")
 	}
 
 	resp := views.NewRegisteredChatResponse(chats)
@@ -108,22 +143,181 @@ func (h *ChatHandler) GetRegisteredChats(c echo.Context) error {
 }
 
 func (h *ChatHandler) RegisterChat(c echo.Context) error {
+	session, failure := h.sessionFor(c)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
 	var req views.RegisterChatRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: 400, Message: "Invalid payload"})
 	}
 
-	chat, err := h.chatService.RegisterChat(req.ChatID, req.Name, req.Type)
+	if req.Platform == "" {
+		req.Platform = messenger.PlatformWhatsApp
+	}
+	if !messenger.IsPlatform(req.Platform) {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: 400, Message: "Unknown platform " + req.Platform})
+	}
+
+	// Lumi can only talk in Telegram chats its bot is a member of, which
+	// getChat confirms.
+	if req.Platform == messenger.PlatformTelegram {
+		if h.telegram == nil {
+			return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: 400, Message: "Telegram is not configured"})
+		}
+
+		remote, err := h.telegram.Chat(c.Request().Context(), req.ChatID)
+		if errors.Is(err, messenger.ErrInvalidID) {
+			return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: 400, Message: "Telegram chat ids are numeric"})
+		}
+		if _, ok := connections.AsTelegramError(err); ok {
+			return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: 400, Message: "The Telegram bot can't see this chat; add it to the chat first"})
+		}
+		if err != nil {
+			return respondError(c, err, "")
+		}
+
+		if req.Name == "" {
+			req.Name = remote.Name
+		}
+		if req.Type == "" {
+			req.Type = "chat"
+			if remote.IsGroup {
+				req.Type = "group"
+			}
+		}
+	}
+
+	chat, err := h.chatService.RegisterChat(session.WahaSessionName, req.Platform, req.ChatID, req.Name, req.Type)
+	if errors.Is(err, services.ErrChatRegisteredElsewhere) {
+		return c.JSON(http.StatusConflict, views.Failure{StatusCode: 409, Message: "Another user already registered this chat"})
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{StatusCode: 500, Message: err.Error()})
+		return respondError(c, err, "")
 	}
 	return c.JSON(http.StatusOK, views.Success{StatusCode: 200, Message: "Chat registered", Data: chat})
 }
 
 func (h *ChatHandler) UnregisterChat(c echo.Context) error {
+	session, failure := h.sessionFor(c)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
 	id := c.Param("chatId")
-	if err := h.chatService.UnregisterChat(id); err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{StatusCode: 500, Message: err.Error()})
+	if err := h.chatService.UnregisterChat(session.WahaSessionName, id); err != nil {
+		return respondError(c, err, "")
 	}
 	return c.JSON(http.StatusOK, views.Success{StatusCode: 200, Message: "Chat unregistered"})
 }
+
+func (h *ChatHandler) UpdateChatSettings(c echo.Context) error {
+	session, failure := h.sessionFor(c)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
+	var req views.UpdateChatSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: 400, Message: "Invalid payload"})
+	}
+
+	chat, err := h.chatService.UpdateChatSettings(session.WahaSessionName, c.Param("chatId"), req.ShowTyping, req.SendReadReceipts)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: 404, Message: "Chat is not registered"})
+	}
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	resp := views.NewRegisteredChatResponse([]models.RegisteredChat{*chat})
+	return c.JSON(http.StatusOK, views.Success{StatusCode: 200, Message: "Chat settings updated", Data: (*resp)[0]})
+}
+
+// SetChatTags replaces the chat's tags, which campaigns can target.
+func (h *ChatHandler) SetChatTags(c echo.Context) error {
+	session, failure := h.sessionFor(c)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
+	var req views.SetChatTagsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: 400, Message: "Invalid payload"})
+	}
+
+	chat, err := h.chatService.SetChatTags(session.WahaSessionName, c.Param("chatId"), req.Tags)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: 404, Message: "Chat is not registered"})
+	}
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	resp := views.NewRegisteredChatResponse([]models.RegisteredChat{*chat})
+	return c.JSON(http.StatusOK, views.Success{StatusCode: 200, Message: "Chat tags updated", Data: (*resp)[0]})
+}
+
+// sessionFor resolves the caller's WhatsApp session, which the registered
+// chats belong to.
+func (h *ChatHandler) sessionFor(c echo.Context) (*models.WhatsAppSession, *views.Failure) {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return nil, &views.Failure{StatusCode: 401, Message: "Unauthorized"}
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		c.Logger().Errorf("%s: %v", c.Path(), err)
+		return nil, &views.Failure{StatusCode: 500, Message: "Internal server error"}
+	}
+	return session, nil
+}
+
+func (h *ChatHandler) GetChatMessages(c echo.Context) error {
+	session, failure := h.sessionFor(c)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
+	chatID := c.Param("chatId")
+	if !h.chatService.IsChatAllowed(session.WahaSessionName, chatID) {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: 404, Message: "Chat is not registered"})
+	}
+
+	limit := 50
+	if raw := c.QueryParam("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 500 {
+			return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: 400, Message: "limit must be between 1 and 500"})
+		}
+		limit = parsed
+	}
+
+	messages, err := h.chatService.GetChatHistory(session.WahaSessionName, chatID, limit)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+	return c.JSON(http.StatusOK, views.Success{StatusCode: 200, Message: "Chat messages fetched", Data: views.NewChatMessageListResponse(messages)})
+}
+
+// SyncChatHistory imports the chat's recent messages from WAHA right away
+// instead of waiting for the periodic sync.
+func (h *ChatHandler) SyncChatHistory(c echo.Context) error {
+	session, failure := h.sessionFor(c)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
+	chatID := c.Param("chatId")
+	if !h.chatService.IsChatAllowed(session.WahaSessionName, chatID) {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: 404, Message: "Chat is not registered"})
+	}
+
+	imported, err := h.historyService.SyncChat(c.Request().Context(), session.WahaSessionName, chatID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+	return c.JSON(http.StatusOK, views.Success{StatusCode: 200, Message: "Chat history synced", Data: views.SyncChatHistoryResponse{ChatID: chatID, Imported: imported}})
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/db/dbtest"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections/telegramtest"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections/wahatest"
+	"github.com/Mahaveer86619/lumi/pkg/services/events"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+// handlerTest serves handlers backed by a wahatest server. The caller is
+// taken from the X-User-Id header in place of a JWT.
+type handlerTest struct {
+	e              *echo.Echo
+	srv            *wahatest.Server
+	sessionService *services.SessionService
+	chatService    *services.ChatService
+	outboxService  *services.OutboxService
+	bus            *events.Bus // set by handlers that subscribe to webhooks
+}
+
+func newHandlerTest(t *testing.T, tables ...interface{}) *handlerTest {
+	t.Helper()
+
+	dbtest.Open(t, append([]interface{}{&models.WhatsAppSession{}, &models.RegisteredChat{}, &models.ChatMessage{}, &models.OutboxMessage{}}, tables...)...)
+
+	srv := wahatest.NewServer()
+	t.Cleanup(srv.Close)
+
+	previous := config.GConfig
+	config.GConfig = &config.Config{WahaServiceURL: srv.URL, WahaAPIKey: srv.APIKey, WahaSessionName: "lumi"}
+	t.Cleanup(func() { config.GConfig = previous })
+
+	sessionService := services.NewSessionService()
+	chatService := services.NewChatService(sessionService)
+	return &handlerTest{
+		e:              echo.New(),
+		srv:            srv,
+		sessionService: sessionService,
+		chatService:    chatService,
+		outboxService:  services.NewOutboxService(sessionService, chatService),
+	}
+}
+
+// startOutbox delivers queued messages until the test ends.
+func (h *handlerTest) startOutbox(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	h.outboxService.Start(ctx)
+}
+
+// waitForSent waits until WAHA has recorded n sends and returns them.
+func (h *handlerTest) waitForSent(t *testing.T, n int) []wahatest.SentMessage {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(h.srv.Sent()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d sends, got %+v", n, h.srv.Sent())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return h.srv.Sent()
+}
+
+// group returns a route group whose requests run as the X-User-Id user.
+func (h *handlerTest) group(prefix string) *echo.Group {
+	return h.e.Group(prefix, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if id, err := strconv.ParseUint(c.Request().Header.Get("X-User-Id"), 10, 64); err == nil {
+				c.Set("user_id", uint(id))
+			}
+			return next(c)
+		}
+	})
+}
+
+// pair starts and pairs the WAHA session of userID and returns its name.
+func (h *handlerTest) pair(t *testing.T, userID uint) string {
+	t.Helper()
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		t.Fatalf("GetOrCreateUserSession: %v", err)
+	}
+	if err := h.sessionService.Client(session.WahaSessionName).StartSession(context.Background()); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if err := h.srv.Pair(session.WahaSessionName, connModel.MeInfo{ID: "15550001111@c.us"}); err != nil {
+		t.Fatalf("Pair: %v", err)
+	}
+	return session.WahaSessionName
+}
+
+// do sends a request as userID, or anonymously for 0, and decodes the
+// response's data into out.
+func (h *handlerTest) do(t *testing.T, userID uint, method, path, body string, out any) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if userID != 0 {
+		req.Header.Set("X-User-Id", strconv.FormatUint(uint64(userID), 10))
+	}
+	rec := httptest.NewRecorder()
+	h.e.ServeHTTP(rec, req)
+
+	if out != nil && rec.Code < 300 {
+		var resp struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s %s: decoding %s: %v", method, path, rec.Body, err)
+		}
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			t.Fatalf("%s %s: decoding data %s: %v", method, path, resp.Data, err)
+		}
+	}
+	return rec.Code
+}
+
+func newChatHandlerTest(t *testing.T) *handlerTest {
+	h := newHandlerTest(t)
+	NewChatHandler(h.group("/chats"), h.chatService, services.NewHistoryService(h.sessionService, h.chatService), h.sessionService, nil)
+	return h
+}
+
+func TestRegisteredChatsBelongToTheUser(t *testing.T) {
+	h := newChatHandlerTest(t)
+	const alice, bob = 1, 2
+
+	status := h.do(t, alice, http.MethodPost, "/chats/register", `{"chat_id":"15552223333@c.us","name":"Friend","type":"chat"}`, nil)
+	if status != http.StatusOK {
+		t.Fatalf("register: status %d", status)
+	}
+
+	tests := []struct {
+		user  uint
+		chats int
+	}{
+		{alice, 1},
+		{bob, 0},
+	}
+	for _, tt := range tests {
+		var chats []views.RegisteredChat
+		if status := h.do(t, tt.user, http.MethodGet, "/chats/registered", "", &chats); status != http.StatusOK {
+			t.Fatalf("user %d: status %d", tt.user, status)
+		}
+		if len(chats) != tt.chats {
+			t.Errorf("user %d sees %d chats, want %d: %+v", tt.user, len(chats), tt.chats, chats)
+		}
+	}
+
+	// Unregistering someone else's chat leaves it alone.
+	if status := h.do(t, bob, http.MethodDelete, "/chats/register/15552223333@c.us", "", nil); status != http.StatusOK {
+		t.Fatalf("bob unregistering: status %d", status)
+	}
+	var chats []views.RegisteredChat
+	h.do(t, alice, http.MethodGet, "/chats/registered", "", &chats)
+	if len(chats) != 1 {
+		t.Errorf("alice's chat was unregistered by bob")
+	}
+
+	if status := h.do(t, 0, http.MethodGet, "/chats/registered", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("anonymous request: status %d, want 401", status)
+	}
+}
+
+func TestGetRemoteChats(t *testing.T) {
+	h := newChatHandlerTest(t)
+	const alice = 1
+
+	h.pair(t, alice)
+	h.srv.SetChats([]connModel.ChatSummary{
+		{ID: "15552223333@c.us", Name: "Friend", LastMessage: &connModel.WAMessage{Body: "see you", Timestamp: 1700000000}},
+		{ID: "120363000000000000@g.us", Name: "Book club", LastMessage: &connModel.WAMessage{Data: map[string]interface{}{"caption": "the cover"}}},
+		{ID: "120363111111111111@newsletter", Name: "News", LastMessage: &connModel.WAMessage{Data: map[string]interface{}{"type": "image"}}},
+	})
+
+	var chats []views.RemoteChatListResponse
+	if status := h.do(t, alice, http.MethodGet, "/chats/remote/chats", "", &chats); status != http.StatusOK {
+		t.Fatalf("remote chats: status %d", status)
+	}
+
+	want := []views.RemoteChatListResponse{
+		{ID: "15552223333@c.us", Name: "Friend", LastMessage: "see you", Timestamp: 1700000000, Type: "chat"},
+		{ID: "120363000000000000@g.us", Name: "Book club", LastMessage: "the cover", Type: "group"},
+		{ID: "120363111111111111@newsletter", Name: "News", LastMessage: "[image]", Type: "channel"},
+	}
+	if len(chats) != len(want) {
+		t.Fatalf("remote chats = %+v, want %d", chats, len(want))
+	}
+	for i := range want {
+		if chats[i] != want[i] {
+			t.Errorf("chat %d = %+v, want %+v", i, chats[i], want[i])
+		}
+	}
+}
+
+func TestUpdateChatSettings(t *testing.T) {
+	h := newChatHandlerTest(t)
+	const alice, bob = 1, 2
+	const friend = "15552223333@c.us"
+
+	if status := h.do(t, alice, http.MethodPost, "/chats/register", `{"chat_id":"`+friend+`","name":"Friend","type":"chat"}`, nil); status != http.StatusOK {
+		t.Fatalf("register: status %d", status)
+	}
+
+	tests := []struct {
+		name     string
+		user     uint
+		body     string
+		status   int
+		typing   bool
+		receipts bool
+	}{
+		{"typing off", alice, `{"show_typing":false}`, http.StatusOK, false, true},
+		{"receipts off, typing untouched", alice, `{"send_read_receipts":false}`, http.StatusOK, false, false},
+		{"both on", alice, `{"show_typing":true,"send_read_receipts":true}`, http.StatusOK, true, true},
+		{"another user's chat", bob, `{"show_typing":false}`, http.StatusNotFound, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := h.do(t, tt.user, http.MethodPatch, "/chats/register/"+friend+"/settings", tt.body, nil); status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+
+			session, _ := h.sessionService.GetOrCreateUserSession(alice)
+			chat, err := h.chatService.GetRegisteredChat(session.WahaSessionName, friend)
+			if err != nil {
+				t.Fatalf("GetRegisteredChat: %v", err)
+			}
+			if chat.ShowTyping != tt.typing || chat.SendReadReceipts != tt.receipts {
+				t.Errorf("typing = %v, receipts = %v; want %v, %v", chat.ShowTyping, chat.SendReadReceipts, tt.typing, tt.receipts)
+			}
+		})
+	}
+}
+
+func TestRegisterChatPlatforms(t *testing.T) {
+	tg := telegramtest.NewServer()
+	t.Cleanup(tg.Close)
+	tg.AddChat(connModel.TGChat{ID: -100, Type: connModel.TGChatSupergroup, Title: "Book club"})
+
+	// The same handlers with and without a Telegram bot.
+	h := newChatHandlerTest(t)
+	NewChatHandler(h.group("/telegram/chats"), h.chatService, services.NewHistoryService(h.sessionService, h.chatService), h.sessionService, messenger.NewTelegram(tg.Client()))
+
+	const alice, bob = 1, 2
+	tests := []struct {
+		name   string
+		path   string
+		user   uint
+		body   string
+		status int
+		want   models.RegisteredChat // platform, name and type on success
+	}{
+		{"WhatsApp by default", "/telegram/chats", alice, `{"chat_id":"15552223333@c.us","name":"Friend","type":"chat"}`, http.StatusOK, models.RegisteredChat{Platform: "whatsapp", Name: "Friend", Type: "chat"}},
+		{"unknown platform", "/telegram/chats", alice, `{"platform":"signal","chat_id":"1"}`, http.StatusBadRequest, models.RegisteredChat{}},
+		{"Telegram not configured", "/chats", alice, `{"platform":"telegram","chat_id":"-100"}`, http.StatusBadRequest, models.RegisteredChat{}},
+		{"Telegram name and type from getChat", "/telegram/chats", alice, `{"platform":"telegram","chat_id":"-100"}`, http.StatusOK, models.RegisteredChat{Platform: "telegram", Name: "Book club", Type: "group"}},
+		{"non-numeric Telegram id", "/telegram/chats", alice, `{"platform":"telegram","chat_id":"15552223333@c.us"}`, http.StatusBadRequest, models.RegisteredChat{}},
+		{"Telegram chat the bot can't see", "/telegram/chats", alice, `{"platform":"telegram","chat_id":"-200"}`, http.StatusBadRequest, models.RegisteredChat{}},
+		{"Telegram chat of another user", "/telegram/chats", bob, `{"platform":"telegram","chat_id":"-100"}`, http.StatusConflict, models.RegisteredChat{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chat models.RegisteredChat
+			if status := h.do(t, tt.user, http.MethodPost, tt.path+"/register", tt.body, &chat); status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if chat.Platform != tt.want.Platform || chat.Name != tt.want.Name || chat.Type != tt.want.Type {
+				t.Errorf("registered %s/%s/%s, want %s/%s/%s", chat.Platform, chat.Name, chat.Type, tt.want.Platform, tt.want.Name, tt.want.Type)
+			}
+		})
+	}
+}
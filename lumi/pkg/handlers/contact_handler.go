@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+type ContactHandler struct {
+	contactService *services.ContactService
+}
+
+func NewContactHandler(group *echo.Group, contactService *services.ContactService) *ContactHandler {
+	handler := &ContactHandler{contactService: contactService}
+
+	group.GET("", handler.ListContacts)
+	group.GET("/check/:phone", handler.CheckNumber)
+	group.POST("/check", handler.CheckNumbers)
+	group.GET("/:contactId", handler.GetContact)
+	group.POST("/:contactId/block", handler.setBlocked(true))
+	group.POST("/:contactId/unblock", handler.setBlocked(false))
+
+	return handler
+}
+
+func (h *ContactHandler) ListContacts(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	refresh := c.QueryParam("refresh") == "true"
+	contacts, err := h.contactService.ListContacts(c.Request().Context(), userID, c.QueryParam("q"), refresh)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Contacts fetched", Data: views.NewContactListResponse(contacts)})
+}
+
+func (h *ContactHandler) GetContact(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	refresh := c.QueryParam("refresh") == "true"
+	contact, err := h.contactService.GetContact(c.Request().Context(), userID, c.Param("contactId"), refresh)
+	if errors.Is(err, services.ErrContactNotFound) {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: http.StatusNotFound, Message: err.Error()})
+	}
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Contact fetched", Data: views.NewContactResponse(*contact)})
+}
+
+func (h *ContactHandler) CheckNumber(c echo.Context) error {
+	return h.checkNumbers(c, []string{c.Param("phone")})
+}
+
+func (h *ContactHandler) CheckNumbers(c echo.Context) error {
+	var req views.CheckNumbersRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Invalid payload"})
+	}
+	return h.checkNumbers(c, req.Phones)
+}
+
+func (h *ContactHandler) checkNumbers(c echo.Context, phones []string) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	checks, err := h.contactService.CheckNumbers(c.Request().Context(), userID, phones)
+	if errors.Is(err, services.ErrNoNumbers) {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
+	if errors.Is(err, services.ErrTooManyNumbers) {
+		return c.JSON(http.StatusBadRequest, views.Failure{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("At most %d numbers can be checked at once", services.MaxBulkNumberChecks),
+		})
+	}
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Numbers checked", Data: views.NewNumberCheckListResponse(checks)})
+}
+
+func (h *ContactHandler) setBlocked(blocked bool) echo.HandlerFunc {
+	message := "Contact unblocked"
+	if blocked {
+		message = "Contact blocked"
+	}
+
+	return func(c echo.Context) error {
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+		}
+
+		if err := h.contactService.SetBlocked(c.Request().Context(), userID, c.Param("contactId"), blocked); err != nil {
+			return respondError(c, err, "")
+		}
+
+		return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: message})
+	}
+}
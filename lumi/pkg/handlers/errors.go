@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+// wahaErrorMessages explains each WahaErrorCode to the client. WAHA's own
+// text is only logged: it can carry internals of WAHA or of other sessions.
+var wahaErrorMessages = map[connections.WahaErrorCode]string{
+	connections.WahaErrSessionState: "The WhatsApp session is not in a state that allows this",
+	connections.WahaErrNotFound:     "WhatsApp does not know this chat or message",
+	connections.WahaErrBadRequest:   "WhatsApp rejected the request",
+	connections.WahaErrUnauthorized: "Lumi could not authenticate with WhatsApp",
+	connections.WahaErrUnavailable:  "WhatsApp service is unreachable",
+}
+
+// respondError writes err as a failure response. WAHA errors are mapped to
+// a status that says whose fault it was and a fixed explanation of their
+// code; anything else is a 500. Raw details only go to the logs. message,
+// when set, replaces the default text shown to the client.
+func respondError(c echo.Context, err error, message string) error {
+	wahaErr, ok := connections.AsWahaError(err)
+	if !ok {
+		c.Logger().Errorf("%s: %v", c.Path(), err)
+		if message == "" {
+			message = "Internal server error"
+		}
+		return c.JSON(http.StatusInternalServerError, views.Failure{StatusCode: http.StatusInternalServerError, Message: message})
+	}
+
+	status, detail := http.StatusBadGateway, views.ErrorResponse{Code: string(wahaErr.Code), Message: wahaErrorMessages[wahaErr.Code]}
+	switch wahaErr.Code {
+	case connections.WahaErrSessionState:
+		status = http.StatusConflict
+		detail.Session = wahaErr.Session
+		detail.Status = wahaErr.Status
+		detail.Expected = wahaErr.Expected
+		if message == "" {
+			message = "WhatsApp session is not ready"
+		}
+	case connections.WahaErrNotFound:
+		status = http.StatusNotFound
+		if message == "" {
+			message = "Not found on WhatsApp"
+		}
+	case connections.WahaErrBadRequest:
+		status = http.StatusBadRequest
+		if message == "" {
+			message = "WhatsApp rejected the request"
+		}
+	default:
+		// Unreachable, misconfigured or crashing WAHA: the client can't do
+		// anything about it.
+		if message == "" {
+			message = "WhatsApp service unavailable"
+		}
+	}
+	c.Logger().Errorf("%s: waha: %v", c.Path(), err)
+
+	return c.JSON(status, views.WahaFailure{StatusCode: status, Message: message, Waha: detail})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+func TestRespondError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		message string
+		status  int
+		want    views.WahaFailure
+	}{
+		{
+			"session not ready",
+			&connections.WahaError{StatusCode: 422, Code: connections.WahaErrSessionState, Message: "Session lumi is STOPPED", Session: "lumi", Status: "STOPPED", Expected: []string{"WORKING"}},
+			"", http.StatusConflict,
+			views.WahaFailure{StatusCode: http.StatusConflict, Message: "WhatsApp session is not ready", Waha: views.ErrorResponse{Code: "session_state", Message: "The WhatsApp session is not in a state that allows this", Session: "lumi", Status: "STOPPED", Expected: []string{"WORKING"}}},
+		},
+		{
+			"not found",
+			&connections.WahaError{StatusCode: 404, Code: connections.WahaErrNotFound, Message: "Chat not found"},
+			"", http.StatusNotFound,
+			views.WahaFailure{StatusCode: http.StatusNotFound, Message: "Not found on WhatsApp", Waha: views.ErrorResponse{Code: "not_found", Message: "WhatsApp does not know this chat or message"}},
+		},
+		{
+			"bad request with a message",
+			&connections.WahaError{StatusCode: 400, Code: connections.WahaErrBadRequest, Message: "invalid chatId"},
+			"Failed to send", http.StatusBadRequest,
+			views.WahaFailure{StatusCode: http.StatusBadRequest, Message: "Failed to send", Waha: views.ErrorResponse{Code: "bad_request", Message: "WhatsApp rejected the request"}},
+		},
+		{
+			"waha down",
+			&connections.WahaError{Code: connections.WahaErrUnavailable, Message: "dial tcp: connection refused"},
+			"", http.StatusBadGateway,
+			views.WahaFailure{StatusCode: http.StatusBadGateway, Message: "WhatsApp service unavailable", Waha: views.ErrorResponse{Code: "unavailable", Message: "WhatsApp service is unreachable"}},
+		},
+		{
+			"wrong api key",
+			&connections.WahaError{StatusCode: 401, Code: connections.WahaErrUnauthorized, Message: "Unauthorized"},
+			"", http.StatusBadGateway,
+			views.WahaFailure{StatusCode: http.StatusBadGateway, Message: "WhatsApp service unavailable", Waha: views.ErrorResponse{Code: "unauthorized", Message: "Lumi could not authenticate with WhatsApp"}},
+		},
+		{
+			"internal error",
+			errors.New("pq: connection refused"),
+			"", http.StatusInternalServerError,
+			views.WahaFailure{StatusCode: http.StatusInternalServerError, Message: "Internal server error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			var logs bytes.Buffer
+			e.Logger.SetOutput(&logs)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+			if err := respondError(c, tt.err, tt.message); err != nil {
+				t.Fatalf("respondError: %v", err)
+			}
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d", rec.Code, tt.status)
+			}
+
+			var got views.WahaFailure
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding %s: %v", rec.Body, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("response = %+v, want %+v", got, tt.want)
+			}
+
+			// WAHA's own text stays in the server logs.
+			if wahaErr, ok := connections.AsWahaError(tt.err); ok {
+				if strings.Contains(rec.Body.String(), wahaErr.Message) {
+					t.Errorf("response %s leaks WAHA's %q", rec.Body, wahaErr.Message)
+				}
+				if !strings.Contains(logs.String(), wahaErr.Message) {
+					t.Errorf("logs %q miss WAHA's %q", logs.String(), wahaErr.Message)
+				}
+			}
+		})
+	}
+}
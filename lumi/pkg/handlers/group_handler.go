@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+type GroupHandler struct {
+	groupService *services.GroupService
+}
+
+func NewGroupHandler(group *echo.Group, groupService *services.GroupService) *GroupHandler {
+	handler := &GroupHandler{groupService: groupService}
+
+	group.GET("", handler.ListGroups)
+	group.POST("", handler.CreateGroup)
+	group.GET("/:groupId", handler.GetGroup)
+	group.PATCH("/:groupId", handler.UpdateGroup)
+	group.PUT("/:groupId/picture", handler.SetGroupPicture)
+
+	group.POST("/:groupId/participants/add", handler.updateParticipants("add"))
+	group.POST("/:groupId/participants/remove", handler.updateParticipants("remove"))
+	group.POST("/:groupId/admins/promote", handler.updateParticipants("promote"))
+	group.POST("/:groupId/admins/demote", handler.updateParticipants("demote"))
+
+	group.GET("/:groupId/invite", handler.GetInviteLink)
+	group.POST("/:groupId/invite/revoke", handler.RevokeInviteLink)
+
+	return handler
+}
+
+func (h *GroupHandler) ListGroups(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	groups, err := h.groupService.ListGroups(c.Request().Context(), userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Groups fetched", Data: views.NewGroupListResponse(groups)})
+}
+
+func (h *GroupHandler) CreateGroup(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	var req views.CreateGroupRequest
+	if err := c.Bind(&req); err != nil || req.Name == "" {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Group name is required"})
+	}
+
+	group, err := h.groupService.CreateGroup(c.Request().Context(), userID, req.Name, req.Participants)
+	if errors.Is(err, services.ErrNoParticipants) {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusCreated, views.Success{StatusCode: http.StatusCreated, Message: "Group created", Data: views.NewGroupResponse(*group)})
+}
+
+func (h *GroupHandler) GetGroup(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	group, err := h.groupService.GetGroup(c.Request().Context(), userID, c.Param("groupId"))
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Group fetched", Data: views.NewGroupResponse(*group)})
+}
+
+func (h *GroupHandler) UpdateGroup(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	var req views.UpdateGroupRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Invalid payload"})
+	}
+	if req.Subject == nil && req.Description == nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Nothing to update"})
+	}
+
+	group, err := h.groupService.UpdateGroupInfo(c.Request().Context(), userID, c.Param("groupId"), req.Subject, req.Description)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Group updated", Data: views.NewGroupResponse(*group)})
+}
+
+func (h *GroupHandler) SetGroupPicture(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	var req connModel.GroupPictureRequest
+	if err := c.Bind(&req); err != nil || (req.File.Url == "" && req.File.Data == "") {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "A picture url or data is required"})
+	}
+
+	if err := h.groupService.SetGroupPicture(c.Request().Context(), userID, c.Param("groupId"), req.File); err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Group picture updated"})
+}
+
+// updateParticipants builds the handler for one participant action, see
+// GroupService.UpdateParticipants.
+func (h *GroupHandler) updateParticipants(action string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, ok := c.Get("user_id").(uint)
+		if !ok {
+			return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+		}
+
+		var req views.GroupParticipantsRequest
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Invalid payload"})
+		}
+
+		group, err := h.groupService.UpdateParticipants(c.Request().Context(), userID, c.Param("groupId"), action, req.Participants)
+		if errors.Is(err, services.ErrNoParticipants) {
+			return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+		}
+		if err != nil {
+			return respondError(c, err, "")
+		}
+
+		return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Participants updated", Data: views.NewGroupResponse(*group)})
+	}
+}
+
+func (h *GroupHandler) GetInviteLink(c echo.Context) error {
+	return h.inviteLink(c, false)
+}
+
+func (h *GroupHandler) RevokeInviteLink(c echo.Context) error {
+	return h.inviteLink(c, true)
+}
+
+func (h *GroupHandler) inviteLink(c echo.Context, revoke bool) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	groupID := c.Param("groupId")
+	link, err := h.groupService.GetInviteLink(c.Request().Context(), userID, groupID, revoke)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Invite link fetched", Data: views.GroupInviteResponse{GroupID: groupID, InviteLink: link}})
+}
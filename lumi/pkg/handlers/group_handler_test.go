@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+)
+
+func newGroupHandlerTest(t *testing.T) *handlerTest {
+	h := newHandlerTest(t)
+	NewGroupHandler(h.group("/groups"), services.NewGroupService(h.sessionService))
+	return h
+}
+
+func TestGroupAdministration(t *testing.T) {
+	h := newGroupHandlerTest(t)
+	const alice = 1
+	const owner, friend, other = "15550001111@c.us", "15552223333@c.us", "15554445555@c.us"
+
+	h.pair(t, alice)
+
+	var group views.Group
+	if status := h.do(t, alice, http.MethodPost, "/groups", `{"name":"Book club","participants":["+1 555 222 3333"]}`, &group); status != http.StatusCreated {
+		t.Fatalf("create: status %d", status)
+	}
+	path := "/groups/" + group.ID
+
+	steps := []struct {
+		name, method, path, body string
+		status                   int
+		members, admins          []string
+	}{
+		{"add", http.MethodPost, path + "/participants/add", `{"participants":["15554445555"]}`, http.StatusOK, []string{owner, friend, other}, []string{owner}},
+		{"promote", http.MethodPost, path + "/admins/promote", `{"participants":["` + friend + `"]}`, http.StatusOK, []string{owner, friend, other}, []string{owner, friend}},
+		{"demote", http.MethodPost, path + "/admins/demote", `{"participants":["` + friend + `"]}`, http.StatusOK, []string{owner, friend, other}, []string{owner}},
+		{"remove", http.MethodPost, path + "/participants/remove", `{"participants":["` + other + `"]}`, http.StatusOK, []string{owner, friend}, []string{owner}},
+		{"no participants", http.MethodPost, path + "/participants/add", `{"participants":[]}`, http.StatusBadRequest, nil, nil},
+		{"nothing to update", http.MethodPatch, path, `{}`, http.StatusBadRequest, nil, nil},
+		{"anonymous", http.MethodGet, path, "", http.StatusUnauthorized, nil, nil},
+	}
+
+	for _, tt := range steps {
+		var got views.Group
+		user := uint(alice)
+		if tt.status == http.StatusUnauthorized {
+			user = 0
+		}
+		if status := h.do(t, user, tt.method, tt.path, tt.body, &got); status != tt.status {
+			t.Fatalf("%s: status %d, want %d", tt.name, status, tt.status)
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+
+		var members []string
+		for _, p := range got.Participants {
+			members = append(members, p.ID)
+		}
+		if !reflect.DeepEqual(members, tt.members) || !reflect.DeepEqual(got.Admins, tt.admins) {
+			t.Errorf("%s: members %v admins %v, want %v and %v", tt.name, members, got.Admins, tt.members, tt.admins)
+		}
+	}
+
+	if status := h.do(t, alice, http.MethodPatch, path, `{"subject":"Reading club","description":"Monthly"}`, &group); status != http.StatusOK {
+		t.Fatalf("update: status %d", status)
+	}
+	if group.Subject != "Reading club" || group.Description != "Monthly" {
+		t.Errorf("updated group = %+v", group)
+	}
+}
+
+func TestGroupInviteLink(t *testing.T) {
+	h := newGroupHandlerTest(t)
+	const alice = 1
+
+	h.pair(t, alice)
+
+	var group views.Group
+	if status := h.do(t, alice, http.MethodPost, "/groups", `{"name":"Book club","participants":["15552223333"]}`, &group); status != http.StatusCreated {
+		t.Fatalf("create: status %d", status)
+	}
+	path := "/groups/" + group.ID + "/invite"
+
+	var first, again, revoked views.GroupInviteResponse
+	h.do(t, alice, http.MethodGet, path, "", &first)
+	h.do(t, alice, http.MethodGet, path, "", &again)
+	h.do(t, alice, http.MethodPost, path+"/revoke", "", &revoked)
+
+	if !strings.HasPrefix(first.InviteLink, "https://chat.whatsapp.com/") || first.GroupID != group.ID {
+		t.Errorf("invite = %+v, want a chat.whatsapp.com link for %s", first, group.ID)
+	}
+	if again.InviteLink != first.InviteLink {
+		t.Errorf("invite link changed without a revoke: %s -> %s", first.InviteLink, again.InviteLink)
+	}
+	if revoked.InviteLink == first.InviteLink || !strings.HasPrefix(revoked.InviteLink, "https://chat.whatsapp.com/") {
+		t.Errorf("revoked link = %s, want a new link", revoked.InviteLink)
+	}
+}
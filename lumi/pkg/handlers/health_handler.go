@@ -23,7 +23,7 @@ func NewHealthHandler(group *echo.Group, healthService *services.HealthService)
 }
 
 func (h *HealthHandler) GetHealth(c echo.Context) error {
-	resp, err := h.healthService.GetHealth()
+	resp, err := h.healthService.GetHealth(c.Request().Context())
 	if err != nil {
 		res := &views.Failure{}
 		res.SetStatusCode(http.StatusBadRequest)
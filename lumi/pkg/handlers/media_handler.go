@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/services/storage"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+type MediaHandler struct {
+	mediaService   *services.MediaService
+	sessionService *services.SessionService
+}
+
+func NewMediaHandler(group *echo.Group, mediaService *services.MediaService, sessionService *services.SessionService) *MediaHandler {
+	handler := &MediaHandler{
+		mediaService:   mediaService,
+		sessionService: sessionService,
+	}
+
+	group.GET("", handler.ListMedia)
+	group.GET("/:id", handler.GetMedia)
+	group.GET("/:id/download", handler.DownloadMedia)
+
+	return handler
+}
+
+func (h *MediaHandler) ListMedia(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	files, err := h.mediaService.ListSessionMedia(session.WahaSessionName, c.QueryParam("chat_id"))
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Media fetched", Data: views.NewMediaFileListResponse(files)})
+}
+
+func (h *MediaHandler) GetMedia(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	file, err := h.mediaService.GetSessionMedia(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if err != nil {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: http.StatusNotFound, Message: "Media not found"})
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Media fetched", Data: views.NewMediaFileResponse(*file)})
+}
+
+func (h *MediaHandler) DownloadMedia(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	file, err := h.mediaService.GetSessionMedia(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if err != nil {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: http.StatusNotFound, Message: "Media not found"})
+	}
+
+	reader, err := h.mediaService.Open(c.Request().Context(), file)
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: http.StatusNotFound, Message: "Media content is missing from storage"})
+	}
+	if err != nil {
+		return respondError(c, err, "")
+	}
+	defer reader.Close()
+
+	if file.Filename != "" {
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", file.Filename))
+	}
+
+	return c.Stream(http.StatusOK, file.Mimetype, reader)
+}
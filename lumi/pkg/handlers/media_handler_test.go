@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/services/storage"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+)
+
+func TestMediaBelongsToTheUser(t *testing.T) {
+	h := newHandlerTest(t, &models.MediaFile{})
+	const alice, bob = 1, 2
+	const friend = "15552223333@c.us"
+
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	mediaService := services.NewMediaService(store, h.sessionService)
+	NewMediaHandler(h.group("/media"), mediaService, h.sessionService)
+
+	sessionName := h.pair(t, alice)
+	h.pair(t, bob)
+
+	photo := h.srv.AddMedia(sessionName, "photo.jpg", "image/jpeg", []byte("jpeg"))
+	file, err := mediaService.StoreMessageMedia(context.Background(), sessionName, friend, connModel.WAMessage{ID: "false_" + friend + "_1", HasMedia: true, Media: &photo})
+	if err != nil {
+		t.Fatalf("StoreMessageMedia: %v", err)
+	}
+	id := views.NewMediaFileResponse(*file).ID
+
+	tests := []struct {
+		name   string
+		user   uint
+		path   string
+		status int
+		files  int // listed files, -1 for routes that don't list
+	}{
+		{"list", alice, "/media", http.StatusOK, 1},
+		{"list by chat", alice, "/media?chat_id=15559990000@c.us", http.StatusOK, 0},
+		{"list of another user", bob, "/media", http.StatusOK, 0},
+		{"get", alice, "/media/" + string(id), http.StatusOK, -1},
+		{"get of another user", bob, "/media/" + string(id), http.StatusNotFound, -1},
+		{"download of another user", bob, "/media/" + string(id) + "/download", http.StatusNotFound, -1},
+		{"anonymous", 0, "/media", http.StatusUnauthorized, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var files []views.MediaFile
+			var out any
+			if tt.files >= 0 {
+				out = &files
+			}
+			if status := h.do(t, tt.user, http.MethodGet, tt.path, "", out); status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+			if tt.files >= 0 && len(files) != tt.files {
+				t.Errorf("listed %d files, want %d", len(files), tt.files)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/media/"+string(id)+"/download", nil)
+	req.Header.Set("X-User-Id", strconv.Itoa(alice))
+	rec := httptest.NewRecorder()
+	h.e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "jpeg" || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("download = %d %q (%s), want the stored jpeg", rec.Code, rec.Body, rec.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type OutboxHandler struct {
+	outboxService  *services.OutboxService
+	sessionService *services.SessionService
+}
+
+func NewOutboxHandler(group *echo.Group, outboxService *services.OutboxService, sessionService *services.SessionService) *OutboxHandler {
+	handler := &OutboxHandler{
+		outboxService:  outboxService,
+		sessionService: sessionService,
+	}
+
+	group.GET("", handler.ListOutbox)
+	group.POST("/:id/retry", handler.RetryOutbox)
+
+	return handler
+}
+
+func (h *OutboxHandler) ListOutbox(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	items, err := h.outboxService.ListSessionOutbox(session.WahaSessionName, c.QueryParam("status"))
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Outbox fetched", Data: views.NewOutboxMessageListResponse(items)})
+}
+
+func (h *OutboxHandler) RetryOutbox(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	item, err := h.outboxService.Retry(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: http.StatusNotFound, Message: "Outbox message not found"})
+	}
+	if errors.Is(err, services.ErrOutboxNotRetryable) {
+		return c.JSON(http.StatusConflict, views.Failure{StatusCode: http.StatusConflict, Message: err.Error()})
+	}
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Message queued for retry", Data: views.NewOutboxMessageResponse(*item)})
+}
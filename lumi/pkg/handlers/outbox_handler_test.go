@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+)
+
+func TestOutboxListAndRetry(t *testing.T) {
+	h := newHandlerTest(t)
+	NewOutboxHandler(h.group("/outbox"), h.outboxService, h.sessionService)
+	const alice, bob = 1, 2
+
+	aliceSession, _ := h.sessionService.GetOrCreateUserSession(alice)
+	h.sessionService.GetOrCreateUserSession(bob)
+
+	enqueue := func(status string) string {
+		item, err := h.outboxService.EnqueueText(aliceSession.WahaSessionName, "15552223333@c.us", "", status, false)
+		if err != nil {
+			t.Fatalf("EnqueueText: %v", err)
+		}
+		db.DB.Model(item).Update("status", status)
+		return string(utils.Mask(item.ID))
+	}
+	dead := enqueue(models.OutboxStatusDead)
+	sent := enqueue(models.OutboxStatusSent)
+
+	var items []views.OutboxMessage
+	if status := h.do(t, alice, http.MethodGet, "/outbox?status=dead", "", &items); status != http.StatusOK || len(items) != 1 || string(items[0].ID) != dead {
+		t.Errorf("dead messages of alice = %d %+v, want %s", status, items, dead)
+	}
+	if status := h.do(t, bob, http.MethodGet, "/outbox", "", &items); status != http.StatusOK || len(items) != 0 {
+		t.Errorf("bob's outbox = %d %+v, want it empty", status, items)
+	}
+
+	tests := []struct {
+		name   string
+		user   uint
+		id     string
+		status int
+	}{
+		{"another user's message", bob, dead, http.StatusNotFound},
+		{"dead message", alice, dead, http.StatusOK},
+		{"pending again", alice, dead, http.StatusConflict},
+		{"sent message", alice, sent, http.StatusConflict},
+		{"anonymous", 0, dead, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		if status := h.do(t, tt.user, http.MethodPost, "/outbox/"+tt.id+"/retry", "", nil); status != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, status, tt.status)
+		}
+	}
+}
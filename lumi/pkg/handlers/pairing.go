@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/enums"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// WAHA rotates the pairing QR about every 20 seconds; checking more
+	// often keeps the code on screen fresh.
+	qrPollInterval = 3 * time.Second
+
+	// pairingTimeout ends a streamed pairing nobody completes.
+	pairingTimeout = 5 * time.Minute
+)
+
+// Pairing QR formats accepted by GET /whatsapp/connect.
+const (
+	pairingFormatImage  = "image"
+	pairingFormatRaw    = "raw"
+	pairingFormatBase64 = "base64"
+)
+
+// qrRotation tells whether WAHA rotated the pairing QR since it was last
+// asked, by comparing the QR's raw value.
+type qrRotation struct {
+	client connections.WahaClient
+	last   string
+}
+
+func (r *qrRotation) next(ctx context.Context) (bool, error) {
+	qr, err := r.client.GetQR(ctx, connModel.QRFormatRaw)
+	if err != nil {
+		return false, err
+	}
+	if qr.Value == r.last {
+		return false, nil
+	}
+	r.last = qr.Value
+	return true, nil
+}
+
+// streamPairing pushes a "qr" event for every QR WAHA shows until the
+// phone is linked, then a "connected" event with the profile. It ends
+// early with "failed" if the session stops, or "timeout".
+func (h *WahaHandler) streamPairing(c echo.Context, sessionName string, wahaClient connections.WahaClient, format string) error {
+	// A QR image can't travel as text, so the stream sends it as base64.
+	if format == pairingFormatImage {
+		format = pairingFormatBase64
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), pairingTimeout)
+	defer cancel()
+
+	res := startSSE(c)
+	rotation := &qrRotation{client: wahaClient}
+
+	ticker := time.NewTicker(qrPollInterval)
+	defer ticker.Stop()
+
+	for {
+		info, err := wahaClient.GetSessionStatus(ctx)
+		if err == nil {
+			switch info.Status {
+			case enums.WAHA_SESSION_WORKING.String():
+				profile, err := wahaClient.GetMe(ctx)
+				if err != nil {
+					break
+				}
+				h.ensureSelfRegistered(sessionName, profile)
+				writeSSE(res, "connected", profile)
+				return nil
+
+			case enums.WAHA_SESSION_FAILED.String(), enums.WAHA_SESSION_STOPPED.String():
+				writeSSE(res, "failed", views.SessionStatusEvent{Session: info.Name, Status: info.Status, At: time.Now()})
+				return nil
+
+			case enums.WAHA_SESSION_SCAN_QR_CODE.String():
+				if rotated, err := rotation.next(ctx); err == nil && rotated {
+					qr := views.PairingQR{Format: pairingFormatRaw, Value: rotation.last}
+					if format != pairingFormatRaw {
+						qr, err = pairingQR(ctx, wahaClient, format)
+					}
+					if err == nil {
+						if err := writeSSE(res, "qr", qr); err != nil {
+							return nil
+						}
+					}
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				writeSSE(res, "timeout", views.Failure{StatusCode: http.StatusRequestTimeout, Message: "Pairing timed out"})
+			}
+			return nil
+		case <-h.appCtx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// pairingQR fetches the session's pairing QR in a JSON format.
+func pairingQR(ctx context.Context, wahaClient connections.WahaClient, format string) (views.PairingQR, error) {
+	wahaFormat := connModel.QRFormatImage
+	if format == pairingFormatRaw {
+		wahaFormat = connModel.QRFormatRaw
+	}
+
+	qr, err := wahaClient.GetQR(ctx, wahaFormat)
+	if err != nil {
+		return views.PairingQR{}, err
+	}
+	return views.PairingQR{Format: format, Value: qr.Value, Mimetype: qr.Mimetype, Data: qr.Data}, nil
+}
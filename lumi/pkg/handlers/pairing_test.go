@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+// waitForQR starts userID's session, which waits until WAHA shows a
+// pairing QR.
+func (h *handlerTest) waitForQR(t *testing.T, userID uint) string {
+	t.Helper()
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		t.Fatalf("GetOrCreateUserSession: %v", err)
+	}
+	if err := h.sessionService.Client(session.WahaSessionName).StartSession(context.Background()); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	return session.WahaSessionName
+}
+
+// bearer returns an Authorization header for userID.
+func bearer(t *testing.T, userID uint) string {
+	t.Helper()
+
+	access, _, err := utils.GenerateTokens(userID)
+	if err != nil {
+		t.Fatalf("GenerateTokens: %v", err)
+	}
+	return "Bearer " + access
+}
+
+func TestConnectWhatsApp(t *testing.T) {
+	h := newWahaHandlerTest(t)
+	const alice = 1
+
+	sessionName := h.waitForQR(t, alice)
+
+	tests := []struct {
+		name        string
+		query       string
+		status      int
+		contentType string
+		qr          views.PairingQR // for JSON formats
+	}{
+		{"image by default", "", http.StatusOK, "image/png", views.PairingQR{}},
+		{"image", "?format=image", http.StatusOK, "image/png", views.PairingQR{}},
+		{"raw", "?format=raw", http.StatusOK, echo.MIMEApplicationJSON, views.PairingQR{Format: "raw", Value: "2@" + sessionName + ",1"}},
+		{"base64", "?format=base64", http.StatusOK, echo.MIMEApplicationJSON, views.PairingQR{Format: "base64", Mimetype: "image/png"}},
+		{"unknown format", "?format=svg", http.StatusBadRequest, echo.MIMEApplicationJSON, views.PairingQR{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whatsapp/connect"+tt.query, nil)
+			req.Header.Set("Authorization", bearer(t, alice))
+			rec := httptest.NewRecorder()
+			h.e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status || !strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), tt.contentType) {
+				t.Fatalf("status %d, content type %q; want %d, %q", rec.Code, rec.Header().Get(echo.HeaderContentType), tt.status, tt.contentType)
+			}
+			if tt.qr.Format == "" {
+				return
+			}
+
+			var resp struct {
+				Data views.PairingQR `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding %s: %v", rec.Body, err)
+			}
+			got := resp.Data
+			if tt.qr.Format == "base64" && got.Data == "" {
+				t.Errorf("base64 QR carries no data")
+			}
+			got.Data = ""
+			if got != tt.qr {
+				t.Errorf("QR = %+v, want %+v", got, tt.qr)
+			}
+		})
+	}
+
+	h.srv.Pair(sessionName, connModel.MeInfo{ID: "15550001111@c.us"})
+	req := httptest.NewRequest(http.MethodGet, "/whatsapp/connect", nil)
+	req.Header.Set("Authorization", bearer(t, alice))
+	rec := httptest.NewRecorder()
+	h.e.ServeHTTP(rec, req)
+	var connected struct {
+		Data struct {
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &connected); err != nil || rec.Code != http.StatusOK || connected.Data.Status != "connected" {
+		t.Errorf("connect once paired: status %d, %s; want connected", rec.Code, rec.Body)
+	}
+}
+
+func TestStreamPairing(t *testing.T) {
+	h := newWahaHandlerTest(t)
+	const alice = 1
+
+	sessionName := h.waitForQR(t, alice)
+
+	server := httptest.NewServer(h.e)
+	t.Cleanup(server.Close)
+
+	// A browser opens the stream with a stream token in the URL.
+	token, _, err := utils.GenerateStreamToken(alice)
+	if err != nil {
+		t.Fatalf("GenerateStreamToken: %v", err)
+	}
+	res, err := http.Get(server.URL + "/whatsapp/connect?stream=true&format=raw&token=" + token)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get(echo.HeaderContentType); ct != "text/event-stream" {
+		t.Fatalf("content type %q, want text/event-stream", ct)
+	}
+
+	events := bufio.NewScanner(res.Body)
+	next := func() (string, string) {
+		t.Helper()
+		var event, data string
+		for events.Scan() {
+			line := events.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event != "":
+				return event, data
+			}
+		}
+		t.Fatalf("stream ended: %v", events.Err())
+		return "", ""
+	}
+
+	// The phone scans the first QR; the stream reports the linked profile
+	// and ends.
+	steps := []struct {
+		event, contains string
+		then            func()
+	}{
+		{"qr", `"value":"2@` + sessionName + `,1"`, func() { h.srv.Pair(sessionName, connModel.MeInfo{ID: "15550001111@c.us"}) }},
+		{"connected", `"id":"15550001111@c.us"`, nil},
+	}
+	for _, step := range steps {
+		event, data := next()
+		if event != step.event || !strings.Contains(data, step.contains) {
+			t.Fatalf("event %s %s, want %s containing %s", event, data, step.event, step.contains)
+		}
+		if step.then != nil {
+			step.then()
+		}
+	}
+}
+
+func TestStreamTokenRoutes(t *testing.T) {
+	h := newWahaHandlerTest(t)
+	const alice = 1
+
+	h.waitForQR(t, alice)
+	stream, _, err := utils.GenerateStreamToken(alice)
+	if err != nil {
+		t.Fatalf("GenerateStreamToken: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		auth   string
+		status int
+	}{
+		{"QR with a bearer token", "/whatsapp/connect?format=raw", bearer(t, alice), http.StatusOK},
+		{"QR with a stream token", "/whatsapp/connect?format=raw&token=" + stream, "", http.StatusUnauthorized},
+		{"QR without credentials", "/whatsapp/connect?format=raw", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
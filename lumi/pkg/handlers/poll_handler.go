@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+type PollHandler struct {
+	pollService    *services.PollService
+	sessionService *services.SessionService
+}
+
+func NewPollHandler(group *echo.Group, pollService *services.PollService, sessionService *services.SessionService) *PollHandler {
+	handler := &PollHandler{
+		pollService:    pollService,
+		sessionService: sessionService,
+	}
+
+	group.GET("", handler.ListPolls)
+	group.GET("/:id", handler.GetPollTally)
+	group.POST("/:id/announce", handler.AnnounceResults)
+
+	return handler
+}
+
+func (h *PollHandler) ListPolls(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	polls, err := h.pollService.ListPolls(session.WahaSessionName, c.QueryParam("chat_id"))
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Polls fetched", Data: views.NewPollListResponse(polls)})
+}
+
+func (h *PollHandler) GetPollTally(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	tally, err := h.pollService.GetTally(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if errors.Is(err, services.ErrPollNotFound) {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: http.StatusNotFound, Message: err.Error()})
+	}
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Poll results fetched", Data: views.NewPollTallyResponse(tally)})
+}
+
+// AnnounceResults posts the poll's current results in its chat.
+func (h *PollHandler) AnnounceResults(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	tally, err := h.pollService.GetTally(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if errors.Is(err, services.ErrPollNotFound) {
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: http.StatusNotFound, Message: err.Error()})
+	}
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	item, err := h.pollService.AnnounceResults(tally)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusAccepted, views.Success{StatusCode: http.StatusAccepted, Message: "Results queued", Data: views.NewOutboxMessageResponse(*item)})
+}
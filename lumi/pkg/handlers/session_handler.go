@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/enums"
+	mid "github.com/Mahaveer86619/lumi/pkg/middleware"
+	"github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+const statusHistoryLimit = 20
+
+type SessionHandler struct {
+	// appCtx is cancelled on shutdown and ends open event streams, which
+	// would otherwise hold up the server's graceful shutdown.
+	appCtx         context.Context
+	sessionService *services.SessionService
+}
+
+// NewSessionHandler registers its routes on group, except GET /events which
+// goes on streams: that group must not require a Bearer token, the route
+// authenticates itself, see mid.StreamToken.
+func NewSessionHandler(appCtx context.Context, group, streams *echo.Group, sessionService *services.SessionService) *SessionHandler {
+	handler := &SessionHandler{
+		appCtx:         appCtx,
+		sessionService: sessionService,
+	}
+
+	group.GET("/status", handler.GetStatus)
+	streams.GET("/events", handler.StreamEvents, mid.StreamToken(nil))
+	group.POST("/events/token", handler.CreateStreamToken)
+
+	return handler
+}
+
+// GetStatus returns the caller's session status and its recent transitions.
+func (h *SessionHandler) GetStatus(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	history, err := h.sessionService.StatusHistory(session.WahaSessionName, statusHistoryLimit)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Session status fetched", Data: views.NewSessionStatusResponse(*session, history)})
+}
+
+// CreateStreamToken issues a short-lived token for opening the event
+// streams, GET /events and GET /connect?stream=true, from a browser.
+func (h *SessionHandler) CreateStreamToken(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	token, expiresAt, err := utils.GenerateStreamToken(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusCreated, views.Success{StatusCode: http.StatusCreated, Message: "Stream token created", Data: views.StreamTokenResponse{Token: token, ExpiresAt: expiresAt}})
+}
+
+// StreamEvents pushes the caller's session status, the pairing QR while
+// one is needed and the logged in profile as server-sent events.
+func (h *SessionHandler) StreamEvents(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	// Subscribe before reading the status so no change slips in between.
+	events, unsubscribe := h.sessionService.Subscribe(session.WahaSessionName)
+	defer unsubscribe()
+
+	res := startSSE(c)
+
+	ctx := c.Request().Context()
+	name := session.WahaSessionName
+	client := h.sessionService.Client(name)
+	status := session.Status
+	rotation := &qrRotation{client: client}
+
+	// sendQR pushes the pairing QR if WAHA rotated it since the last one.
+	sendQR := func() error {
+		rotated, err := rotation.next(ctx)
+		if err != nil || !rotated {
+			// The session may have moved on since; the status event follows.
+			return nil
+		}
+		png, err := client.GetQRCode(ctx)
+		if err != nil {
+			log.Printf("Session: failed to fetch QR for %s: %v", name, err)
+			return nil
+		}
+		return writeSSE(res, "qr", views.SessionQREvent{
+			Session: name,
+			QR:      "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		})
+	}
+	sendProfile := func(profile *connections.MeInfo) error {
+		return writeSSE(res, "profile", views.SessionProfileEvent{Session: name, ID: profile.ID, PushName: profile.PushName})
+	}
+	// onStatus sends what the dashboard needs next for the new status.
+	onStatus := func() error {
+		switch status {
+		case enums.WAHA_SESSION_SCAN_QR_CODE.String():
+			return sendQR()
+		case enums.WAHA_SESSION_WORKING.String():
+			if profile, err := client.GetMe(ctx); err == nil && profile != nil {
+				return sendProfile(profile)
+			}
+		}
+		return nil
+	}
+
+	if err := writeSSE(res, "status", views.SessionStatusEvent{Session: name, Status: status, At: time.Now()}); err != nil {
+		return nil
+	}
+	if err := onStatus(); err != nil {
+		return nil
+	}
+
+	qrTicker := time.NewTicker(qrPollInterval)
+	defer qrTicker.Stop()
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.appCtx.Done():
+			return nil
+		case event := <-events:
+			switch event.Type {
+			case services.SessionEventStatus:
+				status = event.Status
+				err = writeSSE(res, "status", views.SessionStatusEvent{Session: name, Status: status, At: event.At})
+				if err == nil && status == enums.WAHA_SESSION_SCAN_QR_CODE.String() {
+					err = sendQR()
+				}
+			case services.SessionEventProfile:
+				err = sendProfile(event.Profile)
+			}
+		case <-qrTicker.C:
+			if status == enums.WAHA_SESSION_SCAN_QR_CODE.String() {
+				err = sendQR()
+			}
+		case <-heartbeat.C:
+			err = writeSSEPing(res)
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const sseHeartbeat = 25 * time.Second
+
+// startSSE turns the response into a server-sent event stream.
+func startSSE(c echo.Context) *echo.Response {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+	return res
+}
+
+// writeSSE writes one server-sent event with data encoded as JSON.
+func writeSSE(res *echo.Response, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
+
+// writeSSEPing writes a comment line, which keeps proxies from closing an
+// idle stream.
+func writeSSEPing(res *echo.Response) error {
+	if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
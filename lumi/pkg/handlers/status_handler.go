@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/services/events"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+	"github.com/labstack/echo/v4"
+)
+
+type StatusHandler struct {
+	statusService  *services.StatusService
+	sessionService *services.SessionService
+}
+
+func NewStatusHandler(group *echo.Group, bus *events.Bus, statusService *services.StatusService, sessionService *services.SessionService) *StatusHandler {
+	handler := &StatusHandler{
+		statusService:  statusService,
+		sessionService: sessionService,
+	}
+
+	events.On(bus, events.MessageAck, handler.onMessageAck)
+
+	group.POST("/text", handler.PostText)
+	group.POST("/image", handler.PostImage)
+	group.POST("/video", handler.PostVideo)
+
+	group.GET("", handler.ListStatuses)
+	group.GET("/:id", handler.GetStatus)
+	group.DELETE("/:id", handler.DeleteStatus)
+
+	return handler
+}
+
+// PostText posts a text status, or schedules it when scheduled_at is in
+// the future.
+func (h *StatusHandler) PostText(c echo.Context) error {
+	var req views.PostTextStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Invalid payload"})
+	}
+
+	return h.schedule(c, models.StatusPost{
+		Kind:            models.StatusKindText,
+		Text:            req.Text,
+		BackgroundColor: req.BackgroundColor,
+		Font:            req.Font,
+	}, req.ScheduledAt)
+}
+
+func (h *StatusHandler) PostImage(c echo.Context) error {
+	return h.postMedia(c, models.StatusKindImage)
+}
+
+func (h *StatusHandler) PostVideo(c echo.Context) error {
+	return h.postMedia(c, models.StatusKindVideo)
+}
+
+func (h *StatusHandler) postMedia(c echo.Context, kind string) error {
+	var req views.PostMediaStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Invalid payload"})
+	}
+
+	return h.schedule(c, models.StatusPost{
+		Kind:  kind,
+		Text:  req.Caption,
+		Media: req.File,
+	}, req.ScheduledAt)
+}
+
+func (h *StatusHandler) schedule(c echo.Context, post models.StatusPost, scheduledAt *time.Time) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	if scheduledAt != nil {
+		post.ScheduledAt = *scheduledAt
+	}
+
+	scheduled, err := h.statusService.Schedule(session.WahaSessionName, post)
+	if err != nil {
+		return statusError(c, err)
+	}
+
+	return c.JSON(http.StatusAccepted, views.Success{StatusCode: http.StatusAccepted, Message: "Status scheduled", Data: views.NewStatusPostResponse(*scheduled)})
+}
+
+// ListStatuses lists the statuses Lumi posted or will post, filtered by
+// ?state=.
+func (h *StatusHandler) ListStatuses(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	posts, err := h.statusService.List(session.WahaSessionName, c.QueryParam("state"))
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Statuses fetched", Data: views.NewStatusPostListResponse(posts)})
+}
+
+func (h *StatusHandler) GetStatus(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	post, err := h.statusService.Get(session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if err != nil {
+		return statusError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Status fetched", Data: views.NewStatusPostResponse(*post)})
+}
+
+// DeleteStatus removes a posted status from WhatsApp, or cancels a
+// scheduled one.
+func (h *StatusHandler) DeleteStatus(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	post, err := h.statusService.Delete(c.Request().Context(), session.WahaSessionName, utils.GetMaskedId(c.Param("id")).Unmask())
+	if err != nil {
+		return statusError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Status deleted", Data: views.NewStatusPostResponse(*post)})
+}
+
+func (h *StatusHandler) onMessageAck(ctx context.Context, sessionName string, ack connections.MessageAckPayload) {
+	if err := h.statusService.RecordView(sessionName, ack); err != nil && !errors.Is(err, services.ErrUnknownStatusView) {
+		log.Printf("Failed to record status view %s: %v", ack.ID, err)
+	}
+}
+
+func statusError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidStatus):
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	case errors.Is(err, services.ErrStatusNotFound):
+		return c.JSON(http.StatusNotFound, views.Failure{StatusCode: http.StatusNotFound, Message: err.Error()})
+	case errors.Is(err, services.ErrStatusDeleted):
+		return c.JSON(http.StatusConflict, views.Failure{StatusCode: http.StatusConflict, Message: err.Error()})
+	}
+	return respondError(c, err, "")
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/services/events"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+)
+
+func TestStatuses(t *testing.T) {
+	h := newHandlerTest(t, &models.StatusPost{}, &models.StatusView{})
+	NewStatusHandler(h.group("/status"), events.NewBus(), services.NewStatusService(h.sessionService), h.sessionService)
+	const alice, bob = 1, 2
+
+	var scheduled views.StatusPost
+	if status := h.do(t, alice, http.MethodPost, "/status/text", `{"text":"Good night","scheduled_at":"2099-01-01T20:00:00Z"}`, &scheduled); status != http.StatusAccepted {
+		t.Fatalf("schedule: status %d", status)
+	}
+	if scheduled.State != models.StatusPostScheduled || scheduled.BackgroundColor != services.DefaultStatusBackground {
+		t.Errorf("scheduled %+v, want a scheduled status on the default background", scheduled)
+	}
+	path := "/status/" + scheduled.ID.String()
+
+	tests := []struct {
+		name, method, path, body string
+		user                     uint
+		status                   int
+	}{
+		{"invalid colour", http.MethodPost, "/status/text", `{"text":"Hi","background_color":"green"}`, alice, http.StatusBadRequest},
+		{"video without a file", http.MethodPost, "/status/video", `{"caption":"Hi"}`, alice, http.StatusBadRequest},
+		{"get", http.MethodGet, path, "", alice, http.StatusOK},
+		{"get another user's status", http.MethodGet, path, "", bob, http.StatusNotFound},
+		{"list", http.MethodGet, "/status?state=scheduled", "", alice, http.StatusOK},
+		{"cancel", http.MethodDelete, path, "", alice, http.StatusOK},
+		{"cancel again", http.MethodDelete, path, "", alice, http.StatusConflict},
+		{"anonymous", http.MethodGet, "/status", "", 0, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := h.do(t, tt.user, tt.method, tt.path, tt.body, nil); status != tt.status {
+				t.Errorf("status %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
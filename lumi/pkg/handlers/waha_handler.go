@@ -1,103 +1,194 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 
-	"github.com/Mahaveer86619/lumi/pkg/config"
+	mid "github.com/Mahaveer86619/lumi/pkg/middleware"
+	"github.com/Mahaveer86619/lumi/pkg/models"
 	"github.com/Mahaveer86619/lumi/pkg/models/connections"
 	"github.com/Mahaveer86619/lumi/pkg/services"
 	"github.com/Mahaveer86619/lumi/pkg/services/bot"
-	connService "github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/events"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
 	"github.com/Mahaveer86619/lumi/pkg/views"
 	"github.com/labstack/echo/v4"
 )
 
 type WahaHandler struct {
-	wahaService connService.WahaClient
-	chatService *services.ChatService
-	botService  *bot.BotService
+	// appCtx outlives single requests and is cancelled on shutdown; it
+	// scopes work the webhook hands off to the background.
+	appCtx         context.Context
+	sessionService *services.SessionService
+	chatService    *services.ChatService
+	outboxService  *services.OutboxService
+	pollService    *services.PollService
+	botService     *bot.BotService
+	dedupService   *services.DedupService
+	dispatcher     *services.EventDispatcher
 }
 
-func NewWahaHandler(group *echo.Group, wahaService connService.WahaClient, chatService *services.ChatService, botService *bot.BotService) *WahaHandler {
+// NewWahaHandler registers its routes on group, except GET /connect which
+// goes on streams: that group must not require a Bearer token, the route
+// authenticates itself, see mid.StreamToken.
+func NewWahaHandler(appCtx context.Context, group, streams *echo.Group, bus *events.Bus, dispatcher *services.EventDispatcher, sessionService *services.SessionService, chatService *services.ChatService, outboxService *services.OutboxService, pollService *services.PollService, botService *bot.BotService, dedupService *services.DedupService) *WahaHandler {
 	handler := &WahaHandler{
-		wahaService: wahaService,
-		chatService: chatService,
-		botService:  botService,
+		appCtx:         appCtx,
+		sessionService: sessionService,
+		chatService:    chatService,
+		outboxService:  outboxService,
+		pollService:    pollService,
+		botService:     botService,
+		dedupService:   dedupService,
+		dispatcher:     dispatcher,
 	}
 
-	group.GET("/connect", handler.ConnectWhatsApp)
+	events.On(bus, events.SessionStatus, handler.onSessionStatus)
+	events.On(bus, events.Message, handler.onMessage)
+	events.On(bus, events.MessageAny, handler.onMessage)
+	events.On(bus, events.PollVote, handler.onPollVote)
+	events.On(bus, events.MessageRevoked, handler.onMessageRevoked)
+
+	streams.GET("/connect", handler.ConnectWhatsApp, mid.StreamToken(isPairingStream))
 	group.GET("/code", handler.RequestCode)
 	group.POST("/start", handler.StartDefaultSession)
 
 	group.GET("/me", handler.GetMe)
+	group.GET("/webhooks/stats", handler.GetWebhookStats)
 
 	group.POST("/send/text", handler.SendText)
 	group.POST("/send/image", handler.SendImage)
+	group.POST("/send/video", handler.SendVideo)
+	group.POST("/send/voice", handler.SendVoice)
+	group.POST("/send/file", handler.SendFile)
+	group.POST("/send/location", handler.SendLocation)
+	group.POST("/send/contact", handler.SendContact)
+	group.POST("/send/poll", handler.SendPoll)
+	group.POST("/send/reaction", handler.SendReaction)
+	group.POST("/send/edit", handler.EditMessage)
+	group.POST("/send/revoke", handler.RevokeMessage)
 
 	return handler
 }
 
+// HandleWebhook hands WAHA webhook events to the dispatcher.
 func (h *WahaHandler) HandleWebhook(c echo.Context) error {
 	var webhook connections.WAHAWebhook
 	if err := c.Bind(&webhook); err != nil {
 		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "Invalid payload"})
 	}
 
-	switch webhook.Event {
-	case "session.status":
-		var statusPayload connections.SessionStatusPayload
-		if err := json.Unmarshal(webhook.Payload, &statusPayload); err != nil {
-			log.Printf("Failed to unmarshal session status: %v", err)
-			break
-		}
+	h.dispatcher.Dispatch(c.Request().Context(), webhook)
+	return c.NoContent(http.StatusOK)
+}
 
-		config.SetWhatsappConnectionStatus(statusPayload.Status)
+// GetWebhookStats reports how many of the caller's webhook deliveries were
+// dropped as duplicates since startup, and how loaded the message queue
+// is. The queue is shared, so its figures are totals without any chat or
+// session in them.
+func (h *WahaHandler) GetWebhookStats(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"})
+	}
 
-		if statusPayload.Status == "WORKING" {
-			go func() {
-				profile, err := h.wahaService.GetMe()
-				if err == nil && profile != nil {
-					h.ensureSelfRegistered(profile)
-				}
-			}()
-		}
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
 
-	case "message.any":
-		var msg connections.WAMessage
-		if err := json.Unmarshal(webhook.Payload, &msg); err != nil {
-			log.Printf("Failed to unmarshal message payload: %v", err)
-			break
-		}
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Webhook stats fetched", Data: views.NewWebhookStatsResponse(h.dedupService.Stats(session.WahaSessionName), h.botService.QueueStats())})
+}
 
-		me, err := h.wahaService.GetMe()
-		if err != nil {
-			log.Printf("Error fetching me: %v", err)
-		}
+func (h *WahaHandler) onSessionStatus(ctx context.Context, sessionName string, payload connections.SessionStatusPayload) {
+	if _, err := h.sessionService.RecordStatus(sessionName, payload.Status, services.StatusSourceWebhook); err != nil {
+		log.Printf("Failed to update status of session %s: %v", sessionName, err)
+	}
 
-		isSelfMsg := msg.From == me.ID
-		if isSelfMsg {
-			log.Printf("Self message: %s", msg.Body)
-		}
+	if payload.Status == "WORKING" {
+		go func() {
+			profile, err := h.sessionService.Client(sessionName).GetMe(h.appCtx)
+			if err == nil && profile != nil {
+				h.sessionService.UpdateDeviceID(sessionName, profile.ID)
+				h.sessionService.PublishProfile(sessionName, profile)
+				h.ensureSelfRegistered(sessionName, profile)
+			}
+		}()
+	}
+}
 
-		chatID := msg.From
-		if msg.FromMe {
-			chatID = msg.To
-		}
+func (h *WahaHandler) onMessage(ctx context.Context, sessionName string, msg connections.WAMessage) {
+	me, err := h.sessionService.Client(sessionName).GetMe(ctx)
+	if err != nil {
+		log.Printf("Error fetching me: %v", err)
+	}
+
+	isSelfMsg := me != nil && msg.From == me.ID
+	if isSelfMsg {
+		log.Printf("Self message: %s", msg.Body)
+	}
 
-		isSelfChat := msg.From == msg.To
+	chatID := messenger.FromWAMessage(msg).ChatID
 
-		if h.chatService.IsChatAllowed(chatID) || isSelfChat {
-			go h.botService.ProcessMessage(msg)
-		}
+	isSelfChat := msg.From == msg.To
+
+	if !h.chatService.IsChatAllowed(sessionName, chatID) && !isSelfChat {
+		return
 	}
 
-	return c.NoContent(http.StatusOK)
+	// Both "message" and "message.any" carry incoming messages.
+	if !h.dedupService.FirstMessage(sessionName, msg.ID.String()) {
+		return
+	}
+	// A message that never gets handled must not stay marked as handled,
+	// or WAHA's redelivery of it would be skipped.
+	forget := func() { h.dedupService.ForgetMessage(sessionName, msg.ID.String()) }
+	if err := h.botService.Enqueue(ctx, sessionName, msg, forget); err != nil {
+		log.Printf("Dropped message %s from %s: %v", msg.ID, chatID, err)
+		forget()
+	}
+}
+
+func (h *WahaHandler) onPollVote(ctx context.Context, sessionName string, vote connections.PollVotePayload) {
+	if err := h.pollService.RecordVote(sessionName, vote); err != nil {
+		log.Printf("Failed to record vote on poll %s: %v", vote.Poll.ID, err)
+	}
+}
+
+// onMessageRevoked keeps messages deleted for everyone out of the bot's
+// history.
+func (h *WahaHandler) onMessageRevoked(ctx context.Context, sessionName string, payload connections.MessageRevokedPayload) {
+	if payload.RevokedMessageID == "" {
+		return
+	}
+	// The revoked message, or what replaced it, tells which chat it was in.
+	chatID := ""
+	if payload.Before != nil {
+		chatID = messenger.FromWAMessage(*payload.Before).ChatID
+	} else if payload.After != nil {
+		chatID = messenger.FromWAMessage(*payload.After).ChatID
+	}
+
+	if err := h.chatService.RevokeMessage(sessionName, chatID, payload.RevokedMessageID); err != nil {
+		log.Printf("Failed to revoke %s in history: %v", payload.RevokedMessageID, err)
+	}
 }
 
+func isPairingStream(c echo.Context) bool {
+	return c.QueryParam("stream") == "true"
+}
+
+// ConnectWhatsApp starts the caller's session and returns the pairing QR.
+// ?format= picks "image" (PNG, the default), "raw" (the value encoded in
+// the QR) or "base64" (JSON with a base64 PNG). With ?stream=true the QR is
+// pushed as server-sent events every time WAHA rotates it, ending with the
+// connected profile; browsers authenticate the stream with ?token= from
+// POST /whatsapp/events/token.
 func (h *WahaHandler) ConnectWhatsApp(c echo.Context) error {
-	_, ok := c.Get("user_id").(uint)
+	userID, ok := c.Get("user_id").(uint)
 	if !ok {
 		return c.JSON(http.StatusUnauthorized, views.Failure{
 			StatusCode: http.StatusUnauthorized,
@@ -105,17 +196,36 @@ func (h *WahaHandler) ConnectWhatsApp(c echo.Context) error {
 		})
 	}
 
-	err := h.wahaService.StartSession()
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{
-			StatusCode: http.StatusInternalServerError,
-			Message:    "Failed to start WhatsApp session: " + err.Error(),
+	format := c.QueryParam("format")
+	if format == "" {
+		format = pairingFormatImage
+	}
+	if format != pairingFormatImage && format != pairingFormatRaw && format != pairingFormatBase64 {
+		return c.JSON(http.StatusBadRequest, views.Failure{
+			StatusCode: http.StatusBadRequest,
+			Message:    "format must be one of image, raw or base64",
 		})
 	}
+	stream := isPairingStream(c)
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+	wahaClient := h.sessionService.Client(session.WahaSessionName)
 
-	profile, err := h.wahaService.GetMe()
+	err = wahaClient.StartSession(c.Request().Context())
+	if err != nil {
+		return respondError(c, err, "Failed to start WhatsApp session")
+	}
+
+	if stream {
+		return h.streamPairing(c, session.WahaSessionName, wahaClient, format)
+	}
+
+	profile, err := wahaClient.GetMe(c.Request().Context())
 	if err == nil && profile != nil {
-		h.ensureSelfRegistered(profile)
+		h.ensureSelfRegistered(session.WahaSessionName, profile)
 
 		return c.JSON(http.StatusOK, views.Success{
 			StatusCode: http.StatusOK,
@@ -127,18 +237,31 @@ func (h *WahaHandler) ConnectWhatsApp(c echo.Context) error {
 		})
 	}
 
-	qrBytes, err := h.wahaService.GetQRCode()
+	if format != pairingFormatImage {
+		qr, err := pairingQR(c.Request().Context(), wahaClient, format)
+		if err != nil {
+			return respondError(c, err, "Failed to retrieve QR code")
+		}
+		return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Scan the QR code to log in", Data: qr})
+	}
+
+	qrBytes, err := wahaClient.GetQRCode(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusBadGateway, views.Failure{
-			StatusCode: http.StatusBadGateway,
-			Message:    "Failed to retrieve QR code from Waha: " + err.Error(),
-		})
+		return respondError(c, err, "Failed to retrieve QR code")
 	}
 
 	return c.Blob(http.StatusOK, "image/png", qrBytes)
 }
 
 func (h *WahaHandler) RequestCode(c echo.Context) error {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, views.Failure{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "Unauthorized: User ID not found",
+		})
+	}
+
 	phoneNumber := c.QueryParam("phoneNumber")
 	method := c.QueryParam("method")
 
@@ -149,19 +272,18 @@ func (h *WahaHandler) RequestCode(c echo.Context) error {
 		})
 	}
 
-	if err := h.wahaService.StartSession(); err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{
-			StatusCode: http.StatusInternalServerError,
-			Message:    "Failed to start session: " + err.Error(),
-		})
+	wahaClient, err := h.sessionService.ClientForUser(userID)
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	if err := wahaClient.StartSession(c.Request().Context()); err != nil {
+		return respondError(c, err, "Failed to start session")
 	}
 
-	resp, err := h.wahaService.RequestCode(phoneNumber, method)
+	resp, err := wahaClient.RequestCode(c.Request().Context(), phoneNumber, method)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{
-			StatusCode: http.StatusInternalServerError,
-			Message:    "Failed to request code: " + err.Error(),
-		})
+		return respondError(c, err, "Failed to request code")
 	}
 
 	return c.JSON(http.StatusOK, views.Success{
@@ -172,7 +294,7 @@ func (h *WahaHandler) RequestCode(c echo.Context) error {
 }
 
 func (h *WahaHandler) StartDefaultSession(c echo.Context) error {
-	_, ok := c.Get("user_id").(uint)
+	userID, ok := c.Get("user_id").(uint)
 	if !ok {
 		return c.JSON(http.StatusUnauthorized, views.Failure{
 			StatusCode: http.StatusUnauthorized,
@@ -180,15 +302,17 @@ func (h *WahaHandler) StartDefaultSession(c echo.Context) error {
 		})
 	}
 
-	err := h.wahaService.StartSession()
+	wahaClient, err := h.sessionService.ClientForUser(userID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{
-			StatusCode: http.StatusInternalServerError,
-			Message:    "Failed to start WhatsApp session: " + err.Error(),
-		})
+		return respondError(c, err, "")
+	}
+
+	err = wahaClient.StartSession(c.Request().Context())
+	if err != nil {
+		return respondError(c, err, "Failed to start WhatsApp session")
 	}
 
-	profile, err := h.wahaService.GetMe()
+	profile, err := wahaClient.GetMe(c.Request().Context())
 	if err == nil && profile != nil {
 		return c.JSON(http.StatusOK, views.Success{
 			StatusCode: http.StatusOK,
@@ -208,7 +332,7 @@ func (h *WahaHandler) StartDefaultSession(c echo.Context) error {
 }
 
 func (h *WahaHandler) GetMe(c echo.Context) error {
-	_, ok := c.Get("user_id").(uint)
+	userID, ok := c.Get("user_id").(uint)
 	if !ok {
 		return c.JSON(http.StatusUnauthorized, views.Failure{
 			StatusCode: http.StatusUnauthorized,
@@ -216,12 +340,14 @@ func (h *WahaHandler) GetMe(c echo.Context) error {
 		})
 	}
 
-	profile, err := h.wahaService.GetMe()
+	wahaClient, err := h.sessionService.ClientForUser(userID)
 	if err != nil {
-		return c.JSON(http.StatusBadGateway, views.Failure{
-			StatusCode: http.StatusBadGateway,
-			Message:    "Failed to fetch profile. Ensure session is connected: " + err.Error(),
-		})
+		return respondError(c, err, "")
+	}
+
+	profile, err := wahaClient.GetMe(c.Request().Context())
+	if err != nil {
+		return respondError(c, err, "Failed to fetch profile. Ensure session is connected")
 	}
 
 	return c.JSON(http.StatusOK, views.Success{
@@ -237,19 +363,17 @@ func (h *WahaHandler) SendText(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
 	}
 
-	if !h.chatService.IsChatAllowed(req.ChatID) {
-		return c.JSON(http.StatusForbidden, views.Failure{
-			StatusCode: http.StatusForbidden,
-			Message:    "Chat ID is not registered. Please register the chat/group first.",
-		})
+	session, failure := h.senderFor(c, req.ChatID)
+	if failure != nil {
+		return failure.JSON(c)
 	}
 
-	resp, err := h.wahaService.SendText(req.ChatID, req.Text)
+	item, err := h.outboxService.EnqueueText(session.WahaSessionName, req.ChatID, req.ReplyTo, req.Text, false)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{StatusCode: http.StatusInternalServerError, Message: err.Error()})
+		return respondError(c, err, "")
 	}
 
-	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Message sent", Data: resp})
+	return c.JSON(http.StatusAccepted, views.Success{StatusCode: http.StatusAccepted, Message: "Message queued", Data: views.NewOutboxMessageResponse(*item)})
 }
 
 func (h *WahaHandler) SendImage(c echo.Context) error {
@@ -258,33 +382,238 @@ func (h *WahaHandler) SendImage(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
 	}
 
-	if !h.chatService.IsChatAllowed(req.ChatID) {
-		return c.JSON(http.StatusForbidden, views.Failure{
-			StatusCode: http.StatusForbidden,
-			Message:    "Chat ID is not registered.",
-		})
+	return h.enqueueSend(c, req.ChatID, services.OutboxKindImage, req.Caption, func(session string) any {
+		req.Session = session
+		return req
+	})
+}
+
+func (h *WahaHandler) SendVideo(c echo.Context) error {
+	var req connections.MessageVideoRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
+
+	return h.enqueueSend(c, req.ChatID, services.OutboxKindVideo, req.Caption, func(session string) any {
+		req.Session = session
+		return req
+	})
+}
+
+func (h *WahaHandler) SendVoice(c echo.Context) error {
+	var req connections.MessageVoiceRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
 	}
 
-	imagePayload := connections.ImagePayload{Caption: req.Caption, File: req.File}
-	resp, err := h.wahaService.SendImage(req.ChatID, imagePayload)
+	return h.enqueueSend(c, req.ChatID, services.OutboxKindVoice, "", func(session string) any {
+		req.Session = session
+		return req
+	})
+}
+
+func (h *WahaHandler) SendFile(c echo.Context) error {
+	var req connections.MessageFileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
+
+	return h.enqueueSend(c, req.ChatID, services.OutboxKindFile, req.Caption, func(session string) any {
+		req.Session = session
+		return req
+	})
+}
+
+func (h *WahaHandler) SendLocation(c echo.Context) error {
+	var req connections.MessageLocationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
+
+	return h.enqueueSend(c, req.ChatID, services.OutboxKindLocation, req.Title, func(session string) any {
+		req.Session = session
+		return req
+	})
+}
+
+func (h *WahaHandler) SendContact(c echo.Context) error {
+	var req connections.MessageContactVcardRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
+
+	if len(req.Contacts) == 0 {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "At least one contact is required"})
+	}
+
+	return h.enqueueSend(c, req.ChatID, services.OutboxKindContact, "", func(session string) any {
+		req.Session = session
+		return req
+	})
+}
+
+func (h *WahaHandler) SendReaction(c echo.Context) error {
+	var req views.SendReactionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
+
+	if req.MessageID == "" {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "message_id is required"})
+	}
+
+	session, failure := h.senderFor(c, req.ChatID)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
+	// WAHA reacts to any message id, so one from another chat would react
+	// outside the chat the caller may send to.
+	if connections.WAMessageID(req.MessageID).ChatID() != req.ChatID && !h.chatService.HasMessage(session.WahaSessionName, req.ChatID, req.MessageID) {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "message_id is not a message of this chat"})
+	}
+
+	wahaClient := h.sessionService.Client(session.WahaSessionName)
+	if err := wahaClient.SendReaction(c.Request().Context(), req.MessageID, req.Reaction); err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Reaction sent"})
+}
+
+func (h *WahaHandler) SendPoll(c echo.Context) error {
+	var req views.SendPollRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
+
+	session, failure := h.senderFor(c, req.ChatID)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
+	poll, item, err := h.pollService.CreatePoll(session.WahaSessionName, req.ChatID, req.Question, req.Options, req.MultipleAnswers)
+	if errors.Is(err, services.ErrInvalidPoll) {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, views.Failure{StatusCode: http.StatusInternalServerError, Message: err.Error()})
+		return respondError(c, err, "")
 	}
 
-	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Image sent", Data: resp})
+	return c.JSON(http.StatusAccepted, views.Success{
+		StatusCode: http.StatusAccepted,
+		Message:    "Poll queued",
+		Data:       views.SendPollResponse{Poll: views.NewPollResponse(*poll), Outbox: views.NewOutboxMessageResponse(*item)},
+	})
+}
+
+// EditMessage changes the text of a message already sent to the chat.
+func (h *WahaHandler) EditMessage(c echo.Context) error {
+	var req views.EditMessageRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
+
+	if req.MessageID == "" || strings.TrimSpace(req.Text) == "" {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "message_id and text are required"})
+	}
+
+	session, failure := h.senderFor(c, req.ChatID)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
+	wahaClient := h.sessionService.Client(session.WahaSessionName)
+	if err := wahaClient.EditMessage(c.Request().Context(), req.ChatID, req.MessageID, req.Text); err != nil {
+		return respondError(c, err, "")
+	}
+
+	if err := h.chatService.EditMessage(session.WahaSessionName, req.ChatID, req.MessageID, req.Text); err != nil {
+		log.Printf("Edited %s on WhatsApp but failed to update history: %v", req.MessageID, err)
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Message edited"})
+}
+
+// RevokeMessage deletes a sent message for everyone in the chat.
+func (h *WahaHandler) RevokeMessage(c echo.Context) error {
+	var req views.RevokeMessageRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: err.Error()})
+	}
+
+	if req.MessageID == "" {
+		return c.JSON(http.StatusBadRequest, views.Failure{StatusCode: http.StatusBadRequest, Message: "message_id is required"})
+	}
+
+	session, failure := h.senderFor(c, req.ChatID)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
+	wahaClient := h.sessionService.Client(session.WahaSessionName)
+	if err := wahaClient.DeleteMessage(c.Request().Context(), req.ChatID, req.MessageID); err != nil {
+		return respondError(c, err, "")
+	}
+
+	if err := h.chatService.RevokeMessage(session.WahaSessionName, req.ChatID, req.MessageID); err != nil {
+		log.Printf("Revoked %s on WhatsApp but failed to update history: %v", req.MessageID, err)
+	}
+
+	return c.JSON(http.StatusOK, views.Success{StatusCode: http.StatusOK, Message: "Message revoked"})
+}
+
+// enqueueSend checks the chat and queues the request built by payload,
+// which receives the caller's session name.
+func (h *WahaHandler) enqueueSend(c echo.Context, chatID, kind, text string, payload func(session string) any) error {
+	session, failure := h.senderFor(c, chatID)
+	if failure != nil {
+		return failure.JSON(c)
+	}
+
+	item, err := h.outboxService.Enqueue(session.WahaSessionName, chatID, kind, text, payload(session.WahaSessionName))
+	if err != nil {
+		return respondError(c, err, "")
+	}
+
+	return c.JSON(http.StatusAccepted, views.Success{StatusCode: http.StatusAccepted, Message: "Message queued", Data: views.NewOutboxMessageResponse(*item)})
+}
+
+// senderFor resolves the caller's WhatsApp session for sending to chatID.
+// Only registered chats may be messaged; otherwise a ready-to-send failure
+// is returned.
+func (h *WahaHandler) senderFor(c echo.Context, chatID string) (*models.WhatsAppSession, *views.Failure) {
+	userID, ok := c.Get("user_id").(uint)
+	if !ok {
+		return nil, &views.Failure{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"}
+	}
+
+	session, err := h.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		c.Logger().Errorf("%s: %v", c.Path(), err)
+		return nil, &views.Failure{StatusCode: http.StatusInternalServerError, Message: "Internal server error"}
+	}
+
+	if !h.chatService.IsChatAllowed(session.WahaSessionName, chatID) {
+		return nil, &views.Failure{
+			StatusCode: http.StatusForbidden,
+			Message:    "Chat ID is not registered. Please register the chat/group first.",
+		}
+	}
+	return session, nil
 }
 
-func (h *WahaHandler) ensureSelfRegistered(profile *connections.MeInfo) {
+func (h *WahaHandler) ensureSelfRegistered(sessionName string, profile *connections.MeInfo) {
 	if profile == nil || profile.ID == "" {
 		return
 	}
 
-	if !h.chatService.IsChatAllowed(profile.ID) {
+	if !h.chatService.IsChatAllowed(sessionName, profile.ID) {
 		name := profile.PushName
 		if name == "" {
 			name = "Me"
 		}
-		_, err := h.chatService.RegisterChat(profile.ID, name+" (Self)", "self")
+		_, err := h.chatService.RegisterChat(sessionName, messenger.PlatformWhatsApp, profile.ID, name+" (Self)", "self")
 		if err != nil {
 			log.Printf("Failed to auto-register self chat: %v", err)
 		} else {
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/services/bot"
+	"github.com/Mahaveer86619/lumi/pkg/services/events"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+)
+
+func newWahaHandlerTest(t *testing.T) *handlerTest {
+	h := newHandlerTest(t, &models.Poll{}, &models.PollVote{}, &models.PendingPollVote{}, &models.ProcessedWebhook{}, &models.SessionStatusChange{})
+	h.bus = events.NewBus()
+	polls := services.NewPollService(h.outboxService)
+	// The tests send no chat messages, so the bot needs no history or media.
+	config.GConfig.GeminiAPIKey = "test"
+	botService := bot.NewBotService(h.sessionService, h.chatService, nil, nil, h.outboxService, polls)
+	dedup := services.NewDedupService()
+	dispatcher := services.NewEventDispatcher(h.bus, h.sessionService, dedup)
+	// Streams authenticate with real tokens, see bearer.
+	config.GConfig.JWTSecret = "jwt-secret"
+	handler := NewWahaHandler(context.Background(), h.group("/whatsapp"), h.e.Group("/whatsapp"), h.bus, dispatcher, h.sessionService, h.chatService, h.outboxService, polls, botService, dedup)
+	h.e.POST("/webhook", handler.HandleWebhook)
+	h.startOutbox(t)
+	return h
+}
+
+func TestSendMedia(t *testing.T) {
+	h := newWahaHandlerTest(t)
+	const alice = 1
+	const friend = "15552223333@c.us"
+
+	sessionName := h.pair(t, alice)
+	if _, err := h.chatService.RegisterChat(sessionName, messenger.PlatformWhatsApp, friend, "Friend", "chat"); err != nil {
+		t.Fatalf("RegisterChat: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		status   int
+		endpoint string // the WAHA endpoint called, "" for none
+	}{
+		{"text", "/whatsapp/send/text", `{"chat_id":"` + friend + `","text":"hi"}`, http.StatusAccepted, "sendText"},
+		{"image", "/whatsapp/send/image", `{"chatId":"` + friend + `","file":{"mimetype":"image/png","url":"https://example.com/a.png"}}`, http.StatusAccepted, "sendImage"},
+		{"video", "/whatsapp/send/video", `{"chatId":"` + friend + `","file":{"mimetype":"video/mp4","url":"https://example.com/a.mp4"},"asNote":true}`, http.StatusAccepted, "sendVideo"},
+		{"voice", "/whatsapp/send/voice", `{"chatId":"` + friend + `","file":{"mimetype":"audio/ogg","url":"https://example.com/a.ogg"}}`, http.StatusAccepted, "sendVoice"},
+		{"file", "/whatsapp/send/file", `{"chatId":"` + friend + `","file":{"mimetype":"application/pdf","url":"https://example.com/a.pdf"}}`, http.StatusAccepted, "sendFile"},
+		{"location", "/whatsapp/send/location", `{"chatId":"` + friend + `","latitude":52.37,"longitude":4.89,"title":"Here"}`, http.StatusAccepted, "sendLocation"},
+		{"contact", "/whatsapp/send/contact", `{"chatId":"` + friend + `","contacts":[{"fullName":"Ada","phoneNumber":"+15554445555"}]}`, http.StatusAccepted, "sendContactVcard"},
+		{"contact without contacts", "/whatsapp/send/contact", `{"chatId":"` + friend + `","contacts":[]}`, http.StatusBadRequest, ""},
+		{"unregistered chat", "/whatsapp/send/file", `{"chatId":"15559990000@c.us","file":{"url":"https://example.com/a.pdf"}}`, http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(h.srv.Sent())
+
+			if status := h.do(t, alice, http.MethodPost, tt.path, tt.body, nil); status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+
+			if tt.endpoint == "" {
+				if sent := h.srv.Sent()[before:]; len(sent) != 0 {
+					t.Errorf("sent %+v, want nothing", sent)
+				}
+				return
+			}
+			sent := h.waitForSent(t, before+1)[before:]
+			if len(sent) != 1 || sent[0].Endpoint != tt.endpoint || sent[0].Session != sessionName || sent[0].ChatID != friend {
+				t.Errorf("sent %+v, want one %s to %s on %s", sent, tt.endpoint, friend, sessionName)
+			}
+		})
+	}
+
+	if status := h.do(t, 0, http.MethodPost, "/whatsapp/send/text", `{"chat_id":"`+friend+`","text":"hi"}`, nil); status != http.StatusUnauthorized {
+		t.Errorf("anonymous send: status %d, want 401", status)
+	}
+}
+
+func TestRepliesAndReactions(t *testing.T) {
+	h := newWahaHandlerTest(t)
+	const alice = 1
+	const friend = "15552223333@c.us"
+	const question = "false_" + friend + "_AAA"
+
+	sessionName := h.pair(t, alice)
+	if _, err := h.chatService.RegisterChat(sessionName, messenger.PlatformWhatsApp, friend, "Friend", "chat"); err != nil {
+		t.Fatalf("RegisterChat: %v", err)
+	}
+
+	// A message known from the history under an id of another form.
+	if _, err := h.chatService.SaveWAMessage(sessionName, friend, "user", "hi", "3EB0C767D26A", time.Now()); err != nil {
+		t.Fatalf("SaveWAMessage: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		status   int
+		endpoint string // the WAHA endpoint called, "" for none
+		replyTo  string // reply_to sent to WAHA
+		reaction string
+	}{
+		{"plain text", "/whatsapp/send/text", `{"chat_id":"` + friend + `","text":"hi"}`, http.StatusAccepted, "sendText", "", ""},
+		{"quoted text", "/whatsapp/send/text", `{"chat_id":"` + friend + `","text":"yes","reply_to":"` + question + `"}`, http.StatusAccepted, "sendText", question, ""},
+		{"quoted file", "/whatsapp/send/file", `{"chatId":"` + friend + `","file":{"url":"https://example.com/a.pdf"},"reply_to":"` + question + `"}`, http.StatusAccepted, "sendFile", question, ""},
+		{"reaction", "/whatsapp/send/reaction", `{"chat_id":"` + friend + `","message_id":"` + question + `","reaction":"👍"}`, http.StatusOK, "reaction", "", "👍"},
+		{"reaction removed", "/whatsapp/send/reaction", `{"chat_id":"` + friend + `","message_id":"` + question + `","reaction":""}`, http.StatusOK, "reaction", "", ""},
+		{"reaction without message", "/whatsapp/send/reaction", `{"chat_id":"` + friend + `","reaction":"👍"}`, http.StatusBadRequest, "", "", ""},
+		{"reaction to a message from the history", "/whatsapp/send/reaction", `{"chat_id":"` + friend + `","message_id":"3EB0C767D26A","reaction":"👍"}`, http.StatusOK, "reaction", "", "👍"},
+		{"reaction to a message of another chat", "/whatsapp/send/reaction", `{"chat_id":"` + friend + `","message_id":"false_15559990000@c.us_BBB","reaction":"👍"}`, http.StatusBadRequest, "", "", ""},
+		{"reaction to an unknown message", "/whatsapp/send/reaction", `{"chat_id":"` + friend + `","message_id":"3EB0FFFFFFFF","reaction":"👍"}`, http.StatusBadRequest, "", "", ""},
+		{"reaction in an unregistered chat", "/whatsapp/send/reaction", `{"chat_id":"15559990000@c.us","message_id":"false_15559990000@c.us_BBB","reaction":"👍"}`, http.StatusForbidden, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(h.srv.Sent())
+
+			if status := h.do(t, alice, http.MethodPost, tt.path, tt.body, nil); status != tt.status {
+				t.Fatalf("status %d, want %d", status, tt.status)
+			}
+
+			if tt.endpoint == "" {
+				if sent := h.srv.Sent()[before:]; len(sent) != 0 {
+					t.Errorf("sent %+v, want nothing", sent)
+				}
+				return
+			}
+			sent := h.waitForSent(t, before+1)[before:]
+			// The fake takes a reaction's chat from the message id, which
+			// ids of other forms don't carry.
+			inChat := sent[0].ChatID == friend || (tt.endpoint == "reaction" && sent[0].ChatID == "")
+			if len(sent) != 1 || sent[0].Endpoint != tt.endpoint || !inChat {
+				t.Fatalf("sent %+v, want one %s to %s", sent, tt.endpoint, friend)
+			}
+			if replyTo, _ := sent[0].Payload["reply_to"].(string); replyTo != tt.replyTo {
+				t.Errorf("reply_to = %q, want %q", replyTo, tt.replyTo)
+			}
+			if tt.endpoint == "reaction" && sent[0].Text != tt.reaction {
+				t.Errorf("reaction = %q, want %q", sent[0].Text, tt.reaction)
+			}
+		})
+	}
+}
+
+func TestEditAndRevoke(t *testing.T) {
+	h := newWahaHandlerTest(t)
+	const alice = 1
+	const friend = "15552223333@c.us"
+	const mine, theirs = "true_" + friend + "_AAA", "false_" + friend + "_BBB"
+
+	sessionName := h.pair(t, alice)
+	if _, err := h.chatService.RegisterChat(sessionName, messenger.PlatformWhatsApp, friend, "Friend", "chat"); err != nil {
+		t.Fatalf("RegisterChat: %v", err)
+	}
+	h.srv.AddChatMessages(friend,
+		connModel.WAMessage{ID: mine, From: "15550001111@c.us", To: friend, FromMe: true, Body: "helo"},
+		connModel.WAMessage{ID: theirs, From: friend, To: "15550001111@c.us", Body: "hi"},
+	)
+	if _, err := h.chatService.SaveWAMessage(sessionName, friend, "model", "helo", mine, time.Now()); err != nil {
+		t.Fatalf("SaveWAMessage: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		status  int
+		history []string // the chat's stored history afterwards
+	}{
+		{"edit", "/whatsapp/send/edit", `{"chat_id":"` + friend + `","message_id":"` + mine + `","text":"hello"}`, http.StatusOK, []string{"hello"}},
+		{"edit without text", "/whatsapp/send/edit", `{"chat_id":"` + friend + `","message_id":"` + mine + `","text":" "}`, http.StatusBadRequest, []string{"hello"}},
+		{"edit someone else's message", "/whatsapp/send/edit", `{"chat_id":"` + friend + `","message_id":"` + theirs + `","text":"no"}`, http.StatusBadRequest, []string{"hello"}},
+		{"edit in an unregistered chat", "/whatsapp/send/edit", `{"chat_id":"15559990000@c.us","message_id":"` + mine + `","text":"no"}`, http.StatusForbidden, []string{"hello"}},
+		{"revoke without message", "/whatsapp/send/revoke", `{"chat_id":"` + friend + `"}`, http.StatusBadRequest, []string{"hello"}},
+		{"revoke", "/whatsapp/send/revoke", `{"chat_id":"` + friend + `","message_id":"` + mine + `"}`, http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		if status := h.do(t, alice, http.MethodPost, tt.path, tt.body, nil); status != tt.status {
+			t.Fatalf("%s: status %d, want %d", tt.name, status, tt.status)
+		}
+
+		messages, err := h.chatService.GetChatHistory(sessionName, friend, 10)
+		if err != nil {
+			t.Fatalf("%s: GetChatHistory: %v", tt.name, err)
+		}
+		var history []string
+		for _, m := range messages {
+			history = append(history, m.Content)
+		}
+		if fmt.Sprint(history) != fmt.Sprint(tt.history) {
+			t.Errorf("%s: history %q, want %q", tt.name, history, tt.history)
+		}
+	}
+}
+
+func TestRevokedWebhook(t *testing.T) {
+	h := newWahaHandlerTest(t)
+	const alice = 1
+	const friend, other = "15552223333@c.us", "15554445555@c.us"
+	const msgID = "false_" + friend + "_AAA"
+
+	sessionName := h.pair(t, alice)
+	if _, err := h.chatService.SaveWAMessage(sessionName, friend, "user", "hi", msgID, time.Now()); err != nil {
+		t.Fatalf("SaveWAMessage: %v", err)
+	}
+
+	revoked := func(session, chatID string) string {
+		return `{"event":"message.revoked","session":"` + session + `","payload":{"revokedMessageId":"` + msgID + `","after":{"id":"` + msgID + `","from":"` + chatID + `"}}}`
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		history []string
+	}{
+		{"unknown session", revoked("someone-else", friend), []string{"hi"}},
+		{"another chat", revoked(sessionName, other), []string{"hi"}},
+		{"malformed payload", `{"event":"message.revoked","session":"` + sessionName + `","payload":{"revokedMessageId":1}}`, []string{"hi"}},
+		{"revoked", revoked(sessionName, friend), nil},
+	}
+
+	for _, tt := range tests {
+		if status := h.do(t, 0, http.MethodPost, "/webhook", tt.body, nil); status != http.StatusOK {
+			t.Fatalf("%s: status %d, want 200", tt.name, status)
+		}
+
+		messages, _ := h.chatService.GetChatHistory(sessionName, friend, 10)
+		var history []string
+		for _, m := range messages {
+			history = append(history, m.Content)
+		}
+		if fmt.Sprint(history) != fmt.Sprint(tt.history) {
+			t.Errorf("%s: history %q, want %q", tt.name, history, tt.history)
+		}
+	}
+}
+
+func TestWebhookStats(t *testing.T) {
+	h := newWahaHandlerTest(t)
+	const alice, bob = 1, 2
+
+	aliceSession := h.pair(t, alice)
+	h.pair(t, bob)
+
+	status := `{"id":"evt_1","event":"session.status","session":"` + aliceSession + `","payload":{"status":"WORKING"}}`
+	for i := 0; i < 2; i++ {
+		if code := h.do(t, 0, http.MethodPost, "/webhook", status, nil); code != http.StatusOK {
+			t.Fatalf("delivery %d: status %d", i+1, code)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		user       uint
+		events     int64
+		duplicates int64
+	}{
+		{"alice sees the session's deliveries", alice, 2, 1},
+		{"bob sees none of them", bob, 0, 0},
+	}
+
+	for _, tt := range tests {
+		var stats views.WebhookStatsResponse
+		if code := h.do(t, tt.user, http.MethodGet, "/whatsapp/webhooks/stats", "", &stats); code != http.StatusOK {
+			t.Fatalf("%s: status %d", tt.name, code)
+		}
+		if stats.Events != tt.events || stats.DuplicateEvents != tt.duplicates {
+			t.Errorf("%s: stats %+v, want %d events and %d duplicates", tt.name, stats, tt.events, tt.duplicates)
+		}
+	}
+}
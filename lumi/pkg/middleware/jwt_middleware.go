@@ -39,3 +39,39 @@ func JWTMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		return next(c)
 	}
 }
+
+// StreamToken authenticates an event stream route for browsers, whose
+// EventSource can't set an Authorization header: a GET with a stream token
+// from POST /whatsapp/events/token in ?token= is let in. Only stream tokens
+// are taken from the URL; they expire within a minute. isStream, when set,
+// tells which requests to the route are streams. Everything else needs
+// JWTMiddleware's Bearer token, so routes using StreamToken must not also
+// sit behind JWTMiddleware.
+func StreamToken(isStream func(c echo.Context) bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		bearer := JWTMiddleware(next)
+		return func(c echo.Context) error {
+			token := c.QueryParam("token")
+			if token == "" || c.Request().Header.Get("Authorization") != "" {
+				return bearer(c)
+			}
+			if c.Request().Method != http.MethodGet || (isStream != nil && !isStream(c)) {
+				return c.JSON(http.StatusUnauthorized, views.Failure{
+					StatusCode: http.StatusUnauthorized,
+					Message:    "Stream tokens are only accepted on event streams",
+				})
+			}
+
+			claims, err := utils.ValidateToken(token, "stream")
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, views.Failure{
+					StatusCode: http.StatusUnauthorized,
+					Message:    "Invalid or expired stream token",
+				})
+			}
+
+			c.Set("user_id", claims.UserID)
+			return next(c)
+		}
+	}
+}
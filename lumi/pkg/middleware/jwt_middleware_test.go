@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+func newTokens(t *testing.T) (access, stream string) {
+	t.Helper()
+
+	previous := config.GConfig
+	config.GConfig = &config.Config{JWTSecret: "jwt-secret"}
+	t.Cleanup(func() { config.GConfig = previous })
+
+	access, _, err := utils.GenerateTokens(7)
+	if err != nil {
+		t.Fatalf("GenerateTokens: %v", err)
+	}
+	stream, _, err = utils.GenerateStreamToken(7)
+	if err != nil {
+		t.Fatalf("GenerateStreamToken: %v", err)
+	}
+	return access, stream
+}
+
+// authenticate runs a request through middleware and reports the status
+// and the user it authenticated.
+func authenticate(t *testing.T, middleware echo.MiddlewareFunc, method, target, auth string) (int, any) {
+	t.Helper()
+
+	var userID any
+	handler := middleware(func(c echo.Context) error {
+		userID = c.Get("user_id")
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(method, target, nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	rec := httptest.NewRecorder()
+	if err := handler(echo.New().NewContext(req, rec)); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	return rec.Code, userID
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	access, stream := newTokens(t)
+
+	tests := []struct {
+		name   string
+		query  string
+		auth   string
+		status int
+	}{
+		{"bearer access token", "", "Bearer " + access, http.StatusOK},
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"malformed header", "", access, http.StatusUnauthorized},
+		{"stream token as bearer", "", "Bearer " + stream, http.StatusUnauthorized},
+		{"stream token in the url", "?token=" + stream, "", http.StatusUnauthorized},
+		{"access token in the url", "?token=" + access, "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, userID := authenticate(t, JWTMiddleware, http.MethodGet, "/whatsapp/events"+tt.query, tt.auth)
+			if status != tt.status {
+				t.Errorf("status %d, want %d", status, tt.status)
+			}
+			if tt.status == http.StatusOK && userID != uint(7) {
+				t.Errorf("user_id = %v, want 7", userID)
+			}
+		})
+	}
+}
+
+func TestStreamToken(t *testing.T) {
+	access, stream := newTokens(t)
+	isStream := func(c echo.Context) bool { return c.QueryParam("stream") == "true" }
+
+	tests := []struct {
+		name     string
+		isStream func(echo.Context) bool
+		method   string
+		query    string
+		auth     string
+		status   int
+	}{
+		{"stream token on a stream", nil, http.MethodGet, "?token=" + stream, "", http.StatusOK},
+		{"stream token on a stream request", isStream, http.MethodGet, "?stream=true&token=" + stream, "", http.StatusOK},
+		{"stream token on a plain request", isStream, http.MethodGet, "?token=" + stream, "", http.StatusUnauthorized},
+		{"stream token on a POST", nil, http.MethodPost, "?token=" + stream, "", http.StatusUnauthorized},
+		{"access token in the url", nil, http.MethodGet, "?token=" + access, "", http.StatusUnauthorized},
+		{"garbage token", nil, http.MethodGet, "?token=nope", "", http.StatusUnauthorized},
+		{"bearer access token", isStream, http.MethodGet, "", "Bearer " + access, http.StatusOK},
+		{"bearer stream token", nil, http.MethodGet, "", "Bearer " + stream, http.StatusUnauthorized},
+		{"no credentials", nil, http.MethodGet, "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, userID := authenticate(t, StreamToken(tt.isStream), tt.method, "/whatsapp/events"+tt.query, tt.auth)
+			if status != tt.status {
+				t.Errorf("status %d, want %d", status, tt.status)
+			}
+			if tt.status == http.StatusOK && userID != uint(7) {
+				t.Errorf("user_id = %v, want 7", userID)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	echomw "github.com/labstack/echo/v4/middleware"
+)
+
+// Logger is echo's request logger with the stream token masked in logged
+// URIs, see StreamToken.
+func Logger() echo.MiddlewareFunc {
+	return echomw.LoggerWithConfig(echomw.LoggerConfig{
+		Format: strings.Replace(echomw.DefaultLoggerConfig.Format, "${uri}", "${custom}", 1),
+		CustomTagFunc: func(c echo.Context, buf *bytes.Buffer) (int, error) {
+			return buf.WriteString(redactedURI(c))
+		},
+	})
+}
+
+func redactedURI(c echo.Context) string {
+	u := *c.Request().URL
+	query := u.Query()
+	if !query.Has("token") {
+		return c.Request().RequestURI
+	}
+	query.Set("token", "REDACTED")
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestLoggerRedactsToken(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/whatsapp/events?token=secret", "/whatsapp/events?token=REDACTED"},
+		{"/whatsapp/connect?stream=true&token=secret&format=raw", "/whatsapp/connect?format=raw&stream=true&token=REDACTED"},
+		{"/whatsapp/connect?format=raw", "/whatsapp/connect?format=raw"},
+	}
+
+	for _, tt := range tests {
+		e := echo.New()
+		var logs bytes.Buffer
+		e.Logger.SetOutput(&logs)
+		e.Use(Logger())
+		e.GET("/*", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		if strings.Contains(logs.String(), "secret") || !strings.Contains(logs.String(), `"uri":"`+tt.want+`"`) {
+			t.Errorf("%s logged as %s, want uri %s", tt.target, logs.String(), tt.want)
+		}
+	}
+}
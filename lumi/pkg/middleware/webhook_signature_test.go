@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const testSecret = "webhook-secret"
+
+func sign(h func() hash.Hash, secret, body string) string {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBody(id string, at time.Time) string {
+	return fmt.Sprintf(`{"id":%q,"timestamp":%d,"event":"message.any","session":"default","payload":{}}`, id, at.UnixMilli())
+}
+
+// serve runs body through the verifier in front of handler and returns the
+// response status.
+func serve(v *WebhookVerifier, handler echo.HandlerFunc, body string, headers map[string]string) int {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	for k, val := range headers {
+		req.Header.Set(k, val)
+	}
+	rec := httptest.NewRecorder()
+
+	if err := v.Verify(handler)(e.NewContext(req, rec)); err != nil {
+		e.HTTPErrorHandler(err, e.NewContext(req, rec))
+	}
+	return rec.Code
+}
+
+func TestWebhookVerifier(t *testing.T) {
+	now := time.Now()
+	fresh := webhookBody("evt_1", now)
+
+	tests := []struct {
+		name    string
+		body    string
+		headers map[string]string
+		want    int
+		handled bool
+	}{
+		{
+			name:    "sha512 signature",
+			body:    fresh,
+			headers: map[string]string{HeaderWebhookHmac: sign(sha512.New, testSecret, fresh)},
+			want:    http.StatusOK,
+			handled: true,
+		},
+		{
+			name:    "sha256 signature",
+			body:    fresh,
+			headers: map[string]string{HeaderWebhookHmac: sign(sha256.New, testSecret, fresh), HeaderWebhookHmacAlgorithm: "sha256"},
+			want:    http.StatusOK,
+			handled: true,
+		},
+		{
+			name: "missing signature",
+			body: fresh,
+			want: http.StatusUnauthorized,
+		},
+		{
+			name:    "wrong secret",
+			body:    fresh,
+			headers: map[string]string{HeaderWebhookHmac: sign(sha512.New, "other", fresh)},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "unknown algorithm",
+			body:    fresh,
+			headers: map[string]string{HeaderWebhookHmac: sign(sha512.New, testSecret, fresh), HeaderWebhookHmacAlgorithm: "md5"},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "stale timestamp",
+			body:    webhookBody("evt_1", now.Add(-time.Hour)),
+			headers: map[string]string{HeaderWebhookHmac: sign(sha512.New, testSecret, webhookBody("evt_1", now.Add(-time.Hour)))},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "missing event id",
+			body:    webhookBody("", now),
+			headers: map[string]string{HeaderWebhookHmac: sign(sha512.New, testSecret, webhookBody("", now))},
+			want:    http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewWebhookVerifier(testSecret, 0)
+
+			handled := false
+			handler := func(c echo.Context) error {
+				handled = true
+				return c.NoContent(http.StatusOK)
+			}
+
+			if got := serve(v, handler, tt.body, tt.headers); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+			if handled != tt.handled {
+				t.Errorf("handled = %v, want %v", handled, tt.handled)
+			}
+		})
+	}
+}
+
+func TestWebhookVerifierWithoutSecret(t *testing.T) {
+	v := NewWebhookVerifier("", 0)
+
+	handled := false
+	handler := func(c echo.Context) error {
+		handled = true
+		return c.NoContent(http.StatusOK)
+	}
+
+	if got := serve(v, handler, "{}", nil); got != http.StatusOK || !handled {
+		t.Errorf("status = %d, handled = %v; want the request let through", got, handled)
+	}
+}
+
+func TestWebhookVerifierRedeliveries(t *testing.T) {
+	v := NewWebhookVerifier(testSecret, 0)
+	body := webhookBody("evt_1", time.Now())
+	headers := map[string]string{HeaderWebhookHmac: sign(sha512.New, testSecret, body)}
+
+	calls := 0
+	handler := func(c echo.Context) error {
+		calls++
+		return c.NoContent(http.StatusOK)
+	}
+
+	// Redeliveries within the window pass the signature check; the
+	// handler's deduplication decides what to skip.
+	for i := 0; i < 2; i++ {
+		if got := serve(v, handler, body, headers); got != http.StatusOK {
+			t.Fatalf("delivery %d: status %d", i+1, got)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// States of a Campaign.
+const (
+	CampaignRunning   = "running"
+	CampaignPaused    = "paused"
+	CampaignCancelled = "cancelled"
+	CampaignCompleted = "completed" // every recipient was sent to or failed
+)
+
+// States of a CampaignRecipient.
+const (
+	CampaignRecipientPending   = "pending"
+	CampaignRecipientQueued    = "queued" // handed to the outbox
+	CampaignRecipientSent      = "sent"
+	CampaignRecipientFailed    = "failed" // the outbox gave up
+	CampaignRecipientCancelled = "cancelled"
+)
+
+// Campaign sends one message template to many chats, one chat at a time
+// with a random delay in between and at most DailyCap messages a day.
+type Campaign struct {
+	gorm.Model
+
+	SessionName string    `gorm:"index;not null"`
+	Name        string    `gorm:"not null"`
+	Template    string    `gorm:"type:text;not null"` // text/template, see CampaignService
+	Status      string    `gorm:"index;not null;default:'running'"`
+	MinDelay    int       `gorm:"not null"` // seconds between two messages
+	MaxDelay    int       `gorm:"not null"`
+	DailyCap    int       `gorm:"not null"`
+	NextSendAt  time.Time `gorm:"index"`
+	CompletedAt *time.Time
+	Recipients  []CampaignRecipient
+}
+
+// CampaignRecipient is one chat a campaign sends to.
+type CampaignRecipient struct {
+	gorm.Model
+
+	CampaignID      uint   `gorm:"uniqueIndex:idx_campaign_recipient_chat;not null"`
+	ChatID          string `gorm:"uniqueIndex:idx_campaign_recipient_chat;not null"` // e.g. 123@c.us
+	Name            string // fills {{.Name}} in the template
+	Status          string `gorm:"index;not null;default:'pending'"`
+	Text            string `gorm:"type:text"` // the rendered message, once queued
+	OutboxMessageID uint   `gorm:"index"`
+	LastError       string
+	QueuedAt        *time.Time `gorm:"index"`
+	SentAt          *time.Time
+}
+
+// CampaignProgress counts the recipients of a campaign per state.
+type CampaignProgress struct {
+	Campaign Campaign
+	Counts   map[string]int
+	Total    int
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"gorm.io/gorm"
+)
+
+const (
+	ChannelPostDraft     = "draft"     // waiting for a human to approve it
+	ChannelPostPublished = "published" // handed to the outbox
+	ChannelPostDiscarded = "discarded"
+)
+
+// ChannelPost is a post for a WhatsApp Channel. Posts Lumi drafts from a
+// prompt wait for approval; posts written by hand are published right away.
+type ChannelPost struct {
+	gorm.Model
+
+	SessionName     string                 `gorm:"index;not null"`
+	ChannelID       string                 `gorm:"index;not null"` // e.g. 123@newsletter
+	Prompt          string                 `gorm:"type:text"`      // empty for posts written by hand
+	Text            string                 `gorm:"type:text;not null"`
+	Image           *connModel.FileWrapper `gorm:"serializer:json;type:text"`
+	Status          string                 `gorm:"index;not null;default:'draft'"`
+	OutboxMessageID uint                   `gorm:"index"` // set once published
+	PublishedAt     *time.Time
+}
@@ -1,20 +1,40 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type RegisteredChat struct {
 	gorm.Model
-	ChatID      string `gorm:"uniqueIndex;not null" json:"chat_id"` // e.g. 123@c.us
-	Name        string `json:"name"`                                // Friendly name
-	Type        string `json:"type"`                                // "chat" or "group"
-	IsBotActive bool   `gorm:"default:false" json:"is_bot_active"`  // Is the NLP session active?
+	SessionName string `gorm:"uniqueIndex:idx_registered_chats_session_chat;not null;default:''" json:"session_name"` // WAHA session of the user who registered it
+	ChatID      string `gorm:"uniqueIndex:idx_registered_chats_session_chat;not null" json:"chat_id"`                 // e.g. 123@c.us, or a Telegram chat id
+	Platform    string `gorm:"not null;default:'whatsapp'" json:"platform"`                                           // "whatsapp" or "telegram"
+	Name        string `json:"name"`                                                                                  // Friendly name
+	Type        string `json:"type"`                                                                                  // "chat" or "group"
+	IsBotActive bool   `gorm:"default:false" json:"is_bot_active"`                                                    // Is the NLP session active?
+
+	// When the history was last cleared; the history sync does not bring
+	// back anything older.
+	HistoryClearedAt *time.Time `json:"history_cleared_at"`
+
+	// Free-form labels, e.g. to target campaigns; lower case
+	Tags []string `gorm:"serializer:json;type:text" json:"tags"`
+
+	// Presence while the bot prepares an answer
+	ShowTyping       bool `gorm:"default:true" json:"show_typing"`        // "typing…" while generating
+	SendReadReceipts bool `gorm:"default:true" json:"send_read_receipts"` // blue ticks on handled messages
 }
 
 type ChatMessage struct {
 	gorm.Model
-	ChatID  string `gorm:"index;not null"`
-	Role    string `json:"role"`    // "user" or "model"
-	Content string `json:"content"` // Text content
+	SessionName string     `gorm:"index:idx_chat_messages_session_chat;uniqueIndex:idx_chat_messages_session_wa_message_id;not null;default:''"` // see RegisteredChat
+	ChatID      string     `gorm:"index:idx_chat_messages_session_chat;not null"`
+	Role        string     `json:"role"`    // "user" or "model"
+	Content     string     `json:"content"` // Text content
+	WAMessageID string     `gorm:"uniqueIndex:idx_chat_messages_session_wa_message_id,where:wa_message_id <> ''" json:"wa_message_id"`
+	SentAt      *time.Time `gorm:"index" json:"sent_at"` // when the message was sent on WhatsApp
+	EditedAt    *time.Time `json:"edited_at"`
+	RevokedAt   *time.Time `gorm:"index" json:"revoked_at"` // deleted for everyone; Content is cleared
 }
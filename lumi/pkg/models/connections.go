@@ -1,16 +1,32 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type WhatsAppSession struct {
 	gorm.Model
 
-	UserID          uint   `gorm:"uniqueIndex;not null"`
-	WahaSessionName string `gorm:"unique;not null"`
-	Status          string `gorm:"default:'PENDING'"`
+	UserID          uint       `gorm:"uniqueIndex;not null"`
+	WahaSessionName string     `gorm:"unique;not null"`
+	Status          string     `gorm:"default:'PENDING'"`
+	StatusAt        *time.Time // when Status last changed
 	DeviceID        string
 }
 
+// SessionStatusChange is one transition of a WhatsApp session's status.
+type SessionStatusChange struct {
+	gorm.Model
+
+	SessionName string `gorm:"index;not null"`
+	FromStatus  string
+	ToStatus    string    `gorm:"not null"`
+	Source      string    // "webhook" or "sync"
+	ChangedAt   time.Time `gorm:"index;not null"`
+}
+
 type WahaProfile struct {
 	ID      string `json:"id"`      // WhatsApp ID (Phone Number + @c.us)
 	Name    string `json:"name"`    // User's display name
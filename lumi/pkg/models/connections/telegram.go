@@ -0,0 +1,124 @@
+package connections
+
+import "encoding/json"
+
+// Telegram Bot API objects, as far as Lumi uses them.
+// See https://core.telegram.org/bots/api.
+
+// TGResponse wraps every Bot API answer.
+type TGResponse struct {
+	OK          bool                  `json:"ok"`
+	Result      json.RawMessage       `json:"result,omitempty"`
+	ErrorCode   int                   `json:"error_code,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  *TGResponseParameters `json:"parameters,omitempty"`
+}
+
+type TGResponseParameters struct {
+	RetryAfter      int   `json:"retry_after,omitempty"`        // seconds to wait after a 429
+	MigrateToChatID int64 `json:"migrate_to_chat_id,omitempty"` // a group became a supergroup
+}
+
+type TGUser struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// Telegram chat types.
+const (
+	TGChatPrivate    = "private"
+	TGChatGroup      = "group"
+	TGChatSupergroup = "supergroup"
+	TGChatChannel    = "channel"
+)
+
+type TGChat struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	Title     string `json:"title,omitempty"` // groups and channels
+	Username  string `json:"username,omitempty"`
+	FirstName string `json:"first_name,omitempty"` // private chats
+	LastName  string `json:"last_name,omitempty"`
+}
+
+func (c TGChat) IsGroup() bool {
+	return c.Type == TGChatGroup || c.Type == TGChatSupergroup
+}
+
+// Name is the chat's title, or the other person's name in a private chat.
+func (c TGChat) Name() string {
+	if c.Title != "" {
+		return c.Title
+	}
+	if c.LastName != "" {
+		return c.FirstName + " " + c.LastName
+	}
+	if c.FirstName != "" {
+		return c.FirstName
+	}
+	return c.Username
+}
+
+// TGFile is any file attached to a message: a photo size, document, voice
+// note and so on.
+type TGFile struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	MimeType     string `json:"mime_type,omitempty"`
+}
+
+type TGMessage struct {
+	MessageID      int        `json:"message_id"`
+	From           *TGUser    `json:"from,omitempty"`
+	Chat           TGChat     `json:"chat"`
+	Date           int64      `json:"date"` // unix seconds
+	Text           string     `json:"text,omitempty"`
+	Caption        string     `json:"caption,omitempty"`
+	ReplyToMessage *TGMessage `json:"reply_to_message,omitempty"`
+
+	Photo    []TGFile `json:"photo,omitempty"`
+	Document *TGFile  `json:"document,omitempty"`
+	Video    *TGFile  `json:"video,omitempty"`
+	Voice    *TGFile  `json:"voice,omitempty"`
+	Audio    *TGFile  `json:"audio,omitempty"`
+	Sticker  *TGFile  `json:"sticker,omitempty"`
+}
+
+func (m TGMessage) HasMedia() bool {
+	return len(m.Photo) > 0 || m.Document != nil || m.Video != nil || m.Voice != nil || m.Audio != nil || m.Sticker != nil
+}
+
+type TGUpdate struct {
+	UpdateID      int64      `json:"update_id"`
+	Message       *TGMessage `json:"message,omitempty"`
+	EditedMessage *TGMessage `json:"edited_message,omitempty"`
+}
+
+type TGReplyParameters struct {
+	MessageID                int  `json:"message_id"`
+	AllowSendingWithoutReply bool `json:"allow_sending_without_reply,omitempty"`
+}
+
+type TGSendMessageRequest struct {
+	ChatID          int64              `json:"chat_id"`
+	Text            string             `json:"text"`
+	ReplyParameters *TGReplyParameters `json:"reply_parameters,omitempty"`
+}
+
+type TGGetUpdatesRequest struct {
+	Offset         int64    `json:"offset,omitempty"`
+	Timeout        int      `json:"timeout,omitempty"` // long polling, in seconds
+	AllowedUpdates []string `json:"allowed_updates,omitempty"`
+}
+
+type TGChatActionRequest struct {
+	ChatID int64  `json:"chat_id"`
+	Action string `json:"action"` // e.g. "typing"
+}
+
+type TGGetChatRequest struct {
+	ChatID int64 `json:"chat_id"`
+}
@@ -1,6 +1,9 @@
 package connections
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"strings"
+)
 
 type SessionInfo struct {
 	Name   string         `json:"name"`
@@ -37,6 +40,7 @@ type FileWrapper struct {
 type ImagePayload struct {
 	Caption string
 	File    FileWrapper
+	ReplyTo string
 }
 
 type MessageImageRequest struct {
@@ -47,6 +51,123 @@ type MessageImageRequest struct {
 	ReplyTo string      `json:"reply_to,omitempty"`
 }
 
+type VideoPayload struct {
+	Caption string
+	File    FileWrapper
+	AsNote  bool
+	Convert bool
+	ReplyTo string
+}
+
+type MessageVideoRequest struct {
+	ChatID  string      `json:"chatId"`
+	Session string      `json:"session"`
+	File    FileWrapper `json:"file"`
+	Caption string      `json:"caption,omitempty"`
+	AsNote  bool        `json:"asNote,omitempty"` // round video note
+	Convert bool        `json:"convert,omitempty"`
+	ReplyTo string      `json:"reply_to,omitempty"`
+}
+
+type VoicePayload struct {
+	File    FileWrapper
+	Convert bool
+	ReplyTo string
+}
+
+type MessageVoiceRequest struct {
+	ChatID  string      `json:"chatId"`
+	Session string      `json:"session"`
+	File    FileWrapper `json:"file"`
+	Convert bool        `json:"convert,omitempty"` // let WAHA transcode to opus
+	ReplyTo string      `json:"reply_to,omitempty"`
+}
+
+type FilePayload struct {
+	Caption string
+	File    FileWrapper
+	ReplyTo string
+}
+
+type MessageFileRequest struct {
+	ChatID  string      `json:"chatId"`
+	Session string      `json:"session"`
+	File    FileWrapper `json:"file"`
+	Caption string      `json:"caption,omitempty"`
+	ReplyTo string      `json:"reply_to,omitempty"`
+}
+
+type LocationPayload struct {
+	Latitude  float64
+	Longitude float64
+	Title     string
+	ReplyTo   string
+}
+
+type MessageLocationRequest struct {
+	ChatID    string  `json:"chatId"`
+	Session   string  `json:"session"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Title     string  `json:"title,omitempty"`
+	ReplyTo   string  `json:"reply_to,omitempty"`
+}
+
+type ContactVcard struct {
+	FullName     string `json:"fullName,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	PhoneNumber  string `json:"phoneNumber,omitempty"`
+	WhatsAppID   string `json:"whatsappId,omitempty"`
+	Vcard        string `json:"vcard,omitempty"` // raw vCard, overrides the fields above
+}
+
+type MessageContactVcardRequest struct {
+	ChatID   string         `json:"chatId"`
+	Session  string         `json:"session"`
+	Contacts []ContactVcard `json:"contacts"`
+	ReplyTo  string         `json:"reply_to,omitempty"`
+}
+
+type PollOptions struct {
+	Name            string   `json:"name"`
+	Options         []string `json:"options"`
+	MultipleAnswers bool     `json:"multipleAnswers"`
+}
+
+type PollPayload struct {
+	Poll    PollOptions
+	ReplyTo string
+}
+
+type MessagePollRequest struct {
+	ChatID  string      `json:"chatId"`
+	Session string      `json:"session"`
+	Poll    PollOptions `json:"poll"`
+	ReplyTo string      `json:"reply_to,omitempty"`
+}
+
+type MessageReactionRequest struct {
+	MessageID string `json:"messageId"`
+	Reaction  string `json:"reaction"` // emoji, "" removes the reaction
+	Session   string `json:"session"`
+}
+
+type MessageEditRequest struct {
+	Text        string `json:"text"`
+	LinkPreview bool   `json:"linkPreview"`
+}
+
+type ChatActionRequest struct {
+	ChatID  string `json:"chatId"`
+	Session string `json:"session"`
+}
+
+type SendSeenRequest struct {
+	ChatID     string   `json:"chatId"`
+	Session    string   `json:"session"`
+	MessageIDs []string `json:"messageIds,omitempty"`
+}
+
 type WAMessageID string
 
 func (w *WAMessageID) UnmarshalJSON(data []byte) error {
@@ -80,6 +201,16 @@ func (w WAMessageID) String() string {
 	return string(w)
 }
 
+// ChatID returns the chat of a serialized id like "true_123@c.us_ABC", or
+// "" if the id has another form.
+func (w WAMessageID) ChatID() string {
+	parts := strings.SplitN(string(w), "_", 3)
+	if len(parts) < 3 || (parts[0] != "true" && parts[0] != "false") {
+		return ""
+	}
+	return parts[1]
+}
+
 type WAMessage struct {
 	ID        WAMessageID            `json:"id"`
 	Timestamp int64                  `json:"timestamp"`
@@ -92,19 +223,67 @@ type WAMessage struct {
 	Ack       int                    `json:"ack"`
 	AckName   string                 `json:"ackName"`
 	Type      string                 `json:"type"` // e.g. "chat", "image", "video"
+	Media     *WAMedia               `json:"media,omitempty"`
 	Data      map[string]interface{} `json:"_data,omitempty"`
 }
 
+// WAMedia points at a file WAHA has downloaded for a message with HasMedia.
+type WAMedia struct {
+	URL      string `json:"url"`
+	Mimetype string `json:"mimetype"`
+	Filename string `json:"filename,omitempty"`
+	Error    any    `json:"error,omitempty"`
+}
+
 type WANumberExistResult struct {
 	ChatID       string `json:"chatId,omitempty"`
 	NumberExists bool   `json:"numberExists"`
 }
 
+type WAContact struct {
+	ID          string `json:"id"` // e.g. "123456789@c.us"
+	Number      string `json:"number"`
+	Name        string `json:"name"`     // as saved in the address book
+	PushName    string `json:"pushname"` // as set by the contact
+	ShortName   string `json:"shortName"`
+	IsMe        bool   `json:"isMe"`
+	IsGroup     bool   `json:"isGroup"`
+	IsWAContact bool   `json:"isWAContact"`
+	IsMyContact bool   `json:"isMyContact"`
+	IsBlocked   bool   `json:"isBlocked"`
+}
+
+type ContactAbout struct {
+	About *string `json:"about"` // nil when hidden by privacy settings
+}
+
+type ContactProfilePicture struct {
+	ProfilePictureURL *string `json:"profilePictureURL"`
+}
+
+type ContactActionRequest struct {
+	ContactID string `json:"contactId"`
+	Session   string `json:"session"`
+}
+
 type RequestCodeRequest struct {
 	PhoneNumber string `json:"phoneNumber"`
 	Method      string `json:"method,omitempty"`
 }
 
+const (
+	QRFormatImage = "image" // the QR rendered as an image
+	QRFormatRaw   = "raw"   // the value encoded in the QR
+)
+
+// QRCode is the JSON form of the pairing QR: Value for QRFormatRaw,
+// Mimetype and base64 Data for QRFormatImage.
+type QRCode struct {
+	Value    string `json:"value,omitempty"`
+	Mimetype string `json:"mimetype,omitempty"`
+	Data     string `json:"data,omitempty"`
+}
+
 type RequestCodeResponse struct {
 	Code string `json:"code,omitempty"`
 }
@@ -116,17 +295,243 @@ type ChatSummary struct {
 	LastMessage *WAMessage `json:"lastMessage"`
 }
 
+type ChatMessagesQuery struct {
+	Limit         int
+	Offset        int
+	Since         int64 // unix seconds, 0 for no lower bound
+	DownloadMedia bool
+}
+
 type GroupInfo struct {
-	ID      string `json:"id"`
+	ID           string             `json:"id"`
+	Subject      string             `json:"subject"`
+	Description  string             `json:"desc,omitempty"`
+	Owner        string             `json:"owner,omitempty"`
+	Creation     int64              `json:"creation,omitempty"`
+	Participants []GroupParticipant `json:"participants,omitempty"`
+	Announce     bool               `json:"announce"` // only admins can send messages
+	Restrict     bool               `json:"restrict"` // only admins can edit group info
+}
+
+// Admins returns the ids of the participants that are admins or the owner.
+func (g GroupInfo) Admins() []string {
+	admins := []string{}
+	for _, p := range g.Participants {
+		if p.IsAdmin() {
+			admins = append(admins, p.ID)
+		}
+	}
+	return admins
+}
+
+type GroupParticipant struct {
+	ID    string `json:"id"`
+	Admin string `json:"admin,omitempty"` // "", "admin" or "superadmin"
+}
+
+func (p GroupParticipant) IsAdmin() bool {
+	return p.Admin == "admin" || p.Admin == "superadmin"
+}
+
+type GroupParticipantID struct {
+	ID string `json:"id"`
+}
+
+type GroupCreateRequest struct {
+	Name         string               `json:"name"`
+	Participants []GroupParticipantID `json:"participants"`
+}
+
+type GroupParticipantsRequest struct {
+	Participants []GroupParticipantID `json:"participants"`
+}
+
+type GroupSubjectRequest struct {
 	Subject string `json:"subject"`
 }
 
+type GroupDescriptionRequest struct {
+	Description string `json:"description"`
+}
+
+type GroupPictureRequest struct {
+	File FileWrapper `json:"file"`
+}
+
+// Roles of the session's account in a channel.
+const (
+	ChannelRoleOwner      = "OWNER"
+	ChannelRoleAdmin      = "ADMIN"
+	ChannelRoleSubscriber = "SUBSCRIBER"
+	ChannelRoleGuest      = "GUEST"
+)
+
+// Channel is a WhatsApp Channel, called newsletter in its "@newsletter" id.
+type Channel struct {
+	ID               string `json:"id"` // e.g. "123@newsletter"
+	Name             string `json:"name"`
+	Description      string `json:"description,omitempty"`
+	Invite           string `json:"invite,omitempty"` // https://whatsapp.com/channel/<code>
+	Picture          string `json:"picture,omitempty"`
+	Preview          string `json:"preview,omitempty"`
+	Verified         bool   `json:"verified"`
+	Role             string `json:"role"`
+	SubscribersCount int    `json:"subscribersCount"`
+}
+
+// CanPost reports whether the session may publish to the channel.
+func (c Channel) CanPost() bool {
+	return c.Role == ChannelRoleOwner || c.Role == ChannelRoleAdmin
+}
+
+type ChannelCreateRequest struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Picture     *FileWrapper `json:"picture,omitempty"`
+}
+
+// StatusBroadcastID is the chat WhatsApp Status updates are sent to.
+const StatusBroadcastID = "status@broadcast"
+
+// StatusTextRequest posts a text status. ID, from GET status/new-message-id,
+// is what the status can later be deleted by.
+type StatusTextRequest struct {
+	ID              string   `json:"id,omitempty"`
+	Contacts        []string `json:"contacts,omitempty"` // empty: all contacts
+	Text            string   `json:"text"`
+	BackgroundColor string   `json:"backgroundColor"` // e.g. "#38b42f"
+	Font            int      `json:"font"`
+	LinkPreview     bool     `json:"linkPreview"`
+}
+
+// StatusMediaRequest posts an image or video status.
+type StatusMediaRequest struct {
+	ID       string      `json:"id,omitempty"`
+	Contacts []string    `json:"contacts,omitempty"`
+	File     FileWrapper `json:"file"`
+	Caption  string      `json:"caption,omitempty"`
+	Convert  bool        `json:"convert,omitempty"` // videos only
+}
+
+type StatusDeleteRequest struct {
+	ID       string   `json:"id"`
+	Contacts []string `json:"contacts,omitempty"`
+}
+
+type StatusMessageID struct {
+	ID string `json:"id"`
+}
+
 type WAHAWebhook struct {
-	Event   string          `json:"event"`
-	Session string          `json:"session"`
-	Payload json.RawMessage `json:"payload"`
+	ID        string          `json:"id"`
+	Timestamp int64           `json:"timestamp"` // unix millis
+	Event     string          `json:"event"`
+	Session   string          `json:"session"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// PollVotePayload is the payload of a poll.vote webhook event.
+type PollVotePayload struct {
+	Vote PollVote       `json:"vote"`
+	Poll PollMessageRef `json:"poll"`
+}
+
+type PollVote struct {
+	ID              WAMessageID `json:"id"`
+	SelectedOptions []string    `json:"selectedOptions"`
+	Timestamp       int64       `json:"timestamp"`
+	From            string      `json:"from"`
+	FromMe          bool        `json:"fromMe"`
+	To              string      `json:"to"`
+	Participant     string      `json:"participant,omitempty"` // voter in groups
+}
+
+type PollMessageRef struct {
+	ID     WAMessageID `json:"id"`
+	To     string      `json:"to"`
+	From   string      `json:"from"`
+	FromMe bool        `json:"fromMe"`
 }
 
 type SessionStatusPayload struct {
 	Status string `json:"status"`
 }
+
+// MessageAckPayload is the payload of a message.ack webhook event: a
+// message we sent was delivered, read or played.
+type MessageAckPayload struct {
+	ID          WAMessageID `json:"id"`
+	From        string      `json:"from"`
+	To          string      `json:"to"`
+	Participant string      `json:"participant,omitempty"` // reader in groups
+	FromMe      bool        `json:"fromMe"`
+	Ack         int         `json:"ack"`     // -1 error, 0 pending, 1 server, 2 device, 3 read, 4 played
+	AckName     string      `json:"ackName"` // ERROR, PENDING, SERVER, DEVICE, READ, PLAYED
+}
+
+// MessageReactionPayload is the payload of a message.reaction webhook
+// event. An empty Reaction.Text removes the reaction.
+type MessageReactionPayload struct {
+	ID          WAMessageID `json:"id"`
+	Timestamp   int64       `json:"timestamp"`
+	From        string      `json:"from"`
+	FromMe      bool        `json:"fromMe"`
+	To          string      `json:"to"`
+	Participant string      `json:"participant,omitempty"`
+	Reaction    Reaction    `json:"reaction"`
+}
+
+type Reaction struct {
+	Text      string      `json:"text"`
+	MessageID WAMessageID `json:"messageId"`
+}
+
+// MessageRevokedPayload is the payload of a message.revoked webhook event.
+// Before is only set when WAHA still had the original message.
+type MessageRevokedPayload struct {
+	RevokedMessageID string     `json:"revokedMessageId"`
+	Before           *WAMessage `json:"before,omitempty"`
+	After            *WAMessage `json:"after,omitempty"`
+}
+
+// GroupNotificationPayload is the payload of the group.join and
+// group.leave webhook events.
+type GroupNotificationPayload struct {
+	ID           WAMessageID `json:"id"`
+	ChatID       string      `json:"chatId"`
+	Author       string      `json:"author"`       // who added or removed, if anyone
+	RecipientIDs []string    `json:"recipientIds"` // who joined or left
+	Type         string      `json:"type"`         // add, invite, remove, leave, ...
+	Body         string      `json:"body"`
+	Timestamp    int64       `json:"timestamp"`
+}
+
+// PresenceUpdatePayload is the payload of a presence.update webhook event,
+// sent for chats subscribed to with the presence API.
+type PresenceUpdatePayload struct {
+	ID        string     `json:"id"` // chat id
+	Presences []Presence `json:"presences"`
+}
+
+type Presence struct {
+	Participant       string `json:"participant"`
+	LastKnownPresence string `json:"lastKnownPresence"` // offline, online, typing, recording, paused
+	LastSeen          *int64 `json:"lastSeen,omitempty"`
+}
+
+// CallPayload is the payload of a call.received webhook event.
+type CallPayload struct {
+	ID        string `json:"id"`
+	From      string `json:"from"`
+	Timestamp int64  `json:"timestamp"`
+	IsVideo   bool   `json:"isVideo"`
+	IsGroup   bool   `json:"isGroup"`
+}
+
+// Label is the payload of a label.upsert webhook event.
+type Label struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Color    int    `json:"color"`
+	ColorHex string `json:"colorHex"`
+}
@@ -0,0 +1,23 @@
+package connections
+
+import "testing"
+
+func TestWAMessageIDChatID(t *testing.T) {
+	tests := []struct {
+		id   WAMessageID
+		want string
+	}{
+		{"true_15552223333@c.us_3EB0C767D26A", "15552223333@c.us"},
+		{"false_120363000000000000@g.us_3EB0C767D26A_15554445555@c.us", "120363000000000000@g.us"},
+		{"true_status@broadcast_STATUS000001", "status@broadcast"},
+		{"3EB0C767D26A", ""},
+		{"maybe_15552223333@c.us_3EB0C767D26A", ""},
+		{"true_15552223333@c.us", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.id.ChatID(); got != tt.want {
+			t.Errorf("%q.ChatID() = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Contact is a WhatsApp contact of a session, cached from WAHA.
+type Contact struct {
+	gorm.Model
+
+	SessionName string `gorm:"uniqueIndex:idx_contact_session_contact;not null"`
+	ContactID   string `gorm:"uniqueIndex:idx_contact_session_contact;not null"` // e.g. 123@c.us
+	Number      string `gorm:"index"`
+	Name        string
+	PushName    string
+	IsMyContact bool
+	IsBlocked   bool
+	About       string
+	PictureURL  string
+	SyncedAt    time.Time  // last time the contact list was fetched
+	DetailsAt   *time.Time // last time about and picture were fetched
+}
+
+// NumberCheck is WAHA's answer to whether a phone number is on WhatsApp.
+type NumberCheck struct {
+	Phone     string
+	Exists    bool
+	ChatID    string
+	CheckedAt time.Time
+}
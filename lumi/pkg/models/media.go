@@ -0,0 +1,17 @@
+package models
+
+import "gorm.io/gorm"
+
+// MediaFile is an incoming attachment downloaded from WAHA and kept in
+// storage.
+type MediaFile struct {
+	gorm.Model
+
+	SessionName string `gorm:"index;not null"`
+	ChatID      string `gorm:"index;not null"`
+	MessageID   string `gorm:"uniqueIndex;not null"` // WhatsApp message id
+	Mimetype    string
+	Filename    string
+	Size        int64
+	StorageKey  string `gorm:"not null"`
+}
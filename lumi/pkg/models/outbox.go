@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OutboxStatusPending = "pending" // never attempted
+	OutboxStatusFailed  = "failed"  // attempted, will be retried
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead" // gave up after too many attempts
+)
+
+// OutboxMessage is an outbound WhatsApp message waiting to be delivered by
+// the outbox worker.
+type OutboxMessage struct {
+	gorm.Model
+
+	SessionName   string    `gorm:"index;not null"`
+	ChatID        string    `gorm:"index;not null"`
+	Kind          string    `gorm:"not null"` // "text", "image", "video", ...
+	Text          string    // text or caption, kept for listing and history
+	Payload       string    `gorm:"type:text;not null"` // JSON send request
+	RecordHistory bool      // save Text as a "model" ChatMessage once sent
+	Status        string    `gorm:"index;not null;default:'pending'"`
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"index"`
+	LastError     string
+	WAMessageID   string
+	SentAt        *time.Time
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Poll is a WhatsApp poll sent through the outbox. Its WhatsApp message id
+// is the one the outbox records once the poll is delivered.
+type Poll struct {
+	gorm.Model
+
+	SessionName     string   `gorm:"index;not null"`
+	ChatID          string   `gorm:"index;not null"`
+	OutboxMessageID uint     `gorm:"index"`
+	Question        string   `gorm:"not null"`
+	Options         []string `gorm:"serializer:json;type:text;not null"`
+	MultipleAnswers bool
+	Votes           []PollVote
+}
+
+// PollVote is the latest choice of one voter; voting again replaces it.
+type PollVote struct {
+	gorm.Model
+
+	PollID          uint     `gorm:"uniqueIndex:idx_poll_vote_voter;not null"`
+	Voter           string   `gorm:"uniqueIndex:idx_poll_vote_voter;not null"` // e.g. 123@c.us
+	SelectedOptions []string `gorm:"serializer:json;type:text"`                // empty when the vote was withdrawn
+	VotedAt         time.Time
+}
+
+// PendingPollVote is a vote that arrived before the outbox recorded the
+// WhatsApp id of its poll. It is moved to PollVote once the poll is known.
+type PendingPollVote struct {
+	gorm.Model
+
+	SessionName     string   `gorm:"uniqueIndex:idx_pending_poll_vote_voter;not null"`
+	PollMessageID   string   `gorm:"uniqueIndex:idx_pending_poll_vote_voter;not null"` // WhatsApp id of the poll
+	ChatID          string   `gorm:"index"`
+	Voter           string   `gorm:"uniqueIndex:idx_pending_poll_vote_voter;not null"`
+	SelectedOptions []string `gorm:"serializer:json;type:text"`
+	VotedAt         time.Time
+}
+
+// PollTally counts the votes of a poll per option, in option order.
+type PollTally struct {
+	Poll   Poll
+	Counts []PollOptionCount
+	Voters int
+}
+
+type PollOptionCount struct {
+	Option string
+	Votes  int
+}
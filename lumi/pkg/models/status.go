@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusKindText  = "text"
+	StatusKindImage = "image"
+	StatusKindVideo = "video"
+)
+
+// States of a StatusPost.
+const (
+	StatusPostScheduled = "scheduled" // waiting for ScheduledAt, or for a retry
+	StatusPostPosted    = "posted"
+	StatusPostFailed    = "failed"  // gave up after too many attempts
+	StatusPostDeleted   = "deleted" // removed from WhatsApp, or cancelled before posting
+)
+
+// StatusPost is a WhatsApp Status (story) Lumi posts at ScheduledAt.
+type StatusPost struct {
+	gorm.Model
+
+	SessionName     string                 `gorm:"index;not null"`
+	Kind            string                 `gorm:"not null"`
+	Text            string                 `gorm:"type:text"` // text, or the caption of media
+	BackgroundColor string                 // text statuses only
+	Font            int                    // text statuses only
+	Media           *connModel.FileWrapper `gorm:"serializer:json;type:text"`
+	ScheduledAt     time.Time              `gorm:"index;not null"`
+	State           string                 `gorm:"index;not null;default:'scheduled'"`
+	Attempts        int                    `gorm:"not null;default:0"`
+	NextAttemptAt   time.Time              `gorm:"index"`
+	LastError       string
+	MessageID       string `gorm:"index"` // WhatsApp id, reserved before the first attempt
+	PostedAt        *time.Time
+	RemovedAt       *time.Time
+	Views           []StatusView
+}
+
+// StatusView is the first time a contact viewed a status.
+type StatusView struct {
+	gorm.Model
+
+	StatusPostID uint   `gorm:"uniqueIndex:idx_status_view_viewer;not null"`
+	Viewer       string `gorm:"uniqueIndex:idx_status_view_viewer;not null"` // e.g. 123@c.us
+	ViewedAt     time.Time
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ProcessedWebhook marks a webhook event or WhatsApp message as handled, so
+// a redelivery of it is skipped until ExpiresAt.
+type ProcessedWebhook struct {
+	Key       string    `gorm:"primaryKey"` // "event:<id>" or "message:<session>:<id>"
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// DedupStats counts a session's webhook deliveries dropped as duplicates
+// since Since.
+type DedupStats struct {
+	Since             time.Time
+	Events            int64
+	DuplicateEvents   int64
+	DuplicateMessages int64
+}
+
+// QueueStats describes the load on the bot's message queue. Waited counts
+// messages that had to wait for room, AvgWait how long on average.
+type QueueStats struct {
+	Workers   int
+	Capacity  int
+	Depth     int64
+	Enqueued  int64
+	Processed int64
+	Waited    int64
+	AvgWait   time.Duration
+	Dropped   int64
+}
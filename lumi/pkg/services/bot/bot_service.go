@@ -2,26 +2,60 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/models"
 	modelConnections "github.com/Mahaveer86619/lumi/pkg/models/connections"
 	"github.com/Mahaveer86619/lumi/pkg/services"
-	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
 	"google.golang.org/genai"
 )
 
-const SessionTimeout = 5 * time.Minute
+const (
+	SessionTimeout = 5 * time.Minute
+
+	// Messages are handled by queueWorkers workers, with up to
+	// queueCapacity messages waiting across all chats.
+	queueWorkers  = 8
+	queueCapacity = 256
+)
 
 type BotService struct {
-	botClient   *genai.Client
-	wahaClient  connections.WahaClient
-	chatService *services.ChatService
+	botClient      *genai.Client
+	sessionService *services.SessionService
+	chatService    *services.ChatService
+	historyService *services.HistoryService
+	mediaService   *services.MediaService
+	outboxService  *services.OutboxService
+	pollService    *services.PollService
+	queue          *Queue
+
+	// lastMessage tracks the latest incoming message id per chat, keyed by
+	// chatKey, so a reply can tell whether the conversation moved on while
+	// Gemini was thinking.
+	lastMessage   map[string]string
+	lastMessageMu sync.Mutex
+}
+
+// conversation is where a message came in, and so where Lumi answers.
+type conversation struct {
+	messenger messenger.Messenger
+
+	// session is the WAHA session the chat is registered on, which keeps
+	// the chat's history.
+	session string
+
+	// outbox is set for WhatsApp conversations, whose answers go through
+	// the session's outbox; other platforms are answered directly.
+	outbox bool
 }
 
-func NewBotService(wahaClient connections.WahaClient, chatService *services.ChatService) *BotService {
+func NewBotService(sessionService *services.SessionService, chatService *services.ChatService, historyService *services.HistoryService, mediaService *services.MediaService, outboxService *services.OutboxService, pollService *services.PollService) *BotService {
 	client, err := genai.NewClient(
 		context.Background(),
 		&genai.ClientConfig{
@@ -33,41 +67,153 @@ func NewBotService(wahaClient connections.WahaClient, chatService *services.Chat
 	}
 
 	return &BotService{
-		wahaClient:  wahaClient,
-		botClient:   client,
-		chatService: chatService,
+		botClient:      client,
+		sessionService: sessionService,
+		chatService:    chatService,
+		historyService: historyService,
+		mediaService:   mediaService,
+		outboxService:  outboxService,
+		pollService:    pollService,
+		queue:          NewQueue(queueWorkers, queueCapacity),
+		lastMessage:    make(map[string]string),
+	}
+}
+
+// Start starts the workers that process queued messages.
+func (b *BotService) Start() {
+	b.queue.Start()
+}
+
+// Enqueue queues msg for ProcessMessage. Messages of one chat are handled
+// in order, one at a time; ctx bounds how long to wait when the queue is
+// full. skipped, if set, is called when a shutdown drops msg unhandled.
+func (b *BotService) Enqueue(ctx context.Context, sessionName string, msg modelConnections.WAMessage, skipped func()) error {
+	key := chatKey(messenger.PlatformWhatsApp, sessionName, messenger.FromWAMessage(msg).ChatID)
+
+	// Tracked on arrival rather than when processed, so a reply can see
+	// that newer messages are waiting behind its question.
+	if !(msg.FromMe && msg.Source == "api") {
+		b.trackMessage(key, msg.ID.String())
 	}
+
+	return b.queue.Enqueue(ctx, key, func(ctx context.Context) {
+		b.ProcessMessage(ctx, sessionName, msg)
+	}, skipped)
+}
+
+// EnqueueMessage queues msg, received through m, for Process; it is
+// Enqueue for platforms other than WhatsApp.
+func (b *BotService) EnqueueMessage(ctx context.Context, m messenger.Messenger, msg messenger.Message) error {
+	key := chatKey(m.Platform(), "", msg.ChatID)
+	b.trackMessage(key, msg.ID)
+
+	return b.queue.Enqueue(ctx, key, func(ctx context.Context) {
+		b.Process(ctx, m, msg)
+	}, nil)
 }
 
-func (b *BotService) ProcessMessage(msg modelConnections.WAMessage) {
+// Shutdown stops taking messages and waits for the queued ones, at most
+// until ctx is done.
+func (b *BotService) Shutdown(ctx context.Context) error {
+	return b.queue.Shutdown(ctx)
+}
+
+func (b *BotService) QueueStats() models.QueueStats {
+	return b.queue.Stats()
+}
+
+// ProcessMessage handles an incoming message received on the given WAHA
+// session. Replies are queued in the outbox for the same session.
+func (b *BotService) ProcessMessage(ctx context.Context, sessionName string, msg modelConnections.WAMessage) {
 	if msg.FromMe && msg.Source == "api" {
 		return
 	}
 
-	chatID := msg.From
-	if msg.FromMe {
-		chatID = msg.To
-	}
+	m := messenger.FromWAMessage(msg)
+	chatID := m.ChatID
 
+	// Statuses aren't conversations; views of Lumi's own statuses arrive as
+	// message.ack and are recorded by the StatusService.
 	if strings.Contains(chatID, "status") || strings.Contains(chatID, "broadcast") {
 		return
 	}
 
 	text := strings.TrimSpace(msg.Body)
-	if text == "" {
+	if text == "" && !msg.HasMedia {
 		return
 	}
 
-	if msg.From == msg.To && !b.chatService.IsChatAllowed(chatID) {
+	if msg.From == msg.To && !b.chatService.IsChatAllowed(sessionName, chatID) {
 		log.Printf("Auto-registering self-chat: %s", chatID)
-		b.chatService.RegisterChat(chatID, "Me (Self)", "self")
+		b.chatService.RegisterChat(sessionName, messenger.PlatformWhatsApp, chatID, "Me (Self)", "self")
 	}
 
-	if !b.chatService.IsChatAllowed(chatID) {
+	if !b.chatService.IsChatAllowed(sessionName, chatID) {
 		return
 	}
 
-	chat, err := b.chatService.GetRegisteredChat(chatID)
+	if msg.HasMedia {
+		b.storeMedia(ctx, sessionName, chatID, msg)
+	}
+
+	if text == "" {
+		return
+	}
+
+	m.Text = text
+	b.handle(ctx, b.whatsApp(sessionName), m)
+}
+
+// Process handles a message received through m on a platform other than
+// WhatsApp. Replies are sent through m right away.
+func (b *BotService) Process(ctx context.Context, m messenger.Messenger, msg messenger.Message) {
+	if msg.FromMe {
+		return
+	}
+
+	msg.Text = strings.TrimSpace(msg.Text)
+	if msg.Text == "" {
+		return
+	}
+
+	// The bot is shared by every user; the chat's registration tells
+	// whose it is.
+	chat, err := b.chatService.FindPlatformChat(m.Platform(), msg.ChatID)
+	if err != nil {
+		return
+	}
+
+	b.handle(ctx, conversation{messenger: m, session: chat.SessionName}, msg)
+}
+
+// chatKey identifies a chat in the message queue and lastMessage. Two
+// users' WhatsApp sessions can share a chat, so WhatsApp chats are keyed by
+// session too; other platforms have one bot and pass no session.
+func chatKey(platform, sessionName, chatID string) string {
+	return platform + "/" + sessionName + "/" + chatID
+}
+
+// chatKey returns the chatKey of chatID as seen from this conversation.
+func (c conversation) chatKey(chatID string) string {
+	if c.outbox {
+		return chatKey(messenger.PlatformWhatsApp, c.session, chatID)
+	}
+	return chatKey(c.messenger.Platform(), "", chatID)
+}
+
+func (b *BotService) whatsApp(sessionName string) conversation {
+	return conversation{
+		messenger: messenger.NewWaha(b.sessionService.Client(sessionName)),
+		session:   sessionName,
+		outbox:    true,
+	}
+}
+
+// handle runs Lumi's thread logic for a text message of a registered chat.
+func (b *BotService) handle(ctx context.Context, conv conversation, msg messenger.Message) {
+	chatID, text := msg.ChatID, msg.Text
+
+	chat, err := b.chatService.GetRegisteredChat(conv.session, chatID)
 	if err != nil {
 		log.Printf("Error loading registered chat: %s; error: %s", chatID, err.Error())
 		return
@@ -77,8 +223,8 @@ func (b *BotService) ProcessMessage(msg modelConnections.WAMessage) {
 		log.Printf("Session timed out for %s", chatID)
 		chat.IsBotActive = false
 		b.chatService.UpdateRegisteredChat(chat)
-		b.chatService.ClearHistory(chatID)
-		b.wahaClient.SendText(chatID, "💤 LumiThread timed out due to inactivity.")
+		b.chatService.ClearHistory(conv.session, chatID)
+		b.notify(ctx, conv, chatID, "💤 LumiThread timed out due to inactivity.")
 	}
 
 	triggerKeyword := "@lumi"
@@ -86,27 +232,46 @@ func (b *BotService) ProcessMessage(msg modelConnections.WAMessage) {
 	isTrigger := strings.Contains(lowerText, triggerKeyword)
 	isExit := strings.Contains(lowerText, "bye") || strings.Contains(lowerText, "exit") || strings.Contains(lowerText, "stop")
 
+	if isTrigger && strings.Contains(lowerText, "poll result") {
+		b.announcePollResults(ctx, conv, chatID)
+		return
+	}
+
 	if chat.IsBotActive && isExit {
 		chat.IsBotActive = false
 		b.chatService.UpdateRegisteredChat(chat)
-		b.chatService.ClearHistory(chatID)
-		b.wahaClient.SendText(chatID, "LumiThread ended. Data cleared. 👋")
+		b.chatService.ClearHistory(conv.session, chatID)
+		b.notify(ctx, conv, chatID, "LumiThread ended. Data cleared. 👋")
 		return
 	}
 
 	if !chat.IsBotActive {
 		if isTrigger {
+			// The history is not cleared here: what was said before the
+			// thread started is the context it should see. Ending a thread
+			// clears it, and the sync does not bring cleared messages back.
 			chat.IsBotActive = true
 			b.chatService.UpdateRegisteredChat(chat)
-			b.chatService.ClearHistory(chatID)
 
 			cleanText := strings.TrimSpace(strings.ReplaceAll(text, triggerKeyword, ""))
 
 			if cleanText != "" {
-				b.chatService.SaveMessage(chatID, "user", cleanText)
-				b.generateAIResponse(chatID, cleanText)
+				b.saveUserMessage(conv, chatID, cleanText, msg)
+			}
+
+			// Pull in what was said before the thread started, so Lumi
+			// has the context of the conversation. Only WhatsApp keeps a
+			// history to pull from.
+			if conv.outbox {
+				if _, err := b.historyService.SyncChat(ctx, conv.session, chatID); err != nil {
+					log.Printf("Failed to backfill history for %s: %v", chatID, err)
+				}
+			}
+
+			if cleanText != "" {
+				b.generateAIResponse(ctx, conv, chat, msg, cleanText)
 			} else {
-				b.replyAndSave(chatID, "Hey! LumiThread started. 🧠\nI'm listening. Type *bye* to exit.")
+				b.replyAndSave(ctx, conv, chatID, "", "Hey! LumiThread started. 🧠\nI'm listening. Type *bye* to exit.")
 			}
 		}
 		return
@@ -114,18 +279,23 @@ func (b *BotService) ProcessMessage(msg modelConnections.WAMessage) {
 
 	b.chatService.UpdateRegisteredChat(chat)
 
-	b.chatService.SaveMessage(chatID, "user", text)
+	b.saveUserMessage(conv, chatID, text, msg)
 
 	cleanPrompt := text
 	if isTrigger {
 		cleanPrompt = strings.TrimSpace(strings.ReplaceAll(text, triggerKeyword, ""))
 	}
 
-	b.generateAIResponse(chatID, cleanPrompt)
+	b.generateAIResponse(ctx, conv, chat, msg, cleanPrompt)
 }
 
-func (b *BotService) generateAIResponse(chatID, currentText string) {
-	history, err := b.chatService.GetChatHistory(chatID, 10)
+func (b *BotService) generateAIResponse(ctx context.Context, conv conversation, chat *models.RegisteredChat, msg messenger.Message, currentText string) {
+	chatID := chat.ChatID
+
+	stopPresence := b.showPresence(ctx, conv, chat, msg.ID)
+	defer stopPresence()
+
+	history, err := b.chatService.GetChatHistory(conv.session, chatID, 10)
 	if err != nil {
 		log.Printf("Error fetching history: %v", err)
 	}
@@ -145,7 +315,7 @@ func (b *BotService) generateAIResponse(chatID, currentText string) {
 	sysPrompt := config.GConfig.WahaBotSystemPrompt
 
 	resp, err := b.botClient.Models.GenerateContent(
-		context.Background(),
+		ctx,
 		"gemini-2.5-flash",
 		parts,
 		&genai.GenerateContentConfig{
@@ -155,20 +325,157 @@ func (b *BotService) generateAIResponse(chatID, currentText string) {
 
 	if err != nil {
 		log.Printf("Gemini Error: %v", err)
-		b.replyAndSave(chatID, "⚠️ *Error*: My brain connection timed out.")
+		b.replyAndSave(ctx, conv, chatID, "", "⚠️ *Error*: My brain connection timed out.")
 		return
 	}
 
 	responseText := resp.Text()
-	b.replyAndSave(chatID, responseText)
+	b.replyAndSave(ctx, conv, chatID, b.quoteTarget(conv.chatKey(chatID), msg), responseText)
+}
+
+// announcePollResults posts the tally of the newest poll in the chat.
+func (b *BotService) announcePollResults(ctx context.Context, conv conversation, chatID string) {
+	tally, err := b.pollService.LatestTally(conv.session, chatID)
+	if errors.Is(err, services.ErrPollNotFound) {
+		b.notify(ctx, conv, chatID, "There is no poll in this chat yet. 🗳️")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load poll results for %s: %v", chatID, err)
+		return
+	}
+
+	if _, err := b.pollService.AnnounceResults(tally); err != nil {
+		log.Printf("Failed to announce poll results in %s: %v", chatID, err)
+	}
+}
+
+// saveUserMessage records an incoming message under its platform id, so a
+// later history backfill does not import it twice.
+func (b *BotService) saveUserMessage(conv conversation, chatID, text string, msg messenger.Message) {
+	if _, err := b.chatService.SaveWAMessage(conv.session, chatID, "user", text, msg.ID, msg.SentAt); err != nil {
+		log.Printf("Failed to save message from %s: %v", chatID, err)
+	}
+}
+
+// showPresence marks msgID as read and shows "typing…" in the chat until the
+// returned func is called, as far as the chat's settings allow.
+func (b *BotService) showPresence(ctx context.Context, conv conversation, chat *models.RegisteredChat, msgID string) func() {
+	m := conv.messenger
+
+	if chat.SendReadReceipts && msgID != "" {
+		if err := m.MarkRead(ctx, chat.ChatID, msgID); err != nil {
+			log.Printf("Failed to send read receipt to %s: %v", chat.ChatID, err)
+		}
+	}
+
+	if !chat.ShowTyping {
+		return func() {}
+	}
+
+	if err := m.StartTyping(ctx, chat.ChatID); err != nil {
+		log.Printf("Failed to start typing in %s: %v", chat.ChatID, err)
+		return func() {}
+	}
+
+	// The typing state is refreshed well before the platform drops it,
+	// for as long as Gemini is still generating.
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(m.TypingTTL() * 2 / 5)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.StartTyping(ctx, chat.ChatID)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		if err := m.StopTyping(ctx, chat.ChatID); err != nil {
+			log.Printf("Failed to stop typing in %s: %v", chat.ChatID, err)
+		}
+	}
 }
 
-func (b *BotService) replyAndSave(chatID, text string) {
-	_, err := b.wahaClient.SendText(chatID, text)
+// replyAndSave sends a bot answer and adds it to the chat history. On
+// WhatsApp it is queued, and saved once the outbox has delivered it.
+func (b *BotService) replyAndSave(ctx context.Context, conv conversation, chatID, replyTo, text string) {
+	if conv.outbox {
+		if _, err := b.outboxService.EnqueueText(conv.session, chatID, replyTo, text, true); err != nil {
+			log.Printf("Failed to queue message: %v", err)
+		}
+		return
+	}
+
+	sent, err := conv.messenger.SendText(ctx, chatID, replyTo, text)
+	if err != nil {
+		log.Printf("Failed to send message to %s: %v", chatID, err)
+		return
+	}
+	if _, err := b.chatService.SaveWAMessage(conv.session, chatID, "model", text, sent.ID, sent.SentAt); err != nil {
+		log.Printf("Failed to save reply to %s: %v", chatID, err)
+	}
+}
+
+// notify sends a status notice that is not part of the conversation.
+func (b *BotService) notify(ctx context.Context, conv conversation, chatID, text string) {
+	if conv.outbox {
+		if _, err := b.outboxService.EnqueueText(conv.session, chatID, "", text, false); err != nil {
+			log.Printf("Failed to queue notice: %v", err)
+		}
+		return
+	}
+
+	if _, err := conv.messenger.SendText(ctx, chatID, "", text); err != nil {
+		log.Printf("Failed to send notice to %s: %v", chatID, err)
+	}
+}
+
+func (b *BotService) storeMedia(ctx context.Context, sessionName, chatID string, msg modelConnections.WAMessage) {
+	if msg.Media == nil {
+		log.Printf("Message %s has media but WAHA sent no file; is media download enabled?", msg.ID)
+		return
+	}
+
+	file, err := b.mediaService.StoreMessageMedia(ctx, sessionName, chatID, msg)
+	if errors.Is(err, services.ErrMediaNotStorable) {
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to send message: %v", err)
+		log.Printf("Failed to store media of message %s: %v", msg.ID, err)
 		return
 	}
 
-	b.chatService.SaveMessage(chatID, "model", text)
+	log.Printf("Stored %s media for %s as %s", file.Mimetype, chatID, file.StorageKey)
+}
+
+func (b *BotService) trackMessage(key, msgID string) {
+	b.lastMessageMu.Lock()
+	defer b.lastMessageMu.Unlock()
+	b.lastMessage[key] = msgID
+}
+
+// quoteTarget returns the message a reply should quote. Lumi only quotes in
+// groups where someone else spoke after the question, i.e. where the answer
+// would otherwise not sit directly below it. key is the chat's chatKey.
+func (b *BotService) quoteTarget(key string, msg messenger.Message) string {
+	if !msg.IsGroup || msg.ID == "" {
+		return ""
+	}
+
+	b.lastMessageMu.Lock()
+	defer b.lastMessageMu.Unlock()
+
+	if b.lastMessage[key] == msg.ID {
+		return ""
+	}
+	return msg.ID
 }
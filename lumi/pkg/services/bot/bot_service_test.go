@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/db/dbtest"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	modelConnections "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections/wahatest"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
+	"github.com/Mahaveer86619/lumi/pkg/services/storage"
+)
+
+const (
+	me     = "15550001111@c.us"
+	friend = "15552223333@c.us"
+)
+
+// botTest is a BotService wired to a paired wahatest session, with its
+// outbox delivering until the test ends.
+type botTest struct {
+	bot           *BotService
+	chatService   *services.ChatService
+	mediaService  *services.MediaService
+	outboxService *services.OutboxService
+	srv           *wahatest.Server
+	sessionName   string
+}
+
+func newBotTest(t *testing.T) *botTest {
+	t.Helper()
+
+	dbtest.Open(t, &models.WhatsAppSession{}, &models.RegisteredChat{}, &models.ChatMessage{}, &models.MediaFile{}, &models.OutboxMessage{}, &models.Poll{}, &models.PollVote{}, &models.PendingPollVote{})
+
+	srv := wahatest.NewServer()
+	t.Cleanup(srv.Close)
+
+	previous := config.GConfig
+	config.GConfig = &config.Config{WahaServiceURL: srv.URL, WahaAPIKey: srv.APIKey, WahaSessionName: "lumi", GeminiAPIKey: "test"}
+	t.Cleanup(func() { config.GConfig = previous })
+
+	sessionService := services.NewSessionService()
+	session, err := sessionService.GetOrCreateUserSession(1)
+	if err != nil {
+		t.Fatalf("GetOrCreateUserSession: %v", err)
+	}
+	if err := sessionService.Client(session.WahaSessionName).StartSession(context.Background()); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if err := srv.Pair(session.WahaSessionName, modelConnections.MeInfo{ID: me}); err != nil {
+		t.Fatalf("Pair: %v", err)
+	}
+
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	chatService := services.NewChatService(sessionService)
+	mediaService := services.NewMediaService(store, sessionService)
+	historyService := services.NewHistoryService(sessionService, chatService)
+	outboxService := services.NewOutboxService(sessionService, chatService)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	outboxService.Start(ctx)
+
+	return &botTest{
+		bot:           NewBotService(sessionService, chatService, historyService, mediaService, outboxService, services.NewPollService(outboxService)),
+		chatService:   chatService,
+		mediaService:  mediaService,
+		outboxService: outboxService,
+		srv:           srv,
+		sessionName:   session.WahaSessionName,
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLumiThread(t *testing.T) {
+	bt := newBotTest(t)
+	bot, chatService, srv, sessionName := bt.bot, bt.chatService, bt.srv, bt.sessionName
+
+	if _, err := chatService.RegisterChat(sessionName, messenger.PlatformWhatsApp, friend, "Friend", "chat"); err != nil {
+		t.Fatalf("RegisterChat: %v", err)
+	}
+
+	steps := []struct {
+		body    string
+		reply   string // "" when nothing is sent
+		active  bool
+		history int
+	}{
+		{"hello", "", false, 0},
+		{"@lumi", "Hey! LumiThread started. 🧠\nI'm listening. Type *bye* to exit.", true, 1},
+		{"bye", "LumiThread ended. Data cleared. 👋", false, 0},
+	}
+
+	sent := 0
+	for _, step := range steps {
+		bot.ProcessMessage(context.Background(), sessionName, modelConnections.WAMessage{From: friend, To: me, Body: step.body})
+
+		if step.reply != "" {
+			sent++
+		}
+		waitFor(t, fmt.Sprintf("%d messages to %s", sent, friend), func() bool { return len(srv.SentTo(friend)) >= sent })
+		messages := srv.SentTo(friend)
+		if len(messages) != sent {
+			t.Fatalf("%q: %d messages sent, want %d", step.body, len(messages), sent)
+		}
+		if step.reply != "" && messages[sent-1].Text != step.reply {
+			t.Errorf("%q: replied %q, want %q", step.body, messages[sent-1].Text, step.reply)
+		}
+
+		chat, err := chatService.GetRegisteredChat(sessionName, friend)
+		if err != nil {
+			t.Fatalf("GetRegisteredChat: %v", err)
+		}
+		if chat.IsBotActive != step.active {
+			t.Errorf("%q: bot active = %v, want %v", step.body, chat.IsBotActive, step.active)
+		}
+		// A reply joins the history once the outbox has delivered it.
+		var history []models.ChatMessage
+		waitFor(t, fmt.Sprintf("%d history messages", step.history), func() bool {
+			history, _ = chatService.GetChatHistory(sessionName, friend, 10)
+			return len(history) == step.history
+		})
+	}
+}
+
+func TestUnregisteredChatsAreIgnored(t *testing.T) {
+	bt := newBotTest(t)
+
+	bt.bot.ProcessMessage(context.Background(), bt.sessionName, modelConnections.WAMessage{From: friend, To: me, Body: "@lumi"})
+
+	if queued, _ := bt.outboxService.ListSessionOutbox(bt.sessionName, ""); len(queued) != 0 {
+		t.Errorf("queued %+v for an unregistered chat", queued)
+	}
+}
+
+func TestIncomingMediaIsStored(t *testing.T) {
+	bt := newBotTest(t)
+
+	if _, err := bt.chatService.RegisterChat(bt.sessionName, messenger.PlatformWhatsApp, friend, "Friend", "chat"); err != nil {
+		t.Fatalf("RegisterChat: %v", err)
+	}
+
+	photo := bt.srv.AddMedia(bt.sessionName, "photo.jpg", "image/jpeg", []byte("jpeg"))
+	tests := []struct {
+		name   string
+		from   string
+		media  *modelConnections.WAMedia
+		stored int // files stored on the session afterwards
+	}{
+		{"media without a file", friend, nil, 0},
+		{"unregistered chat", "15559990000@c.us", &photo, 0},
+		{"registered chat", friend, &photo, 1},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := modelConnections.WAMessage{
+				ID:       modelConnections.WAMessageID(fmt.Sprintf("false_%s_%d", tt.from, i)),
+				From:     tt.from,
+				To:       me,
+				HasMedia: true,
+				Media:    tt.media,
+			}
+			bt.bot.ProcessMessage(context.Background(), bt.sessionName, msg)
+
+			files, err := bt.mediaService.ListSessionMedia(bt.sessionName, "")
+			if err != nil {
+				t.Fatalf("ListSessionMedia: %v", err)
+			}
+			if len(files) != tt.stored {
+				t.Errorf("%d files stored, want %d", len(files), tt.stored)
+			}
+		})
+	}
+
+	if queued, _ := bt.outboxService.ListSessionOutbox(bt.sessionName, ""); len(queued) != 0 {
+		t.Errorf("a media message without text was answered: %+v", queued)
+	}
+}
+
+func TestQuoteTarget(t *testing.T) {
+	const group = "120363000000000000@g.us"
+	const question = "false_" + group + "_AAA"
+
+	tests := []struct {
+		name      string
+		chatID    string
+		isGroup   bool
+		trackedIn string // session the latest message was seen in
+		last      string // latest message seen in the chat
+		msgID     string
+		want      string
+	}{
+		{"private chat", friend, false, "alice", "false_" + friend + "_BBB", "false_" + friend + "_AAA", ""},
+		{"group, answer right below", group, true, "alice", question, question, ""},
+		{"group, others spoke since", group, true, "alice", "false_" + group + "_CCC", question, question},
+		{"group, others spoke in another session", group, true, "bob", "false_" + group + "_CCC", question, ""},
+		{"group, no message to quote", group, true, "alice", question, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &BotService{lastMessage: make(map[string]string)}
+			b.trackMessage(chatKey(messenger.PlatformWhatsApp, "alice", tt.chatID), question)
+			b.trackMessage(chatKey(messenger.PlatformWhatsApp, tt.trackedIn, tt.chatID), tt.last)
+
+			msg := messenger.Message{ID: tt.msgID, ChatID: tt.chatID, IsGroup: tt.isGroup}
+			if got := b.quoteTarget(chatKey(messenger.PlatformWhatsApp, "alice", tt.chatID), msg); got != tt.want {
+				t.Errorf("quoteTarget = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShowPresence(t *testing.T) {
+	const question = modelConnections.WAMessageID("false_" + friend + "_AAA")
+
+	tests := []struct {
+		name     string
+		typing   bool
+		receipts bool
+		msgID    modelConnections.WAMessageID
+		want     []string // presence calls, in order
+	}{
+		{"everything", true, true, question, []string{"sendSeen", "startTyping", "stopTyping"}},
+		{"typing only", true, false, question, []string{"startTyping", "stopTyping"}},
+		{"receipts only", false, true, question, []string{"sendSeen"}},
+		{"nothing", false, false, question, nil},
+		{"no message to mark", false, true, "", nil},
+	}
+
+	bt := newBotTest(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat := &models.RegisteredChat{ChatID: friend, ShowTyping: tt.typing, SendReadReceipts: tt.receipts}
+			before := len(bt.srv.Actions())
+
+			stop := bt.bot.showPresence(context.Background(), bt.bot.whatsApp(bt.sessionName), chat, tt.msgID.String())
+			stop()
+
+			var got []string
+			for _, action := range bt.srv.Actions()[before:] {
+				if action.ChatID != friend || action.Session != bt.sessionName {
+					t.Errorf("presence call %+v outside the chat", action)
+				}
+				if action.Endpoint == "sendSeen" && (len(action.MessageIDs) != 1 || action.MessageIDs[0] != question.String()) {
+					t.Errorf("marked %v as seen, want %s", action.MessageIDs, question)
+				}
+				got = append(got, action.Endpoint)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("presence calls = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	modelConnections "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"google.golang.org/genai"
+)
+
+// Channel posts speak for whoever runs the channel, so they are written
+// without Lumi's persona.
+const channelDraftPrompt = `You write posts for a WhatsApp Channel. Write one ready-to-publish post for the request you get.
+
+* Keep it short: a headline line and at most a few short paragraphs or bullet points.
+* Use WhatsApp formatting: *bold*, _italics_, and - for bullets. No Markdown headings or links in brackets.
+* Use emojis sparingly.
+* Answer with the post only, without any introduction, options or comments.`
+
+var ErrEmptyDraft = errors.New("no draft was generated")
+
+// DraftChannelPost has Gemini write a post for channel from prompt.
+func (b *BotService) DraftChannelPost(ctx context.Context, channel modelConnections.Channel, prompt string) (string, error) {
+	request := fmt.Sprintf("Channel: %s\n", channel.Name)
+	if channel.Description != "" {
+		request += fmt.Sprintf("About the channel: %s\n", channel.Description)
+	}
+	request += "Request: " + prompt
+
+	resp, err := b.botClient.Models.GenerateContent(
+		ctx,
+		"gemini-2.5-flash",
+		genai.Text(request),
+		&genai.GenerateContentConfig{
+			SystemInstruction: genai.Text(channelDraftPrompt)[0],
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	draft := strings.TrimSpace(resp.Text())
+	if draft == "" {
+		return "", ErrEmptyDraft
+	}
+	return draft, nil
+}
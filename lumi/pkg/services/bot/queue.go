@@ -0,0 +1,239 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+)
+
+var ErrQueueClosed = errors.New("message queue is shut down")
+
+type job struct {
+	run      func(ctx context.Context)
+	skipped  func() // may be nil
+	queuedAt time.Time
+}
+
+// Queue runs jobs on a bounded pool of workers. Every key has its own FIFO:
+// its jobs run one after another in the order they were queued. A key with
+// jobs waiting and none running is ready, and the next free worker picks it
+// up, so a slow key only ever holds up its own jobs.
+type Queue struct {
+	workers int
+	wg      sync.WaitGroup
+
+	// slots bounds the jobs waiting across all keys; Enqueue takes one
+	// before it touches mu and a worker returns it when it takes the job.
+	slots chan struct{}
+
+	// ready carries keys to the workers. A key is in it at most once and
+	// only while it has waiting jobs, each holding a slot, so it never
+	// holds more than cap(slots) keys and sending on it never blocks.
+	ready chan string
+
+	// mu guards the per key FIFOs. It is never held while blocking.
+	mu      sync.Mutex
+	pending map[string][]job
+	active  map[string]bool // keys in ready or running a job
+	closed  bool
+
+	// Jobs run under workCtx, which Shutdown cancels once its own deadline
+	// passes, so a drain can't outlive it.
+	workCtx    context.Context
+	cancelWork context.CancelFunc
+
+	depth     atomic.Int64
+	enqueued  atomic.Int64
+	processed atomic.Int64
+	waited    atomic.Int64
+	waitTime  atomic.Int64 // nanoseconds
+	dropped   atomic.Int64
+}
+
+// NewQueue returns a queue of workers workers, holding up to capacity
+// waiting jobs before Enqueue blocks.
+func NewQueue(workers, capacity int) *Queue {
+	q := &Queue{
+		workers: workers,
+		slots:   make(chan struct{}, capacity),
+		ready:   make(chan string, capacity),
+		pending: make(map[string][]job),
+		active:  make(map[string]bool),
+	}
+	q.workCtx, q.cancelWork = context.WithCancel(context.Background())
+	return q
+}
+
+func (q *Queue) Start() {
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+// Enqueue queues run under key. When the queue is full it waits for room,
+// which slows the caller down instead of growing without bound, and gives
+// up when ctx is done. skipped, if set, is called instead of run when a
+// shutdown cuts the drain short before the job's turn.
+func (q *Queue) Enqueue(ctx context.Context, key string, run func(ctx context.Context), skipped func()) error {
+	if q.isClosed() {
+		q.dropped.Add(1)
+		return ErrQueueClosed
+	}
+
+	j := job{run: run, skipped: skipped, queuedAt: time.Now()}
+
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		q.waited.Add(1)
+		select {
+		case q.slots <- struct{}{}:
+			q.waitTime.Add(int64(time.Since(j.queuedAt)))
+		case <-ctx.Done():
+			q.dropped.Add(1)
+			return ctx.Err()
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		<-q.slots
+		q.dropped.Add(1)
+		return ErrQueueClosed
+	}
+
+	q.pending[key] = append(q.pending[key], j)
+	if !q.active[key] {
+		q.active[key] = true
+		q.ready <- key
+	}
+
+	q.enqueued.Add(1)
+	q.depth.Add(1)
+	return nil
+}
+
+func (q *Queue) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// Shutdown stops accepting jobs and waits for the queued ones to finish.
+// If ctx ends first, running jobs are cancelled and the rest skipped.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		if len(q.active) == 0 {
+			close(q.ready)
+		}
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancelWork()
+		return nil
+	case <-ctx.Done():
+		q.cancelWork()
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) Stats() models.QueueStats {
+	stats := models.QueueStats{
+		Workers:   q.workers,
+		Capacity:  cap(q.slots),
+		Depth:     q.depth.Load(),
+		Enqueued:  q.enqueued.Load(),
+		Processed: q.processed.Load(),
+		Waited:    q.waited.Load(),
+		Dropped:   q.dropped.Load(),
+	}
+	if stats.Waited > 0 {
+		stats.AvgWait = time.Duration(q.waitTime.Load() / stats.Waited)
+	}
+	return stats
+}
+
+// work runs the next job of each ready key it picks up, then hands the key
+// back to ready if more of its jobs wait, so busy keys take turns.
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for key := range q.ready {
+		j := q.next(key)
+		<-q.slots
+		q.depth.Add(-1)
+
+		if q.workCtx.Err() != nil {
+			q.dropped.Add(1)
+			if j.skipped != nil {
+				j.skipped()
+			}
+		} else {
+			q.run(j)
+			q.processed.Add(1)
+		}
+
+		q.done(key)
+	}
+}
+
+// next takes the oldest waiting job of key.
+func (q *Queue) next(key string) job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := q.pending[key]
+	j := jobs[0]
+	jobs[0] = job{}
+	if len(jobs) == 1 {
+		delete(q.pending, key)
+	} else {
+		q.pending[key] = jobs[1:]
+	}
+	return j
+}
+
+// done finishes a job of key: the key is ready again if it has more jobs,
+// and idle otherwise. The last key to go idle after Shutdown stops the
+// workers.
+func (q *Queue) done(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending[key]) > 0 {
+		q.ready <- key
+		return
+	}
+	delete(q.active, key)
+	if q.closed && len(q.active) == 0 {
+		close(q.ready)
+	}
+}
+
+// run keeps a panicking job from taking its worker down.
+func (q *Queue) run(j job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Queue: job panicked: %v", r)
+		}
+	}()
+	j.run(q.workCtx)
+}
@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueueKeepsChatOrder(t *testing.T) {
+	q := NewQueue(2, 4)
+	q.Start()
+
+	var mu sync.Mutex
+	got := make(map[string][]int)
+
+	chats := []string{"a@c.us", "b@c.us", "c@c.us"}
+	for i := 0; i < 10; i++ {
+		for _, chat := range chats {
+			chat, i := chat, i
+			err := q.Enqueue(context.Background(), chat, func(ctx context.Context) {
+				mu.Lock()
+				defer mu.Unlock()
+				got[chat] = append(got[chat], i)
+			}, nil)
+			if err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+		}
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	want := fmt.Sprint([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	for _, chat := range chats {
+		if fmt.Sprint(got[chat]) != want {
+			t.Errorf("%s ran %v, want %s", chat, got[chat], want)
+		}
+	}
+	if stats := q.Stats(); stats.Enqueued != 30 || stats.Processed != 30 || stats.Depth != 0 {
+		t.Errorf("stats = %+v, want 30 enqueued and processed", stats)
+	}
+}
+
+func TestQueueBusyChatHoldsUpOnlyItself(t *testing.T) {
+	q := NewQueue(2, 16)
+	q.Start()
+	t.Cleanup(func() { q.Shutdown(context.Background()) })
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var got []string
+	record := func(job string) func(context.Context) {
+		return func(context.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, job)
+		}
+	}
+
+	enqueue := func(key string, run func(context.Context)) {
+		t.Helper()
+		if err := q.Enqueue(context.Background(), key, run, nil); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	// a's first job blocks its worker; a's later job waits behind it while
+	// every other chat is served by the remaining worker.
+	enqueue("a@c.us", func(context.Context) { <-release })
+	enqueue("a@c.us", record("a2"))
+	for i := 0; i < 6; i++ {
+		chat := fmt.Sprintf("%c@c.us", 'b'+i)
+		enqueue(chat, record(chat))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == 6 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("other chats ran %v while a@c.us was busy, want all 6", got)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	for _, job := range got {
+		if job == "a2" {
+			t.Errorf("a@c.us's second job ran before its first finished")
+		}
+	}
+	mu.Unlock()
+
+	close(release)
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if len(got) != 7 || got[6] != "a2" {
+		t.Errorf("ran %v, want a2 last", got)
+	}
+	if stats := q.Stats(); stats.Workers != 2 || stats.Capacity != 16 || stats.Processed != 8 || stats.Depth != 0 {
+		t.Errorf("stats = %+v, want 2 workers, capacity 16 and 8 processed", stats)
+	}
+}
+
+func TestQueueShutdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		drain   time.Duration // how long Shutdown may wait
+		err     error
+		ran     int
+		skipped int
+	}{
+		{"drained", time.Second, nil, 2, 0},
+		{"cut short", 10 * time.Millisecond, context.DeadlineExceeded, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQueue(1, 4)
+			q.Start()
+
+			var mu sync.Mutex
+			ran, skipped := 0, 0
+			run := func(ctx context.Context) {
+				select {
+				case <-ctx.Done():
+				case <-time.After(50 * time.Millisecond):
+				}
+				mu.Lock()
+				ran++
+				mu.Unlock()
+			}
+			skip := func() {
+				mu.Lock()
+				skipped++
+				mu.Unlock()
+			}
+			for i := 0; i < 2; i++ {
+				if err := q.Enqueue(context.Background(), "a@c.us", run, skip); err != nil {
+					t.Fatalf("Enqueue: %v", err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.drain)
+			defer cancel()
+			if err := q.Shutdown(ctx); !errors.Is(err, tt.err) {
+				t.Fatalf("Shutdown = %v, want %v", err, tt.err)
+			}
+			q.wg.Wait()
+
+			if ran != tt.ran || skipped != tt.skipped {
+				t.Errorf("ran %d and skipped %d, want %d and %d", ran, skipped, tt.ran, tt.skipped)
+			}
+			if err := q.Enqueue(context.Background(), "a@c.us", run, nil); !errors.Is(err, ErrQueueClosed) {
+				t.Errorf("Enqueue after shutdown = %v, want ErrQueueClosed", err)
+			}
+		})
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	q := NewQueue(1, 1)
+	// Not started: the first job fills the worker's buffer.
+	if err := q.Enqueue(context.Background(), "a@c.us", func(context.Context) {}, nil); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Enqueue(ctx, "a@c.us", func(context.Context) {}, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Enqueue on a full queue = %v, want DeadlineExceeded", err)
+	}
+	if stats := q.Stats(); stats.Waited != 1 || stats.Dropped != 1 || stats.Depth != 1 {
+		t.Errorf("stats = %+v, want 1 waited, 1 dropped and 1 queued", stats)
+	}
+}
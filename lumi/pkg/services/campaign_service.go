@@ -0,0 +1,656 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
+	"gorm.io/gorm"
+)
+
+const (
+	// Defaults, and the limits a campaign can't go past, to keep the
+	// number from looking like a spammer.
+	campaignDefaultMinDelay = 30 * time.Second
+	campaignDefaultMaxDelay = 90 * time.Second
+	campaignMinDelay        = 10 * time.Second
+	campaignDefaultDailyCap = 100
+	campaignMaxDailyCap     = 500
+	// A session sends at most this many campaign messages a day, however
+	// many campaigns run at once.
+	campaignSessionDailyCap = 500
+
+	campaignPollInterval = 5 * time.Second
+)
+
+var (
+	ErrInvalidCampaign       = errors.New("invalid campaign")
+	ErrCampaignNoRecipients  = errors.New("the campaign targets no WhatsApp chats")
+	ErrCampaignNotFound      = errors.New("campaign not found")
+	ErrCampaignInvalidChange = errors.New("campaign can't change to that state")
+)
+
+// CampaignTarget selects the recipients of a campaign. The chats matching
+// any of the fields are combined.
+type CampaignTarget struct {
+	ChatIDs []string // WhatsApp chats registered on the session
+	Tags    []string // registered chats carrying one of the tags
+	Type    string   // every registered chat of the type, e.g. "chat" or "group"
+}
+
+// CampaignMessage is what a campaign template is rendered with, e.g.
+// "Hi {{.FirstName}}, ...".
+type CampaignMessage struct {
+	Name      string
+	FirstName string
+	ChatID    string
+}
+
+type CampaignService struct {
+	chatService   *ChatService
+	outboxService *OutboxService
+	wake          chan struct{}
+}
+
+func NewCampaignService(chatService *ChatService, outboxService *OutboxService) *CampaignService {
+	return &CampaignService{
+		chatService:   chatService,
+		outboxService: outboxService,
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Create stores a campaign and starts sending it. Delays are in seconds;
+// zero values pick the defaults.
+func (s *CampaignService) Create(sessionName, name, text string, target CampaignTarget, minDelay, maxDelay, dailyCap int) (*models.CampaignProgress, error) {
+	campaign := models.Campaign{
+		SessionName: sessionName,
+		Name:        strings.TrimSpace(name),
+		Template:    strings.TrimSpace(text),
+		Status:      models.CampaignRunning,
+		MinDelay:    minDelay,
+		MaxDelay:    maxDelay,
+		DailyCap:    dailyCap,
+		NextSendAt:  time.Now(),
+	}
+	if err := validateCampaign(&campaign); err != nil {
+		return nil, err
+	}
+
+	recipients, err := s.resolveTarget(sessionName, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, ErrCampaignNoRecipients
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&campaign).Error; err != nil {
+			return err
+		}
+		for i := range recipients {
+			recipients[i].CampaignID = campaign.ID
+			recipients[i].Status = models.CampaignRecipientPending
+		}
+		return tx.CreateInBatches(recipients, 100).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify()
+	return &models.CampaignProgress{
+		Campaign: campaign,
+		Counts:   map[string]int{models.CampaignRecipientPending: len(recipients)},
+		Total:    len(recipients),
+	}, nil
+}
+
+func validateCampaign(campaign *models.Campaign) error {
+	if campaign.Name == "" {
+		return fmt.Errorf("%w: a name is required", ErrInvalidCampaign)
+	}
+	if campaign.Template == "" {
+		return fmt.Errorf("%w: a message template is required", ErrInvalidCampaign)
+	}
+	if _, err := renderCampaign(campaign.Template, CampaignMessage{Name: "Test", FirstName: "Test", ChatID: "123@c.us"}); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCampaign, err)
+	}
+
+	if campaign.MinDelay == 0 {
+		campaign.MinDelay = int(campaignDefaultMinDelay.Seconds())
+	}
+	if campaign.MaxDelay == 0 {
+		campaign.MaxDelay = max(campaign.MinDelay, int(campaignDefaultMaxDelay.Seconds()))
+	}
+	if campaign.MinDelay < int(campaignMinDelay.Seconds()) {
+		return fmt.Errorf("%w: messages must be at least %v apart", ErrInvalidCampaign, campaignMinDelay)
+	}
+	if campaign.MaxDelay < campaign.MinDelay {
+		return fmt.Errorf("%w: the maximum delay is below the minimum", ErrInvalidCampaign)
+	}
+
+	if campaign.DailyCap == 0 {
+		campaign.DailyCap = campaignDefaultDailyCap
+	}
+	if campaign.DailyCap < 0 || campaign.DailyCap > campaignMaxDailyCap {
+		return fmt.Errorf("%w: the daily cap must be between 1 and %d", ErrInvalidCampaign, campaignMaxDailyCap)
+	}
+	return nil
+}
+
+func renderCampaign(text string, msg CampaignMessage) (string, error) {
+	tmpl, err := template.New("campaign").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, msg); err != nil {
+		return "", err
+	}
+
+	rendered := strings.TrimSpace(out.String())
+	if rendered == "" {
+		return "", errors.New("the template renders an empty message")
+	}
+	return rendered, nil
+}
+
+// resolveTarget lists the session's registered WhatsApp chats target
+// selects, named after their registration or the session's contacts. A
+// chat id the session has not registered makes the target invalid.
+func (s *CampaignService) resolveTarget(sessionName string, target CampaignTarget) ([]models.CampaignRecipient, error) {
+	registered, err := s.chatService.GetRegisteredChats(sessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	var chatIDs []string
+	add := func(chatID string) {
+		if !slices.Contains(chatIDs, chatID) {
+			chatIDs = append(chatIDs, chatID)
+		}
+	}
+
+	tags := NormalizeTags(target.Tags)
+	for _, chat := range registered {
+		if chat.Platform != messenger.PlatformWhatsApp {
+			continue
+		}
+		names[chat.ChatID] = chat.Name
+
+		matchesType := target.Type != "" && chat.Type == target.Type
+		matchesTag := slices.ContainsFunc(chat.Tags, func(tag string) bool { return slices.Contains(tags, tag) })
+		if matchesType || matchesTag {
+			add(chat.ChatID)
+		}
+	}
+
+	for _, chatID := range target.ChatIDs {
+		chatID = strings.TrimSpace(chatID)
+		if _, ok := names[chatID]; !ok {
+			return nil, fmt.Errorf("%w: %q is not a registered WhatsApp chat", ErrInvalidCampaign, chatID)
+		}
+		add(chatID)
+	}
+
+	if len(chatIDs) == 0 {
+		return nil, nil
+	}
+
+	// Fill the gaps from the contacts cache.
+	var contacts []models.Contact
+	if err := db.DB.Where("session_name = ? AND contact_id IN ?", sessionName, chatIDs).Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+	for _, contact := range contacts {
+		if names[contact.ContactID] != "" {
+			continue
+		}
+		if contact.Name != "" {
+			names[contact.ContactID] = contact.Name
+		} else {
+			names[contact.ContactID] = contact.PushName
+		}
+	}
+
+	recipients := make([]models.CampaignRecipient, 0, len(chatIDs))
+	for _, chatID := range chatIDs {
+		recipients = append(recipients, models.CampaignRecipient{ChatID: chatID, Name: names[chatID]})
+	}
+	return recipients, nil
+}
+
+func (s *CampaignService) List(sessionName, status string) ([]models.CampaignProgress, error) {
+	query := db.DB.Where("session_name = ?", sessionName)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var campaigns []models.Campaign
+	if err := query.Order("created_at desc").Limit(200).Find(&campaigns).Error; err != nil {
+		return nil, err
+	}
+
+	progress := make([]models.CampaignProgress, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		p, err := progressOf(campaign)
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, *p)
+	}
+	return progress, nil
+}
+
+func (s *CampaignService) Get(sessionName string, id uint) (*models.CampaignProgress, error) {
+	campaign, err := findCampaign(sessionName, id)
+	if err != nil {
+		return nil, err
+	}
+	return progressOf(*campaign)
+}
+
+// Recipients lists the recipients of a campaign, only those in status if
+// that is not empty.
+func (s *CampaignService) Recipients(sessionName string, id uint, status string) ([]models.CampaignRecipient, error) {
+	if _, err := findCampaign(sessionName, id); err != nil {
+		return nil, err
+	}
+
+	query := db.DB.Where("campaign_id = ?", id)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var recipients []models.CampaignRecipient
+	if err := query.Order("id asc").Find(&recipients).Error; err != nil {
+		return nil, err
+	}
+	return recipients, nil
+}
+
+// Pause stops a running campaign after the message being sent, if any.
+func (s *CampaignService) Pause(sessionName string, id uint) (*models.CampaignProgress, error) {
+	return s.transition(sessionName, id, []string{models.CampaignRunning}, map[string]any{"status": models.CampaignPaused})
+}
+
+func (s *CampaignService) Resume(sessionName string, id uint) (*models.CampaignProgress, error) {
+	progress, err := s.transition(sessionName, id, []string{models.CampaignPaused}, map[string]any{"status": models.CampaignRunning, "next_send_at": time.Now()})
+	if err == nil {
+		s.notify()
+	}
+	return progress, err
+}
+
+// Cancel stops a campaign for good. Messages already handed to the outbox
+// are still delivered; the remaining recipients are cancelled.
+func (s *CampaignService) Cancel(sessionName string, id uint) (*models.CampaignProgress, error) {
+	campaign, err := findCampaign(sessionName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		claim := tx.Model(&models.Campaign{}).
+			Where("id = ? AND status IN ?", campaign.ID, []string{models.CampaignRunning, models.CampaignPaused}).
+			Update("status", models.CampaignCancelled)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return ErrCampaignInvalidChange
+		}
+
+		return tx.Model(&models.CampaignRecipient{}).
+			Where("campaign_id = ? AND status = ?", campaign.ID, models.CampaignRecipientPending).
+			Update("status", models.CampaignRecipientCancelled).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(sessionName, id)
+}
+
+func (s *CampaignService) transition(sessionName string, id uint, from []string, updates map[string]any) (*models.CampaignProgress, error) {
+	campaign, err := findCampaign(sessionName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	claim := db.DB.Model(&models.Campaign{}).Where("id = ? AND status IN ?", campaign.ID, from).Updates(updates)
+	if claim.Error != nil {
+		return nil, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return nil, ErrCampaignInvalidChange
+	}
+	return s.Get(sessionName, id)
+}
+
+func findCampaign(sessionName string, id uint) (*models.Campaign, error) {
+	var campaign models.Campaign
+	err := db.DB.Where("id = ? AND session_name = ?", id, sessionName).First(&campaign).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func progressOf(campaign models.Campaign) (*models.CampaignProgress, error) {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	err := db.DB.Model(&models.CampaignRecipient{}).
+		Select("status, count(*) as count").
+		Where("campaign_id = ?", campaign.ID).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &models.CampaignProgress{Campaign: campaign, Counts: make(map[string]int)}
+	for _, row := range rows {
+		progress.Counts[row.Status] = row.Count
+		progress.Total += row.Count
+	}
+	return progress, nil
+}
+
+// Start runs the campaign worker until ctx is cancelled.
+func (s *CampaignService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(campaignPollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.syncDeliveries()
+			s.processDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+func (s *CampaignService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// syncDeliveries copies the outcome of queued messages from the outbox.
+func (s *CampaignService) syncDeliveries() {
+	var rows []struct {
+		ID        uint
+		Status    string
+		LastError string
+		SentAt    *time.Time
+	}
+	err := db.DB.Table("campaign_recipients AS r").
+		Select("r.id, o.status, o.last_error, o.sent_at").
+		Joins("JOIN outbox_messages AS o ON o.id = r.outbox_message_id").
+		Where("r.deleted_at IS NULL").
+		// A dead message the user retried from the outbox can still succeed.
+		Where("(r.status = ? AND o.status IN ?) OR (r.status = ? AND o.status = ?)",
+			models.CampaignRecipientQueued, []string{models.OutboxStatusSent, models.OutboxStatusDead},
+			models.CampaignRecipientFailed, models.OutboxStatusSent).
+		Scan(&rows).Error
+	if err != nil {
+		log.Printf("Campaign: failed to load deliveries: %v", err)
+		return
+	}
+
+	for _, row := range rows {
+		updates := map[string]any{"status": models.CampaignRecipientSent, "sent_at": row.SentAt, "last_error": ""}
+		if row.Status == models.OutboxStatusDead {
+			updates = map[string]any{"status": models.CampaignRecipientFailed, "last_error": row.LastError}
+		}
+		if err := db.DB.Model(&models.CampaignRecipient{}).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+			log.Printf("Campaign: failed to update recipient %d: %v", row.ID, err)
+		}
+	}
+
+	// Campaigns with nothing left to send or wait for are done.
+	err = db.DB.Model(&models.Campaign{}).
+		Where("status = ?", models.CampaignRunning).
+		Where("NOT EXISTS (?)", db.DB.Model(&models.CampaignRecipient{}).
+			Select("1").
+			Where("campaign_recipients.campaign_id = campaigns.id AND campaign_recipients.status IN ?",
+				[]string{models.CampaignRecipientPending, models.CampaignRecipientQueued})).
+		Updates(map[string]any{"status": models.CampaignCompleted, "completed_at": time.Now()}).Error
+	if err != nil {
+		log.Printf("Campaign: failed to complete campaigns: %v", err)
+	}
+}
+
+func (s *CampaignService) processDue(ctx context.Context) {
+	var campaigns []models.Campaign
+	err := db.DB.
+		Where("status = ? AND next_send_at <= ?", models.CampaignRunning, time.Now()).
+		Order("next_send_at asc").
+		Find(&campaigns).Error
+	if err != nil {
+		log.Printf("Campaign: failed to load due campaigns: %v", err)
+		return
+	}
+
+	// One message per session and round, so campaigns running side by side
+	// don't add up to a burst.
+	served := make(map[string]bool)
+	for i := range campaigns {
+		if ctx.Err() != nil {
+			return
+		}
+		if served[campaigns[i].SessionName] {
+			continue
+		}
+		if s.sendNext(&campaigns[i]) {
+			served[campaigns[i].SessionName] = true
+		}
+	}
+}
+
+// sendNext queues the campaign's next recipient in the outbox and reports
+// whether it did. While the session's previous campaign message is still in
+// the outbox, e.g. because WhatsApp is down, nothing more is queued, and
+// the delay counts from when that message actually went out. Otherwise the
+// messages would pile up and leave in a burst once the outbox recovers.
+func (s *CampaignService) sendNext(campaign *models.Campaign) bool {
+	now := time.Now()
+
+	lastSent, waiting, err := lastCampaignSend(campaign.SessionName)
+	if err != nil {
+		log.Printf("Campaign: failed to check the last message of session %s: %v", campaign.SessionName, err)
+		return false
+	}
+	if waiting {
+		return false
+	}
+	if lastSent != nil && now.Before(lastSent.Add(time.Duration(campaign.MinDelay)*time.Second)) {
+		s.reschedule(campaign, lastSent.Add(campaignDelay(campaign)))
+		return false
+	}
+
+	capped, err := dailyCapReached(campaign, now)
+	if err != nil {
+		log.Printf("Campaign: failed to count today's messages of campaign %d: %v", campaign.ID, err)
+		return false
+	}
+	if capped {
+		s.reschedule(campaign, nextDay(now).Add(campaignDelay(campaign)))
+		return false
+	}
+
+	var recipient models.CampaignRecipient
+	err = db.DB.Where("campaign_id = ? AND status = ?", campaign.ID, models.CampaignRecipientPending).
+		Order("id asc").
+		First(&recipient).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false // waiting for queued messages; syncDeliveries completes it
+	}
+	if err != nil {
+		log.Printf("Campaign: failed to load next recipient of campaign %d: %v", campaign.ID, err)
+		return false
+	}
+
+	text, renderErr := renderCampaign(campaign.Template, campaignMessage(recipient))
+	if renderErr != nil {
+		err := db.DB.Model(&recipient).Updates(map[string]any{"status": models.CampaignRecipientFailed, "last_error": renderErr.Error()}).Error
+		if err != nil {
+			log.Printf("Campaign: failed to mark recipient %s of campaign %d as failed: %v", recipient.ChatID, campaign.ID, err)
+		}
+		return false
+	}
+
+	// Claim the recipient, queue its message and link the two in one go: a
+	// pause or cancel that lands meanwhile can't be overtaken, and a failure
+	// leaves the recipient pending rather than queued without a message.
+	queued := false
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		claim := tx.Model(&models.CampaignRecipient{}).
+			Where("id = ? AND status = ?", recipient.ID, models.CampaignRecipientPending).
+			Where("EXISTS (?)", tx.Model(&models.Campaign{}).Select("1").Where("campaigns.id = ? AND campaigns.status = ?", campaign.ID, models.CampaignRunning)).
+			Updates(map[string]any{"status": models.CampaignRecipientQueued, "text": text, "queued_at": now})
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			return claim.Error
+		}
+
+		payload := textRequest(campaign.SessionName, recipient.ChatID, "", text)
+		item, err := s.outboxService.enqueueIn(tx, campaign.SessionName, recipient.ChatID, OutboxKindText, text, payload, false)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CampaignRecipient{}).Where("id = ?", recipient.ID).Update("outbox_message_id", item.ID).Error; err != nil {
+			return err
+		}
+		queued = true
+		return nil
+	})
+	if err != nil {
+		log.Printf("Campaign: failed to queue message of campaign %d to %s: %v", campaign.ID, recipient.ChatID, err)
+		s.reschedule(campaign, now.Add(campaignMinDelay))
+		return false
+	}
+	if !queued {
+		return false
+	}
+
+	s.outboxService.notify()
+	s.reschedule(campaign, now.Add(campaignDelay(campaign)))
+	return true
+}
+
+func (s *CampaignService) reschedule(campaign *models.Campaign, at time.Time) {
+	err := db.DB.Model(&models.Campaign{}).
+		Where("id = ? AND status = ?", campaign.ID, models.CampaignRunning).
+		Update("next_send_at", at).Error
+	if err != nil {
+		log.Printf("Campaign: failed to reschedule campaign %d: %v", campaign.ID, err)
+	}
+}
+
+// lastCampaignSend returns when the session's last campaign message was
+// sent, and whether one is still waiting in the outbox.
+func lastCampaignSend(sessionName string) (*time.Time, bool, error) {
+	sessionRecipients := func() *gorm.DB {
+		return db.DB.Model(&models.CampaignRecipient{}).
+			Joins("JOIN campaigns ON campaigns.id = campaign_recipients.campaign_id").
+			Where("campaigns.session_name = ?", sessionName)
+	}
+
+	var queued int64
+	if err := sessionRecipients().Where("campaign_recipients.status = ?", models.CampaignRecipientQueued).Count(&queued).Error; err != nil {
+		return nil, false, err
+	}
+	if queued > 0 {
+		return nil, true, nil
+	}
+
+	var last models.CampaignRecipient
+	err := sessionRecipients().
+		Where("campaign_recipients.sent_at IS NOT NULL").
+		Order("campaign_recipients.sent_at desc").
+		First(&last).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return last.SentAt, false, nil
+}
+
+// dailyCapReached reports whether the campaign, or its session across all
+// campaigns, already queued its share of messages today.
+func dailyCapReached(campaign *models.Campaign, now time.Time) (bool, error) {
+	today := startOfDay(now)
+
+	var sent int64
+	err := db.DB.Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND queued_at >= ?", campaign.ID, today).
+		Count(&sent).Error
+	if err != nil {
+		return false, err
+	}
+	if sent >= int64(campaign.DailyCap) {
+		return true, nil
+	}
+
+	err = db.DB.Model(&models.CampaignRecipient{}).
+		Joins("JOIN campaigns ON campaigns.id = campaign_recipients.campaign_id").
+		Where("campaigns.session_name = ? AND campaign_recipients.queued_at >= ?", campaign.SessionName, today).
+		Count(&sent).Error
+	if err != nil {
+		return false, err
+	}
+	return sent >= campaignSessionDailyCap, nil
+}
+
+func campaignDelay(campaign *models.Campaign) time.Duration {
+	spread := campaign.MaxDelay - campaign.MinDelay
+	delay := campaign.MinDelay
+	if spread > 0 {
+		delay += rand.IntN(spread + 1)
+	}
+	return time.Duration(delay) * time.Second
+}
+
+func campaignMessage(recipient models.CampaignRecipient) CampaignMessage {
+	msg := CampaignMessage{Name: recipient.Name, ChatID: recipient.ChatID}
+	if fields := strings.Fields(recipient.Name); len(fields) > 0 {
+		msg.FirstName = fields[0]
+	}
+	return msg
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+func nextDay(t time.Time) time.Time {
+	return startOfDay(t).AddDate(0, 0, 1)
+}
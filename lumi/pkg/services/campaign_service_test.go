@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
+)
+
+const (
+	campaignFriend = "15552223333@c.us"
+	campaignFamily = "15554445555@c.us"
+	campaignClub   = "120363000000000000@g.us"
+)
+
+func newCampaignTest(t *testing.T) *CampaignService {
+	t.Helper()
+
+	outbox, chats, _ := newOutboxTest(t)
+	if err := db.DB.AutoMigrate(&models.Campaign{}, &models.CampaignRecipient{}, &models.Contact{}); err != nil {
+		t.Fatalf("migrating campaigns: %v", err)
+	}
+
+	registered := []struct {
+		session, platform, chatID, name, chatType string
+		tags                                      []string
+	}{
+		{"default", messenger.PlatformWhatsApp, campaignFriend, "Sam Doe", "chat", []string{"friends"}},
+		{"default", messenger.PlatformWhatsApp, campaignFamily, "", "chat", []string{"Family", "friends"}},
+		{"default", messenger.PlatformWhatsApp, campaignClub, "Book club", "group", nil},
+		{"default", messenger.PlatformTelegram, "-100", "Telegram group", "group", []string{"friends"}},
+		{"other", messenger.PlatformWhatsApp, "15556667777@c.us", "Someone else's", "chat", []string{"friends"}},
+	}
+	for _, r := range registered {
+		if _, err := chats.RegisterChat(r.session, r.platform, r.chatID, r.name, r.chatType); err != nil {
+			t.Fatalf("RegisterChat: %v", err)
+		}
+		if _, err := chats.SetChatTags(r.session, r.chatID, r.tags); err != nil {
+			t.Fatalf("SetChatTags: %v", err)
+		}
+	}
+	contact := models.Contact{SessionName: "default", ContactID: campaignFamily, PushName: "Kim"}
+	if err := db.DB.Create(&contact).Error; err != nil {
+		t.Fatalf("creating contact: %v", err)
+	}
+
+	return NewCampaignService(chats, outbox)
+}
+
+func TestCreateCampaign(t *testing.T) {
+	campaigns := newCampaignTest(t)
+
+	tests := []struct {
+		name       string
+		text       string
+		target     CampaignTarget
+		minDelay   int
+		dailyCap   int
+		wantErr    error
+		recipients map[string]string // chat id to name
+	}{
+		{"by tag", "Hi {{.FirstName}}", CampaignTarget{Tags: []string{" FRIENDS "}}, 0, 0, nil,
+			map[string]string{campaignFriend: "Sam Doe", campaignFamily: "Kim"}},
+		{"by type", "Hi all", CampaignTarget{Type: "group"}, 0, 0, nil,
+			map[string]string{campaignClub: "Book club"}},
+		{"by id, combined with a tag", "Hi", CampaignTarget{ChatIDs: []string{campaignClub}, Tags: []string{"family"}}, 0, 0, nil,
+			map[string]string{campaignClub: "Book club", campaignFamily: "Kim"}},
+		{"a Telegram id", "Hi", CampaignTarget{ChatIDs: []string{"-100"}}, 0, 0, ErrInvalidCampaign, nil},
+		{"an unregistered id", "Hi", CampaignTarget{ChatIDs: []string{campaignClub, "15559990000@c.us"}}, 0, 0, ErrInvalidCampaign, nil},
+		{"another session's chat", "Hi", CampaignTarget{ChatIDs: []string{"15556667777@c.us"}}, 0, 0, ErrInvalidCampaign, nil},
+		{"nobody", "Hi", CampaignTarget{Tags: []string{"colleagues"}}, 0, 0, ErrCampaignNoRecipients, nil},
+		{"unknown template field", "Hi {{.Nickname}}", CampaignTarget{Type: "chat"}, 0, 0, ErrInvalidCampaign, nil},
+		{"too fast", "Hi", CampaignTarget{Type: "chat"}, 5, 0, ErrInvalidCampaign, nil},
+		{"cap too high", "Hi", CampaignTarget{Type: "chat"}, 0, 1000, ErrInvalidCampaign, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress, err := campaigns.Create("default", tt.name, tt.text, tt.target, tt.minDelay, 0, tt.dailyCap)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			recipients, err := campaigns.Recipients("default", progress.Campaign.ID, "")
+			if err != nil {
+				t.Fatalf("Recipients: %v", err)
+			}
+			got := make(map[string]string)
+			for _, r := range recipients {
+				got[r.ChatID] = r.Name
+			}
+			if len(got) != len(tt.recipients) || progress.Total != len(tt.recipients) {
+				t.Fatalf("recipients = %v, want %v", got, tt.recipients)
+			}
+			for chatID, name := range tt.recipients {
+				if got[chatID] != name {
+					t.Errorf("recipient %s named %q, want %q", chatID, got[chatID], name)
+				}
+			}
+		})
+	}
+}
+
+func TestCampaignPacing(t *testing.T) {
+	campaigns := newCampaignTest(t)
+	ctx := context.Background()
+
+	progress, err := campaigns.Create("default", "Newsletter", "Hi {{.FirstName}}!", CampaignTarget{Tags: []string{"friends"}}, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	id := progress.Campaign.ID
+
+	// deliver marks whatever the campaign queued as sent by the outbox at.
+	deliver := func(at time.Time) {
+		var recipients []models.CampaignRecipient
+		db.DB.Where("campaign_id = ? AND status = ?", id, models.CampaignRecipientQueued).Find(&recipients)
+		for _, r := range recipients {
+			db.DB.Model(&models.OutboxMessage{}).Where("id = ?", r.OutboxMessageID).
+				Updates(map[string]any{"status": models.OutboxStatusSent, "sent_at": at})
+		}
+		campaigns.syncDeliveries()
+	}
+	due := func() {
+		db.DB.Model(&models.Campaign{}).Where("id = ?", id).Update("next_send_at", time.Now())
+		campaigns.processDue(ctx)
+	}
+
+	// Each step runs on the state the previous ones left behind.
+	steps := []struct {
+		name   string
+		run    func()
+		counts map[string]int
+		status string
+	}{
+		{"the first message is queued", func() { campaigns.processDue(ctx) },
+			map[string]int{models.CampaignRecipientQueued: 1, models.CampaignRecipientPending: 1}, models.CampaignRunning},
+		{"nothing more while it waits in the outbox", due,
+			map[string]int{models.CampaignRecipientQueued: 1, models.CampaignRecipientPending: 1}, models.CampaignRunning},
+		{"it is sent", func() { deliver(time.Now()) },
+			map[string]int{models.CampaignRecipientSent: 1, models.CampaignRecipientPending: 1}, models.CampaignRunning},
+		{"the delay counts from the delivery", due,
+			map[string]int{models.CampaignRecipientSent: 1, models.CampaignRecipientPending: 1}, models.CampaignRunning},
+		{"paused", func() { campaigns.Pause("default", id) },
+			map[string]int{models.CampaignRecipientSent: 1, models.CampaignRecipientPending: 1}, models.CampaignPaused},
+		{"nothing is sent while paused", func() {
+			db.DB.Model(&models.CampaignRecipient{}).Where("campaign_id = ?", id).Update("sent_at", time.Now().Add(-time.Hour))
+			due()
+		}, map[string]int{models.CampaignRecipientSent: 1, models.CampaignRecipientPending: 1}, models.CampaignPaused},
+		{"resumed, the second message is queued", func() {
+			campaigns.Resume("default", id)
+			campaigns.processDue(ctx)
+		}, map[string]int{models.CampaignRecipientSent: 1, models.CampaignRecipientQueued: 1}, models.CampaignRunning},
+		{"completed once delivered", func() { deliver(time.Now()) },
+			map[string]int{models.CampaignRecipientSent: 2}, models.CampaignCompleted},
+	}
+	for _, step := range steps {
+		step.run()
+
+		progress, err := campaigns.Get("default", id)
+		if err != nil {
+			t.Fatalf("%s: Get: %v", step.name, err)
+		}
+		if progress.Campaign.Status != step.status || len(progress.Counts) != len(step.counts) {
+			t.Fatalf("%s: campaign %s with %v, want %s with %v", step.name, progress.Campaign.Status, progress.Counts, step.status, step.counts)
+		}
+		for status, n := range step.counts {
+			if progress.Counts[status] != n {
+				t.Fatalf("%s: counts %v, want %v", step.name, progress.Counts, step.counts)
+			}
+		}
+	}
+
+	sent, err := campaigns.Recipients("default", id, models.CampaignRecipientSent)
+	if err != nil {
+		t.Fatalf("Recipients: %v", err)
+	}
+	if len(sent) != 2 || sent[0].Text != "Hi Sam!" || sent[1].Text != "Hi Kim!" {
+		t.Errorf("sent %+v, want personalised messages to Sam and Kim", sent)
+	}
+	if _, err := campaigns.Cancel("default", id); !errors.Is(err, ErrCampaignInvalidChange) {
+		t.Errorf("cancelling a completed campaign: err = %v, want ErrCampaignInvalidChange", err)
+	}
+}
+
+func TestCampaignSendNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		prepare  func(t *testing.T)
+		queued   bool
+		status   string // of the first recipient afterwards
+	}{
+		{"queued and linked", "Hi {{.FirstName}}!", func(*testing.T) {}, true, models.CampaignRecipientQueued},
+		{"the outbox fails", "Hi {{.FirstName}}!", func(t *testing.T) {
+			if err := db.DB.Migrator().DropTable(&models.OutboxMessage{}); err != nil {
+				t.Fatalf("dropping the outbox: %v", err)
+			}
+		}, false, models.CampaignRecipientPending},
+		{"the template fails", "Hi {{.FirstName.Missing}}!", func(*testing.T) {}, false, models.CampaignRecipientFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			campaigns := newCampaignTest(t)
+			progress, err := campaigns.Create("default", "Newsletter", "Hi", CampaignTarget{Tags: []string{"friends"}}, 0, 0, 0)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			campaign := progress.Campaign
+			if err := db.DB.Model(&campaign).Update("template", tt.template).Error; err != nil {
+				t.Fatalf("setting the template: %v", err)
+			}
+			tt.prepare(t)
+
+			if queued := campaigns.sendNext(&campaign); queued != tt.queued {
+				t.Errorf("sendNext = %v, want %v", queued, tt.queued)
+			}
+
+			var recipient models.CampaignRecipient
+			if err := db.DB.Where("campaign_id = ?", campaign.ID).Order("id asc").First(&recipient).Error; err != nil {
+				t.Fatalf("loading the recipient: %v", err)
+			}
+			if recipient.Status != tt.status {
+				t.Errorf("recipient %s, want %s", recipient.Status, tt.status)
+			}
+			if !tt.queued {
+				if recipient.OutboxMessageID != 0 || recipient.QueuedAt != nil {
+					t.Errorf("recipient %+v left half queued", recipient)
+				}
+				return
+			}
+			if item := outboxItem(t, recipient.OutboxMessageID); item.ChatID != recipient.ChatID || item.Text != "Hi Sam!" {
+				t.Errorf("recipient %s linked to %+v", recipient.ChatID, item)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrChannelNotWritable  = errors.New("only channels you own or administer can be posted to")
+	ErrEmptyChannelPost    = errors.New("a channel post needs text or an image")
+	ErrChannelPostNotFound = errors.New("channel post not found")
+	ErrChannelPostNotDraft = errors.New("channel post is no longer a draft")
+)
+
+type ChannelService struct {
+	sessionService *SessionService
+	outboxService  *OutboxService
+}
+
+func NewChannelService(sessionService *SessionService, outboxService *OutboxService) *ChannelService {
+	return &ChannelService{
+		sessionService: sessionService,
+		outboxService:  outboxService,
+	}
+}
+
+// ListChannels lists the channels the user's session owns or follows, only
+// those where it has role if that is not empty.
+func (s *ChannelService) ListChannels(ctx context.Context, userID uint, role string) ([]connModel.Channel, error) {
+	client, err := s.sessionService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetChannels(ctx, role)
+}
+
+func (s *ChannelService) CreateChannel(ctx context.Context, userID uint, name, description string, picture *connModel.FileWrapper) (*connModel.Channel, error) {
+	client, err := s.sessionService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return client.CreateChannel(ctx, connModel.ChannelCreateRequest{Name: name, Description: description, Picture: picture})
+}
+
+// WritableChannel returns the channel if the session may post to it, and
+// ErrChannelNotWritable if it only follows it.
+func (s *ChannelService) WritableChannel(ctx context.Context, sessionName, channelID string) (*connModel.Channel, error) {
+	channel, err := s.sessionService.Client(sessionName).GetChannel(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !channel.CanPost() {
+		return nil, ErrChannelNotWritable
+	}
+	return channel, nil
+}
+
+// Publish queues a post for the channel right away.
+func (s *ChannelService) Publish(ctx context.Context, sessionName, channelID, text string, image *connModel.FileWrapper) (*models.ChannelPost, *models.OutboxMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" && image == nil {
+		return nil, nil, ErrEmptyChannelPost
+	}
+	if _, err := s.WritableChannel(ctx, sessionName, channelID); err != nil {
+		return nil, nil, err
+	}
+
+	item, err := s.enqueue(sessionName, channelID, text, image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	post := models.ChannelPost{
+		SessionName:     sessionName,
+		ChannelID:       channelID,
+		Text:            text,
+		Image:           image,
+		Status:          models.ChannelPostPublished,
+		OutboxMessageID: item.ID,
+		PublishedAt:     &now,
+	}
+	if err := db.DB.Create(&post).Error; err != nil {
+		return nil, nil, err
+	}
+	return &post, item, nil
+}
+
+// CreateDraft stores a post Lumi wrote from prompt, to be approved later.
+func (s *ChannelService) CreateDraft(sessionName, channelID, prompt, text string, image *connModel.FileWrapper) (*models.ChannelPost, error) {
+	text = strings.TrimSpace(text)
+	if text == "" && image == nil {
+		return nil, ErrEmptyChannelPost
+	}
+
+	post := models.ChannelPost{
+		SessionName: sessionName,
+		ChannelID:   channelID,
+		Prompt:      prompt,
+		Text:        text,
+		Image:       image,
+		Status:      models.ChannelPostDraft,
+	}
+	if err := db.DB.Create(&post).Error; err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (s *ChannelService) ListPosts(sessionName, channelID, status string) ([]models.ChannelPost, error) {
+	query := db.DB.Where("session_name = ?", sessionName)
+	if channelID != "" {
+		query = query.Where("channel_id = ?", channelID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var posts []models.ChannelPost
+	if err := query.Order("created_at desc").Limit(200).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// UpdateDraft replaces the text of a draft, e.g. after a human edited it.
+func (s *ChannelService) UpdateDraft(sessionName string, id uint, text string) (*models.ChannelPost, error) {
+	post, err := s.findDraft(sessionName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" && post.Image == nil {
+		return nil, ErrEmptyChannelPost
+	}
+
+	post.Text = text
+	if err := db.DB.Save(post).Error; err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// ApproveDraft publishes a draft. A draft can only be approved once, even
+// when two approvals race.
+func (s *ChannelService) ApproveDraft(ctx context.Context, sessionName string, id uint) (*models.ChannelPost, *models.OutboxMessage, error) {
+	post, err := s.findDraft(sessionName, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.WritableChannel(ctx, sessionName, post.ChannelID); err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	claim := db.DB.Model(&models.ChannelPost{}).
+		Where("id = ? AND status = ?", post.ID, models.ChannelPostDraft).
+		Updates(map[string]any{"status": models.ChannelPostPublished, "published_at": now})
+	if claim.Error != nil {
+		return nil, nil, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return nil, nil, ErrChannelPostNotDraft
+	}
+
+	item, err := s.enqueue(sessionName, post.ChannelID, post.Text, post.Image)
+	if err != nil {
+		// Hand the draft back so it can be approved again.
+		db.DB.Model(&models.ChannelPost{}).Where("id = ?", post.ID).
+			Updates(map[string]any{"status": models.ChannelPostDraft, "published_at": nil})
+		return nil, nil, err
+	}
+
+	post.Status = models.ChannelPostPublished
+	post.PublishedAt = &now
+	post.OutboxMessageID = item.ID
+	if err := db.DB.Model(post).Update("outbox_message_id", item.ID).Error; err != nil {
+		return nil, nil, err
+	}
+	return post, item, nil
+}
+
+func (s *ChannelService) DiscardDraft(sessionName string, id uint) (*models.ChannelPost, error) {
+	post, err := s.findDraft(sessionName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	claim := db.DB.Model(&models.ChannelPost{}).
+		Where("id = ? AND status = ?", post.ID, models.ChannelPostDraft).
+		Update("status", models.ChannelPostDiscarded)
+	if claim.Error != nil {
+		return nil, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return nil, ErrChannelPostNotDraft
+	}
+
+	post.Status = models.ChannelPostDiscarded
+	return post, nil
+}
+
+func (s *ChannelService) findDraft(sessionName string, id uint) (*models.ChannelPost, error) {
+	var post models.ChannelPost
+	err := db.DB.Where("id = ? AND session_name = ?", id, sessionName).First(&post).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrChannelPostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if post.Status != models.ChannelPostDraft {
+		return nil, ErrChannelPostNotDraft
+	}
+	return &post, nil
+}
+
+func (s *ChannelService) enqueue(sessionName, channelID, text string, image *connModel.FileWrapper) (*models.OutboxMessage, error) {
+	if image == nil {
+		return s.outboxService.EnqueueText(sessionName, channelID, "", text, false)
+	}
+
+	payload := connModel.MessageImageRequest{
+		ChatID:  channelID,
+		Session: sessionName,
+		File:    *image,
+		Caption: text,
+	}
+	return s.outboxService.Enqueue(sessionName, channelID, OutboxKindImage, text, payload)
+}
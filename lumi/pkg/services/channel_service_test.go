@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+)
+
+const (
+	ownedChannel    = "120363000000000001@newsletter"
+	adminChannel    = "120363000000000002@newsletter"
+	followedChannel = "120363000000000003@newsletter"
+)
+
+func newChannelTest(t *testing.T) (*ChannelService, *OutboxService) {
+	t.Helper()
+
+	outbox, chats, srv := newOutboxTest(t)
+	if err := db.DB.AutoMigrate(&models.ChannelPost{}); err != nil {
+		t.Fatalf("migrating channel posts: %v", err)
+	}
+	srv.SetChannels([]connModel.Channel{
+		{ID: ownedChannel, Name: "News", Role: connModel.ChannelRoleOwner},
+		{ID: adminChannel, Name: "Club", Role: connModel.ChannelRoleAdmin},
+		{ID: followedChannel, Name: "Weather", Role: connModel.ChannelRoleSubscriber},
+	})
+	return NewChannelService(chats.SessionService, outbox), outbox
+}
+
+func TestPublishToChannel(t *testing.T) {
+	channels, _ := newChannelTest(t)
+	image := &connModel.FileWrapper{Mimetype: "image/png", Url: "https://example.com/a.png"}
+
+	tests := []struct {
+		name      string
+		channelID string
+		text      string
+		image     *connModel.FileWrapper
+		kind      string // outbox kind on success
+		wantErr   error
+	}{
+		{"text to an owned channel", ownedChannel, "Hello", nil, OutboxKindText, nil},
+		{"image to an administered channel", adminChannel, "Look", image, OutboxKindImage, nil},
+		{"to a followed channel", followedChannel, "Hello", nil, "", ErrChannelNotWritable},
+		{"nothing to post", ownedChannel, "  ", nil, "", ErrEmptyChannelPost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post, item, err := channels.Publish(context.Background(), "default", tt.channelID, tt.text, tt.image)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Publish err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if post.Status != models.ChannelPostPublished || post.OutboxMessageID != item.ID || post.PublishedAt == nil {
+				t.Errorf("post %+v is not published as outbox message %d", post, item.ID)
+			}
+			if item.ChatID != tt.channelID || item.Kind != tt.kind {
+				t.Errorf("queued %s to %s, want %s to %s", item.Kind, item.ChatID, tt.kind, tt.channelID)
+			}
+		})
+	}
+
+	if _, _, err := channels.Publish(context.Background(), "default", "120363000000000009@newsletter", "Hello", nil); !connections.IsNotFoundError(err) {
+		t.Errorf("Publish to an unknown channel: err = %v, want a not found error", err)
+	}
+}
+
+func TestChannelDrafts(t *testing.T) {
+	channels, _ := newChannelTest(t)
+
+	draft, err := channels.CreateDraft("default", ownedChannel, "announce the meetup", "Meetup on Friday!", nil)
+	if err != nil {
+		t.Fatalf("CreateDraft: %v", err)
+	}
+	other, err := channels.CreateDraft("default", ownedChannel, "announce the sale", "Sale!", nil)
+	if err != nil {
+		t.Fatalf("CreateDraft: %v", err)
+	}
+
+	// Each step runs on the state the previous ones left behind.
+	tests := []struct {
+		name    string
+		run     func() (*models.ChannelPost, error)
+		status  string
+		wantErr error
+	}{
+		{"edit the draft", func() (*models.ChannelPost, error) {
+			return channels.UpdateDraft("default", draft.ID, "Meetup on Friday at 7!")
+		}, models.ChannelPostDraft, nil},
+		{"empty the draft", func() (*models.ChannelPost, error) {
+			return channels.UpdateDraft("default", draft.ID, " ")
+		}, "", ErrEmptyChannelPost},
+		{"another session approves it", func() (*models.ChannelPost, error) {
+			post, _, err := channels.ApproveDraft(context.Background(), "other", draft.ID)
+			return post, err
+		}, "", ErrChannelPostNotFound},
+		{"approve it", func() (*models.ChannelPost, error) {
+			post, _, err := channels.ApproveDraft(context.Background(), "default", draft.ID)
+			return post, err
+		}, models.ChannelPostPublished, nil},
+		{"approve it again", func() (*models.ChannelPost, error) {
+			post, _, err := channels.ApproveDraft(context.Background(), "default", draft.ID)
+			return post, err
+		}, "", ErrChannelPostNotDraft},
+		{"discard the published post", func() (*models.ChannelPost, error) {
+			return channels.DiscardDraft("default", draft.ID)
+		}, "", ErrChannelPostNotDraft},
+		{"discard the other draft", func() (*models.ChannelPost, error) {
+			return channels.DiscardDraft("default", other.ID)
+		}, models.ChannelPostDiscarded, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post, err := tt.run()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && post.Status != tt.status {
+				t.Errorf("post is %s, want %s", post.Status, tt.status)
+			}
+		})
+	}
+
+	published, err := channels.ListPosts("default", ownedChannel, models.ChannelPostPublished)
+	if err != nil {
+		t.Fatalf("ListPosts: %v", err)
+	}
+	if len(published) != 1 || published[0].Text != "Meetup on Friday at 7!" || published[0].OutboxMessageID == 0 {
+		t.Errorf("published posts = %+v, want the edited meetup post", published)
+	}
+}
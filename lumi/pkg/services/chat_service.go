@@ -1,35 +1,52 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/Mahaveer86619/lumi/pkg/db"
 	"github.com/Mahaveer86619/lumi/pkg/models"
 	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
-	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrChatRegisteredElsewhere = errors.New("chat is registered by another user")
+
 type ChatService struct {
-	WahaClient connections.WahaClient
+	SessionService *SessionService
 }
 
-func NewChatService(wahaClient connections.WahaClient) *ChatService {
+func NewChatService(sessionService *SessionService) *ChatService {
 	return &ChatService{
-		WahaClient: wahaClient,
+		SessionService: sessionService,
 	}
 }
 
-func (s *ChatService) GetRemoteChats() ([]connModel.ChatSummary, error) {
-	return s.WahaClient.GetChats()
+func (s *ChatService) GetRemoteChats(ctx context.Context, userID uint) ([]connModel.ChatSummary, error) {
+	client, err := s.SessionService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetChats(ctx)
 }
 
-func (s *ChatService) GetRemoteGroups() ([]connModel.GroupInfo, error) {
-	return s.WahaClient.GetGroups()
+func (s *ChatService) GetRemoteGroups(ctx context.Context, userID uint) ([]connModel.GroupInfo, error) {
+	client, err := s.SessionService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetGroups(ctx)
 }
 
-func (s *ChatService) GetRegisteredChats() ([]models.RegisteredChat, error) {
+// GetRegisteredChats lists the chats registered on the given session.
+func (s *ChatService) GetRegisteredChats(sessionName string) ([]models.RegisteredChat, error) {
 	var chats []models.RegisteredChat
-	result := db.DB.Find(&chats)
+	result := db.DB.Where("session_name = ?", sessionName).Find(&chats)
 
 	if len(chats) == 0 {
 		log.Println("0 chats registered")
@@ -38,9 +55,20 @@ func (s *ChatService) GetRegisteredChats() ([]models.RegisteredChat, error) {
 	return chats, result.Error
 }
 
-func (s *ChatService) GetRegisteredChat(chatID string) (*models.RegisteredChat, error) {
+func (s *ChatService) GetRegisteredChat(sessionName, chatID string) (*models.RegisteredChat, error) {
 	var chat models.RegisteredChat
-	if err := db.DB.Where("chat_id = ?", chatID).First(&chat).Error; err != nil {
+	if err := db.DB.Where("session_name = ? AND chat_id = ?", sessionName, chatID).First(&chat).Error; err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// FindPlatformChat returns the registration of a chat on a platform other
+// than WhatsApp. Their bots are shared by every user, so such a chat is
+// registered on one session only.
+func (s *ChatService) FindPlatformChat(platform, chatID string) (*models.RegisteredChat, error) {
+	var chat models.RegisteredChat
+	if err := db.DB.Where("platform = ? AND chat_id = ?", platform, chatID).First(&chat).Error; err != nil {
 		return nil, err
 	}
 	return &chat, nil
@@ -50,45 +78,171 @@ func (s *ChatService) UpdateRegisteredChat(chat *models.RegisteredChat) error {
 	return db.DB.Save(chat).Error
 }
 
-func (s *ChatService) RegisterChat(chatID, name, chatType string) (*models.RegisteredChat, error) {
+// UpdateChatSettings changes the presence settings of a registered chat;
+// nil values are left untouched.
+func (s *ChatService) UpdateChatSettings(sessionName, chatID string, showTyping, sendReadReceipts *bool) (*models.RegisteredChat, error) {
+	chat, err := s.GetRegisteredChat(sessionName, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	if showTyping != nil {
+		chat.ShowTyping = *showTyping
+	}
+	if sendReadReceipts != nil {
+		chat.SendReadReceipts = *sendReadReceipts
+	}
+
+	if err := db.DB.Save(chat).Error; err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
+// SetChatTags replaces the tags of a registered chat. Tags are trimmed,
+// lower-cased and deduplicated.
+func (s *ChatService) SetChatTags(sessionName, chatID string, tags []string) (*models.RegisteredChat, error) {
+	chat, err := s.GetRegisteredChat(sessionName, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	chat.Tags = NormalizeTags(tags)
+	if err := db.DB.Save(chat).Error; err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// RegisterChat registers a chat on the given session, the one of the user
+// registering it.
+func (s *ChatService) RegisterChat(sessionName, platform, chatID, name, chatType string) (*models.RegisteredChat, error) {
+	if platform != messenger.PlatformWhatsApp {
+		owner, err := s.FindPlatformChat(platform, chatID)
+		if err == nil && owner.SessionName != sessionName {
+			return nil, ErrChatRegisteredElsewhere
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
 	chat := models.RegisteredChat{
-		ChatID: chatID,
-		Name:   name,
-		Type:   chatType,
+		SessionName: sessionName,
+		ChatID:      chatID,
+		Platform:    platform,
+		Name:        name,
+		Type:        chatType,
 	}
 
-	if err := db.DB.Where("chat_id = ?", chatID).FirstOrCreate(&chat).Error; err != nil {
+	if err := db.DB.Where("session_name = ? AND chat_id = ?", sessionName, chatID).FirstOrCreate(&chat).Error; err != nil {
 		return nil, err
 	}
 	return &chat, nil
 }
 
-func (s *ChatService) UnregisterChat(chatID string) error {
-	return db.DB.Where("chat_id = ?", chatID).Unscoped().Delete(&models.RegisteredChat{}).Error
+func (s *ChatService) UnregisterChat(sessionName, chatID string) error {
+	return db.DB.Where("session_name = ? AND chat_id = ?", sessionName, chatID).Unscoped().Delete(&models.RegisteredChat{}).Error
+}
+
+func (s *ChatService) IsChatAllowed(sessionName, chatID string) bool {
+	var count int64
+	db.DB.Model(&models.RegisteredChat{}).Where("session_name = ? AND chat_id = ?", sessionName, chatID).Count(&count)
+	return count > 0
 }
 
-func (s *ChatService) IsChatAllowed(chatID string) bool {
+// HasMessage reports whether the chat's history holds the WhatsApp message.
+func (s *ChatService) HasMessage(sessionName, chatID, waMessageID string) bool {
 	var count int64
-	db.DB.Model(&models.RegisteredChat{}).Where("chat_id = ?", chatID).Count(&count)
+	db.DB.Model(&models.ChatMessage{}).Where("session_name = ? AND chat_id = ? AND wa_message_id = ?", sessionName, chatID, waMessageID).Count(&count)
 	return count > 0
 }
 
-func (s *ChatService) SaveMessage(chatID, role, content string) error {
+func (s *ChatService) SaveMessage(sessionName, chatID, role, content string) error {
+	now := time.Now()
 	msg := models.ChatMessage{
-		ChatID:  chatID,
-		Role:    role,
-		Content: content,
+		SessionName: sessionName,
+		ChatID:      chatID,
+		Role:        role,
+		Content:     content,
+		SentAt:      &now,
 	}
 	return db.DB.Create(&msg).Error
 }
 
-func (s *ChatService) ClearHistory(chatID string) error {
-	return db.DB.Where("chat_id = ?", chatID).Unscoped().Delete(&models.ChatMessage{}).Error
+// SaveWAMessage saves a message that has a WhatsApp id. A message that is
+// already stored is skipped; the result reports whether it was new.
+func (s *ChatService) SaveWAMessage(sessionName, chatID, role, content, waMessageID string, sentAt time.Time) (bool, error) {
+	if waMessageID == "" {
+		return true, s.SaveMessage(sessionName, chatID, role, content)
+	}
+
+	msg := models.ChatMessage{
+		SessionName: sessionName,
+		ChatID:      chatID,
+		Role:        role,
+		Content:     content,
+		WAMessageID: waMessageID,
+		SentAt:      &sentAt,
+	}
+	result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&msg)
+	return result.RowsAffected > 0, result.Error
+}
+
+// EditMessage updates the stored text of a WhatsApp message of the
+// session, if it is stored at all. An empty chatID matches any chat.
+func (s *ChatService) EditMessage(sessionName, chatID, waMessageID, content string) error {
+	return storedMessage(sessionName, chatID, waMessageID).
+		Updates(map[string]any{"content": content, "edited_at": time.Now()}).Error
+}
+
+// RevokeMessage marks a stored WhatsApp message of the session as deleted
+// for everyone and drops its text, so it no longer reaches the bot's
+// history. An empty chatID matches any chat.
+func (s *ChatService) RevokeMessage(sessionName, chatID, waMessageID string) error {
+	return storedMessage(sessionName, chatID, waMessageID).
+		Updates(map[string]any{"content": "", "revoked_at": time.Now()}).Error
+}
+
+func storedMessage(sessionName, chatID, waMessageID string) *gorm.DB {
+	query := db.DB.Model(&models.ChatMessage{}).Where("session_name = ? AND wa_message_id = ?", sessionName, waMessageID)
+	if chatID != "" {
+		query = query.Where("chat_id = ?", chatID)
+	}
+	return query
+}
+
+// ClearHistory deletes the chat's messages and remembers when, so the
+// history sync does not import them again.
+func (s *ChatService) ClearHistory(sessionName, chatID string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.RegisteredChat{}).
+			Where("session_name = ? AND chat_id = ?", sessionName, chatID).
+			Update("history_cleared_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("session_name = ? AND chat_id = ?", sessionName, chatID).Unscoped().Delete(&models.ChatMessage{}).Error
+	})
 }
 
-func (s *ChatService) GetChatHistory(chatID string, limit int) ([]models.ChatMessage, error) {
+func (s *ChatService) GetChatHistory(sessionName, chatID string, limit int) ([]models.ChatMessage, error) {
 	var messages []models.ChatMessage
-	if err := db.DB.Where("chat_id = ?", chatID).Order("created_at desc").Limit(limit).Find(&messages).Error; err != nil {
+	if err := db.DB.Where("session_name = ? AND chat_id = ? AND revoked_at IS NULL", sessionName, chatID).Order("COALESCE(sent_at, created_at) desc").Limit(limit).Find(&messages).Error; err != nil {
 		return nil, err
 	}
 
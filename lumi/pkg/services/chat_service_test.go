@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/db/dbtest"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
+)
+
+func TestRegisteredChatsAreScopedBySession(t *testing.T) {
+	dbtest.Open(t, &models.RegisteredChat{}, &models.ChatMessage{})
+	chats := NewChatService(nil)
+
+	// Both users talk to the same contact.
+	for _, session := range []string{"alice", "bob"} {
+		if _, err := chats.RegisterChat(session, messenger.PlatformWhatsApp, "123@c.us", "Friend", "chat"); err != nil {
+			t.Fatalf("RegisterChat on %s: %v", session, err)
+		}
+	}
+	if _, err := chats.RegisterChat("alice", messenger.PlatformWhatsApp, "456@g.us", "Book club", "group"); err != nil {
+		t.Fatalf("RegisterChat: %v", err)
+	}
+	if err := chats.SaveMessage("alice", "123@c.us", "user", "hi from alice's side"); err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+	if err := chats.UnregisterChat("bob", "123@c.us"); err != nil {
+		t.Fatalf("UnregisterChat: %v", err)
+	}
+
+	tests := []struct {
+		session, chatID string
+		allowed         bool
+		history         int
+	}{
+		{"alice", "123@c.us", true, 1},
+		{"alice", "456@g.us", true, 0},
+		{"bob", "123@c.us", false, 0},
+		{"bob", "456@g.us", false, 0},
+		{"carol", "123@c.us", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.session+"/"+tt.chatID, func(t *testing.T) {
+			if got := chats.IsChatAllowed(tt.session, tt.chatID); got != tt.allowed {
+				t.Errorf("IsChatAllowed = %v, want %v", got, tt.allowed)
+			}
+
+			_, err := chats.GetRegisteredChat(tt.session, tt.chatID)
+			if found := err == nil; found != tt.allowed {
+				t.Errorf("GetRegisteredChat found = %v (err %v), want %v", found, err, tt.allowed)
+			}
+
+			history, err := chats.GetChatHistory(tt.session, tt.chatID, 10)
+			if err != nil {
+				t.Fatalf("GetChatHistory: %v", err)
+			}
+			if len(history) != tt.history {
+				t.Errorf("history has %d messages, want %d", len(history), tt.history)
+			}
+		})
+	}
+
+	registered, err := chats.GetRegisteredChats("alice")
+	if err != nil {
+		t.Fatalf("GetRegisteredChats: %v", err)
+	}
+	if len(registered) != 2 {
+		t.Errorf("alice has %d registered chats, want 2", len(registered))
+	}
+
+	if err := chats.ClearHistory("bob", "123@c.us"); err != nil {
+		t.Fatalf("ClearHistory: %v", err)
+	}
+	if history, _ := chats.GetChatHistory("alice", "123@c.us", 10); len(history) != 1 {
+		t.Errorf("clearing bob's chat removed alice's history")
+	}
+}
+
+func TestTelegramChatsHaveOneOwner(t *testing.T) {
+	dbtest.Open(t, &models.RegisteredChat{})
+	chats := NewChatService(nil)
+
+	// Telegram's bot is shared, so its chats belong to whoever registers
+	// them first; WhatsApp chats belong to each user's own session.
+	tests := []struct {
+		name, session, platform, chatID string
+		wantErr                         error
+	}{
+		{"alice registers a Telegram group", "alice", messenger.PlatformTelegram, "-100123", nil},
+		{"alice registers it again", "alice", messenger.PlatformTelegram, "-100123", nil},
+		{"bob registers the same group", "bob", messenger.PlatformTelegram, "-100123", ErrChatRegisteredElsewhere},
+		{"bob registers another group", "bob", messenger.PlatformTelegram, "-100456", nil},
+		{"both register a WhatsApp contact", "alice", messenger.PlatformWhatsApp, "123@c.us", nil},
+		{"bob registers the contact too", "bob", messenger.PlatformWhatsApp, "123@c.us", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat, err := chats.RegisterChat(tt.session, tt.platform, tt.chatID, "Chat", "group")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RegisterChat err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && chat.Platform != tt.platform {
+				t.Errorf("chat registered on %q, want %q", chat.Platform, tt.platform)
+			}
+		})
+	}
+
+	owner, err := chats.FindPlatformChat(messenger.PlatformTelegram, "-100123")
+	if err != nil || owner.SessionName != "alice" {
+		t.Errorf("FindPlatformChat = %+v, %v; want alice's chat", owner, err)
+	}
+}
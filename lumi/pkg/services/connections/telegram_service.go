@@ -0,0 +1,166 @@
+package connections
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+// TelegramClient talks to the Telegram Bot API as one bot.
+type TelegramClient interface {
+	GetMe(ctx context.Context) (*models.TGUser, error)
+	GetChat(ctx context.Context, chatID int64) (*models.TGChat, error)
+	SendMessage(ctx context.Context, message models.TGSendMessageRequest) (*models.TGMessage, error)
+	SendChatAction(ctx context.Context, chatID int64, action string) error
+
+	// GetUpdates long-polls for updates from offset on, waiting up to
+	// timeout for the first one.
+	GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]models.TGUpdate, error)
+}
+
+type TelegramService struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+func NewTelegramService() TelegramClient {
+	return NewTelegramClient(config.GConfig.TelegramAPIURL, config.GConfig.TelegramBotToken)
+}
+
+// NewTelegramClient builds a client against an explicit Bot API server,
+// e.g. a telegramtest server.
+func NewTelegramClient(baseURL, token string) TelegramClient {
+	return &TelegramService{
+		httpClient: &http.Client{},
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+	}
+}
+
+// TelegramError is a call the Bot API answered with ok=false.
+type TelegramError struct {
+	StatusCode  int
+	Description string
+	RetryAfter  time.Duration // set on 429 Too Many Requests
+}
+
+func (e *TelegramError) Error() string {
+	return fmt.Sprintf("telegram (%d): %s", e.StatusCode, e.Description)
+}
+
+func AsTelegramError(err error) (*TelegramError, bool) {
+	var tgErr *TelegramError
+	ok := errors.As(err, &tgErr)
+	return tgErr, ok
+}
+
+func (s *TelegramService) GetMe(ctx context.Context) (*models.TGUser, error) {
+	var me models.TGUser
+	if err := s.call(ctx, "getMe", nil, &me); err != nil {
+		return nil, err
+	}
+	return &me, nil
+}
+
+func (s *TelegramService) GetChat(ctx context.Context, chatID int64) (*models.TGChat, error) {
+	var chat models.TGChat
+	if err := s.call(ctx, "getChat", models.TGGetChatRequest{ChatID: chatID}, &chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+func (s *TelegramService) SendMessage(ctx context.Context, message models.TGSendMessageRequest) (*models.TGMessage, error) {
+	var sent models.TGMessage
+	if err := s.call(ctx, "sendMessage", message, &sent); err != nil {
+		return nil, err
+	}
+	return &sent, nil
+}
+
+func (s *TelegramService) SendChatAction(ctx context.Context, chatID int64, action string) error {
+	return s.call(ctx, "sendChatAction", models.TGChatActionRequest{ChatID: chatID, Action: action}, nil)
+}
+
+func (s *TelegramService) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]models.TGUpdate, error) {
+	// The HTTP request has to outlive the long poll.
+	ctx, cancel := withDefaultTimeout(ctx, timeout+defaultRequestTimeout)
+	defer cancel()
+
+	req := models.TGGetUpdatesRequest{
+		Offset:         offset,
+		Timeout:        int(timeout.Seconds()),
+		AllowedUpdates: []string{"message"},
+	}
+
+	var updates []models.TGUpdate
+	if err := s.call(ctx, "getUpdates", req, &updates); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// call posts payload to a Bot API method and decodes its result into v.
+func (s *TelegramService) call(ctx context.Context, method string, payload, v interface{}) error {
+	ctx, cancel := withDefaultTimeout(ctx, defaultRequestTimeout)
+	defer cancel()
+
+	body := []byte("{}")
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/%s", s.baseURL, s.token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		// The URL carries the bot token, so it must not end up in logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var response models.TGResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("telegram %s: failed to decode response (status %d): %w", method, resp.StatusCode, err)
+	}
+
+	if !response.OK {
+		tgErr := &TelegramError{StatusCode: response.ErrorCode, Description: response.Description}
+		if tgErr.StatusCode == 0 {
+			tgErr.StatusCode = resp.StatusCode
+		}
+		if response.Parameters != nil {
+			tgErr.RetryAfter = time.Duration(response.Parameters.RetryAfter) * time.Second
+		}
+		return tgErr
+	}
+
+	if v != nil {
+		if err := json.Unmarshal(response.Result, v); err != nil {
+			return fmt.Errorf("telegram %s: failed to decode result: %w", method, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+// Package telegramtest provides an in-process fake of the Telegram Bot API so
+// that code built on connections.TelegramClient can be exercised without a
+// real bot.
+//
+// A typical setup points Lumi's config at the fake:
+//
+//	srv := telegramtest.NewServer()
+//	defer srv.Close()
+//	config.GConfig = &config.Config{TelegramAPIURL: srv.URL, TelegramBotToken: srv.Token}
+package telegramtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+)
+
+const DefaultToken = "123456:telegramtest-token"
+
+// ChatAction is a sendChatAction call recorded by the fake.
+type ChatAction struct {
+	ChatID int64
+	Action string
+}
+
+type Server struct {
+	*httptest.Server
+
+	Token string
+	Me    models.TGUser
+
+	mu       sync.Mutex
+	chats    map[int64]models.TGChat
+	updates  []models.TGUpdate // not yet confirmed by an offset
+	sent     []models.TGMessage
+	actions  []ChatAction
+	failures map[string]int
+	msgSeq   map[int64]int // message ids are numbered per chat
+	nextID   int64         // next update_id
+	arrived  chan struct{} // closed and replaced when an update is added
+}
+
+func NewServer() *Server {
+	s := &Server{
+		Token:    DefaultToken,
+		Me:       models.TGUser{ID: 123456, IsBot: true, FirstName: "Lumi", Username: "lumi_test_bot"},
+		chats:    make(map[int64]models.TGChat),
+		failures: make(map[string]int),
+		msgSeq:   make(map[int64]int),
+		nextID:   1,
+		arrived:  make(chan struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.route))
+	return s
+}
+
+// Client returns a TelegramClient for the fake's bot.
+func (s *Server) Client() connections.TelegramClient {
+	return connections.NewTelegramClient(s.URL, s.Token)
+}
+
+// --- Test Controls ---
+
+// AddChat makes chat known to getChat and to Receive.
+func (s *Server) AddChat(chat models.TGChat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats[chat.ID] = chat
+}
+
+// Receive simulates from writing text in chatID and queues the update for
+// getUpdates. Chats not added with AddChat are private chats.
+func (s *Server) Receive(chatID int64, from models.TGUser, text string) models.TGUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := s.newMessage(chatID, text)
+	msg.From = &from
+
+	update := models.TGUpdate{UpdateID: s.nextID, Message: &msg}
+	s.nextID++
+	s.updates = append(s.updates, update)
+
+	close(s.arrived)
+	s.arrived = make(chan struct{})
+	return update
+}
+
+// FailNext makes the next call of method fail with status. A 429 asks the
+// client to retry after a second.
+func (s *Server) FailNext(method string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = status
+}
+
+// Sent returns a copy of every message the bot sent.
+func (s *Server) Sent() []models.TGMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.TGMessage(nil), s.sent...)
+}
+
+// SentTo returns the messages the bot sent to chatID.
+func (s *Server) SentTo(chatID int64) []models.TGMessage {
+	var out []models.TGMessage
+	for _, m := range s.Sent() {
+		if m.Chat.ID == chatID {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Actions returns a copy of every recorded chat action.
+func (s *Server) Actions() []ChatAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ChatAction(nil), s.actions...)
+}
+
+// Pending returns how many updates the bot has not confirmed yet.
+func (s *Server) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.updates)
+}
+
+// --- Routing ---
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	// Paths look like /bot<token>/<method>.
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] != "bot"+s.Token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	method := parts[1]
+
+	if status, ok := s.takeFailure(method); ok {
+		writeError(w, status, "injected failure")
+		return
+	}
+
+	switch method {
+	case "getMe":
+		writeResult(w, s.Me)
+	case "getChat":
+		s.handleGetChat(w, r)
+	case "sendMessage":
+		s.handleSendMessage(w, r)
+	case "sendChatAction":
+		s.handleChatAction(w, r)
+	case "getUpdates":
+		s.handleGetUpdates(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found: method not found")
+	}
+}
+
+func (s *Server) handleGetChat(w http.ResponseWriter, r *http.Request) {
+	var req models.TGGetChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: invalid payload")
+		return
+	}
+
+	s.mu.Lock()
+	chat, ok := s.chats[req.ChatID]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad Request: chat not found")
+		return
+	}
+	writeResult(w, chat)
+}
+
+func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	var req models.TGSendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: invalid payload")
+		return
+	}
+	if req.Text == "" {
+		writeError(w, http.StatusBadRequest, "Bad Request: message text is empty")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Bots can only write to chats they know or have heard from.
+	if _, ok := s.chats[req.ChatID]; !ok && s.msgSeq[req.ChatID] == 0 {
+		writeError(w, http.StatusBadRequest, "Bad Request: chat not found")
+		return
+	}
+
+	msg := s.newMessage(req.ChatID, req.Text)
+	me := s.Me
+	msg.From = &me
+	if req.ReplyParameters != nil {
+		msg.ReplyToMessage = &models.TGMessage{MessageID: req.ReplyParameters.MessageID, Chat: msg.Chat}
+	}
+
+	s.sent = append(s.sent, msg)
+	writeResult(w, msg)
+}
+
+func (s *Server) handleChatAction(w http.ResponseWriter, r *http.Request) {
+	var req models.TGChatActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: invalid payload")
+		return
+	}
+
+	s.mu.Lock()
+	s.actions = append(s.actions, ChatAction{ChatID: req.ChatID, Action: req.Action})
+	s.mu.Unlock()
+
+	writeResult(w, true)
+}
+
+// handleGetUpdates confirms the updates before offset and answers with the
+// rest, waiting up to timeout for one to arrive if there are none.
+func (s *Server) handleGetUpdates(w http.ResponseWriter, r *http.Request) {
+	var req models.TGGetUpdatesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: invalid payload")
+		return
+	}
+
+	deadline := time.After(time.Duration(req.Timeout) * time.Second)
+	for {
+		s.mu.Lock()
+		kept := s.updates[:0]
+		for _, u := range s.updates {
+			if u.UpdateID >= req.Offset {
+				kept = append(kept, u)
+			}
+		}
+		s.updates = kept
+		pending := append([]models.TGUpdate{}, s.updates...)
+		arrived := s.arrived
+		s.mu.Unlock()
+
+		if len(pending) > 0 || req.Timeout == 0 {
+			writeResult(w, pending)
+			return
+		}
+
+		select {
+		case <-arrived:
+		case <-deadline:
+			writeResult(w, []models.TGUpdate{})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// --- Helpers ---
+
+func (s *Server) newMessage(chatID int64, text string) models.TGMessage {
+	chat, ok := s.chats[chatID]
+	if !ok {
+		chat = models.TGChat{ID: chatID, Type: models.TGChatPrivate}
+	}
+
+	s.msgSeq[chatID]++
+	return models.TGMessage{
+		MessageID: s.msgSeq[chatID],
+		Chat:      chat,
+		Date:      time.Now().Unix(),
+		Text:      text,
+	}
+}
+
+func (s *Server) takeFailure(method string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.failures[method]
+	if ok {
+		delete(s.failures, method)
+	}
+	return status, ok
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	raw, _ := json.Marshal(result)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TGResponse{OK: true, Result: raw})
+}
+
+// writeError answers in the Bot API's error shape.
+func writeError(w http.ResponseWriter, status int, description string) {
+	resp := models.TGResponse{ErrorCode: status, Description: description}
+	if status == http.StatusTooManyRequests {
+		resp.Parameters = &models.TGResponseParameters{RetryAfter: 1}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,127 @@
+package connections
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type WahaErrorCode string
+
+const (
+	WahaErrSessionState WahaErrorCode = "session_state" // session is not in one of the expected states
+	WahaErrNotFound     WahaErrorCode = "not_found"
+	WahaErrBadRequest   WahaErrorCode = "bad_request"
+	WahaErrUnauthorized WahaErrorCode = "unauthorized" // WAHA rejected our API key
+	WahaErrUnavailable  WahaErrorCode = "unavailable"  // WAHA unreachable or failing
+)
+
+// WahaError is a failed call to WAHA. StatusCode is WAHA's HTTP status, or
+// 0 when WAHA could not be reached at all.
+type WahaError struct {
+	StatusCode int
+	Code       WahaErrorCode
+	Message    string
+	Session    string
+	Status     string   // session status WAHA reported
+	Expected   []string // session statuses the call needed
+	Err        error    // transport error, if any
+}
+
+func (e *WahaError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "waha %s", e.Code)
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, " (%d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if e.Status != "" {
+		fmt.Fprintf(&b, " [session %s is %s, expected %s]", e.Session, e.Status, strings.Join(e.Expected, "|"))
+	}
+	return b.String()
+}
+
+func (e *WahaError) Unwrap() error {
+	return e.Err
+}
+
+// AsWahaError returns the WahaError in err's chain, if there is one.
+func AsWahaError(err error) (*WahaError, bool) {
+	var wahaErr *WahaError
+	ok := errors.As(err, &wahaErr)
+	return wahaErr, ok
+}
+
+// IsSessionStateError reports whether err says the session is not in a
+// state that allows the call, e.g. not started or not paired yet.
+func IsSessionStateError(err error) bool {
+	wahaErr, ok := AsWahaError(err)
+	return ok && wahaErr.Code == WahaErrSessionState
+}
+
+func IsNotFoundError(err error) bool {
+	wahaErr, ok := AsWahaError(err)
+	return ok && wahaErr.Code == WahaErrNotFound
+}
+
+// newWahaError builds a WahaError from a non-2xx WAHA response. WAHA answers
+// either {"error", "session", "status", "expected"} for session state
+// problems or NestJS' {"statusCode", "message", "error"}.
+func newWahaError(statusCode int, body []byte) *WahaError {
+	var raw struct {
+		Error    string          `json:"error"`
+		Message  json.RawMessage `json:"message"`
+		Session  string          `json:"session"`
+		Status   string          `json:"status"`
+		Expected []string        `json:"expected"`
+	}
+	json.Unmarshal(body, &raw)
+
+	e := &WahaError{
+		StatusCode: statusCode,
+		Message:    raw.Error,
+		Session:    raw.Session,
+		Status:     raw.Status,
+		Expected:   raw.Expected,
+	}
+
+	// NestJS puts the details in "message" (a string or a list of
+	// validation errors) and the status text in "error".
+	var message string
+	var messages []string
+	if json.Unmarshal(raw.Message, &message) == nil && message != "" {
+		e.Message = message
+	} else if json.Unmarshal(raw.Message, &messages) == nil && len(messages) > 0 {
+		e.Message = strings.Join(messages, "; ")
+	}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+	}
+
+	switch {
+	case len(e.Expected) > 0 || (statusCode == http.StatusUnprocessableEntity && e.Status != ""):
+		e.Code = WahaErrSessionState
+	case statusCode == http.StatusNotFound:
+		e.Code = WahaErrNotFound
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		e.Code = WahaErrUnauthorized
+	case statusCode >= 500:
+		e.Code = WahaErrUnavailable
+	default:
+		e.Code = WahaErrBadRequest
+	}
+	return e
+}
+
+// newTransportError wraps a failure to reach WAHA.
+func newTransportError(err error) *WahaError {
+	return &WahaError{
+		Code:    WahaErrUnavailable,
+		Message: err.Error(),
+		Err:     err,
+	}
+}
@@ -0,0 +1,60 @@
+package connections
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestNewWahaError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		code     WahaErrorCode
+		message  string
+		expected []string
+	}{
+		{
+			"session not ready", http.StatusUnprocessableEntity,
+			`{"error":"Session status is not as expected","session":"lumi","status":"SCAN_QR_CODE","expected":["WORKING"]}`,
+			WahaErrSessionState, "Session status is not as expected", []string{"WORKING"},
+		},
+		{"not found", http.StatusNotFound, `{"statusCode":404,"message":"Chat not found","error":"Not Found"}`, WahaErrNotFound, "Chat not found", nil},
+		{"validation errors", http.StatusBadRequest, `{"statusCode":400,"message":["chatId must be a string","text should not be empty"],"error":"Bad Request"}`, WahaErrBadRequest, "chatId must be a string; text should not be empty", nil},
+		{"wrong api key", http.StatusUnauthorized, `{"message":"Unauthorized","statusCode":401}`, WahaErrUnauthorized, "Unauthorized", nil},
+		{"crash", http.StatusInternalServerError, `Internal Server Error`, WahaErrUnavailable, "Internal Server Error", nil},
+		{"bad gateway", http.StatusBadGateway, ``, WahaErrUnavailable, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newWahaError(tt.status, []byte(tt.body))
+			if e.Code != tt.code || e.Message != tt.message || e.StatusCode != tt.status || fmt.Sprint(e.Expected) != fmt.Sprint(tt.expected) {
+				t.Errorf("newWahaError = %+v, want code %s, message %q, expected %v", e, tt.code, tt.message, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAsWahaError(t *testing.T) {
+	transport := errors.New("connection refused")
+	wrapped := fmt.Errorf("sending: %w", newTransportError(transport))
+
+	wahaErr, ok := AsWahaError(wrapped)
+	if !ok || wahaErr.Code != WahaErrUnavailable || !errors.Is(wrapped, transport) {
+		t.Errorf("AsWahaError(%v) = %+v, %v", wrapped, wahaErr, ok)
+	}
+	if _, ok := AsWahaError(transport); ok {
+		t.Errorf("a plain error was taken for a WahaError")
+	}
+
+	stateErr := fmt.Errorf("wrapped: %w", newWahaError(http.StatusUnprocessableEntity, []byte(`{"status":"STOPPED","expected":["WORKING"]}`)))
+	if !IsSessionStateError(stateErr) || IsNotFoundError(stateErr) {
+		t.Errorf("session state error misclassified: %v", stateErr)
+	}
+	if !IsNotFoundError(newWahaError(http.StatusNotFound, nil)) {
+		t.Errorf("404 is not a not-found error")
+	}
+}
@@ -0,0 +1,141 @@
+package connections
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsMinBackoff = time.Second
+	wsMaxBackoff = time.Minute
+
+	// A connection that stayed up this long counts as healthy, and the
+	// next reconnect starts from wsMinBackoff again.
+	wsStableAfter = time.Minute
+
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 75 * time.Second
+	wsWriteWait    = 10 * time.Second
+)
+
+// EventStream reads the events of all sessions from WAHA's /ws socket, for
+// deployments WAHA can't deliver webhooks to.
+type EventStream struct {
+	url    string
+	apiKey string
+	dialer *websocket.Dialer
+}
+
+func NewEventStream(baseURL, apiKey string) *EventStream {
+	url := strings.Replace(strings.TrimRight(baseURL, "/"), "http", "ws", 1) + "/ws"
+
+	query := neturl.Values{}
+	query.Set("session", "*")
+	query.Set("events", "*")
+	if apiKey != "" {
+		query.Set("x-api-key", apiKey)
+	}
+
+	return &EventStream{
+		url:    url + "?" + query.Encode(),
+		apiKey: apiKey,
+		dialer: &websocket.Dialer{HandshakeTimeout: defaultRequestTimeout},
+	}
+}
+
+// Run hands every event to handle until ctx is cancelled, reconnecting
+// with exponential backoff whenever the socket drops.
+func (s *EventStream) Run(ctx context.Context, handle func(ctx context.Context, event models.WAHAWebhook)) {
+	backoff := wsMinBackoff
+
+	for {
+		started := time.Now()
+		err := s.consume(ctx, handle)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) > wsStableAfter {
+			backoff = wsMinBackoff
+		}
+		log.Printf("WAHA events: socket closed (%v), reconnecting in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, wsMaxBackoff)
+	}
+}
+
+// consume reads one connection until it fails or ctx is cancelled.
+func (s *EventStream) consume(ctx context.Context, handle func(ctx context.Context, event models.WAHAWebhook)) error {
+	header := http.Header{}
+	if s.apiKey != "" {
+		header.Set("X-Api-Key", s.apiKey)
+	}
+
+	conn, resp, err := s.dialer.DialContext(ctx, s.url, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("dial: %w (status %d)", err, resp.StatusCode)
+		}
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+	log.Printf("WAHA events: connected")
+
+	// Closing the connection is what unblocks ReadMessage on shutdown.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(wsWriteWait))
+				conn.Close()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var event models.WAHAWebhook
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Printf("WAHA events: skipping malformed event: %v", err)
+			continue
+		}
+		handle(ctx, event)
+	}
+}
@@ -2,10 +2,15 @@ package connections
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/Mahaveer86619/lumi/pkg/config"
@@ -14,23 +19,93 @@ import (
 
 type WahaClient interface {
 	// Lifecycle
-	Ping() error
-	StartSession() error
-	StopSession() error
-	RestartSession() error
-	GetSessionStatus() (*models.SessionInfo, error)
-	GetQRCode() ([]byte, error)
-	RequestCode(phoneNumber string, method string) (*models.RequestCodeResponse, error)
-	GetMe() (*models.MeInfo, error)
+	Ping(ctx context.Context) error
+	StartSession(ctx context.Context) error
+	StopSession(ctx context.Context) error
+	RestartSession(ctx context.Context) error
+	GetSessionStatus(ctx context.Context) (*models.SessionInfo, error)
+	GetQRCode(ctx context.Context) ([]byte, error)
+	GetQR(ctx context.Context, format string) (*models.QRCode, error)
+	RequestCode(ctx context.Context, phoneNumber string, method string) (*models.RequestCodeResponse, error)
+	GetMe(ctx context.Context) (*models.MeInfo, error)
 
 	// Chatting
-	SendText(chatId, text string) (*models.WAMessage, error)
-	SendImage(chatId string, image models.ImagePayload) (*models.WAMessage, error)
-	CheckNumberExists(phone string) (*models.WANumberExistResult, error)
-	GetChats() ([]models.ChatSummary, error)
-	GetGroups() ([]models.GroupInfo, error)
+	SendText(ctx context.Context, chatId, text string) (*models.WAMessage, error)
+	ReplyText(ctx context.Context, chatId, replyTo, text string) (*models.WAMessage, error)
+	SendImage(ctx context.Context, chatId string, image models.ImagePayload) (*models.WAMessage, error)
+	SendVideo(ctx context.Context, chatId string, video models.VideoPayload) (*models.WAMessage, error)
+	SendVoice(ctx context.Context, chatId string, voice models.VoicePayload) (*models.WAMessage, error)
+	SendFile(ctx context.Context, chatId string, file models.FilePayload) (*models.WAMessage, error)
+	SendLocation(ctx context.Context, chatId string, location models.LocationPayload) (*models.WAMessage, error)
+	SendContactVcard(ctx context.Context, chatId string, contacts []models.ContactVcard) (*models.WAMessage, error)
+	SendPoll(ctx context.Context, chatId string, poll models.PollPayload) (*models.WAMessage, error)
+	SendReaction(ctx context.Context, messageId, reaction string) error
+	EditMessage(ctx context.Context, chatId, messageId, text string) error
+	DeleteMessage(ctx context.Context, chatId, messageId string) error
+	GetChats(ctx context.Context) ([]models.ChatSummary, error)
+	GetChatMessages(ctx context.Context, chatId string, query models.ChatMessagesQuery) ([]models.WAMessage, error)
+	GetGroups(ctx context.Context) ([]models.GroupInfo, error)
+
+	// Contacts
+	CheckNumberExists(ctx context.Context, phone string) (*models.WANumberExistResult, error)
+	GetContacts(ctx context.Context) ([]models.WAContact, error)
+	GetContact(ctx context.Context, contactId string) (*models.WAContact, error)
+	GetContactAbout(ctx context.Context, contactId string) (string, error)
+	GetProfilePicture(ctx context.Context, contactId string, refresh bool) (string, error)
+	BlockContact(ctx context.Context, contactId string) error
+	UnblockContact(ctx context.Context, contactId string) error
+
+	// Presence
+	StartTyping(ctx context.Context, chatId string) error
+	StopTyping(ctx context.Context, chatId string) error
+	SendSeen(ctx context.Context, chatId string, messageIds ...string) error
+
+	// Groups
+	CreateGroup(ctx context.Context, name string, participants []string) (*models.GroupInfo, error)
+	GetGroup(ctx context.Context, groupId string) (*models.GroupInfo, error)
+	AddGroupParticipants(ctx context.Context, groupId string, participants []string) error
+	RemoveGroupParticipants(ctx context.Context, groupId string, participants []string) error
+	PromoteGroupAdmins(ctx context.Context, groupId string, participants []string) error
+	DemoteGroupAdmins(ctx context.Context, groupId string, participants []string) error
+	SetGroupSubject(ctx context.Context, groupId, subject string) error
+	SetGroupDescription(ctx context.Context, groupId, description string) error
+	SetGroupPicture(ctx context.Context, groupId string, file models.FileWrapper) error
+	GetGroupInviteCode(ctx context.Context, groupId string) (string, error)
+	RevokeGroupInviteCode(ctx context.Context, groupId string) (string, error)
+
+	// Channels
+	GetChannels(ctx context.Context, role string) ([]models.Channel, error)
+	GetChannel(ctx context.Context, channelId string) (*models.Channel, error)
+	CreateChannel(ctx context.Context, channel models.ChannelCreateRequest) (*models.Channel, error)
+
+	// Status
+	NewStatusMessageID(ctx context.Context) (string, error)
+	PostTextStatus(ctx context.Context, status models.StatusTextRequest) error
+	PostImageStatus(ctx context.Context, status models.StatusMediaRequest) error
+	PostVideoStatus(ctx context.Context, status models.StatusMediaRequest) error
+	DeleteStatus(ctx context.Context, statusId string) error
+
+	// Media
+	DownloadMedia(ctx context.Context, media models.WAMedia) ([]byte, error)
 }
 
+const (
+	// Applied to a single WAHA call when the caller's context has no deadline.
+	defaultRequestTimeout = 30 * time.Second
+	// Upper bound for waiting on a session to leave STARTING.
+	sessionReadyTimeout = 20 * time.Second
+	// Largest media file DownloadMedia reads into memory.
+	maxMediaSize = 64 << 20
+	// Largest pairing QR image GetQRCode accepts; a real one is a few KB.
+	maxQRSize = 1 << 20
+)
+
+var (
+	ErrInvalidMediaURL = errors.New("media url is not a WAHA file url")
+	ErrMediaTooLarge   = fmt.Errorf("media is larger than %d MB", maxMediaSize>>20)
+	ErrQRTooLarge      = fmt.Errorf("pairing QR is larger than %d MB", maxQRSize>>20)
+)
+
 type WahaService struct {
 	httpClient  *http.Client
 	baseURL     string
@@ -38,20 +113,26 @@ type WahaService struct {
 	apiKey      string
 }
 
-func NewWahaService() WahaClient {
+func NewWahaService(sessionName string) WahaClient {
+	return NewWahaClient(config.GConfig.WahaServiceURL, config.GConfig.WahaAPIKey, sessionName)
+}
+
+// NewWahaClient builds a client against an explicit WAHA instance, e.g. a
+// wahatest server.
+func NewWahaClient(baseURL, apiKey, sessionName string) WahaClient {
 	return &WahaService{
-		httpClient:  &http.Client{Timeout: 60 * time.Second},
-		baseURL:     config.GConfig.WahaServiceURL,
-		sessionName: config.GConfig.WahaSessionName,
-		apiKey:      config.GConfig.WahaAPIKey,
+		httpClient:  &http.Client{},
+		baseURL:     baseURL,
+		sessionName: sessionName,
+		apiKey:      apiKey,
 	}
 }
 
 // --- Lifecycle Methods ---
 
-func (s *WahaService) Ping() error {
+func (s *WahaService) Ping(ctx context.Context) error {
 	url := fmt.Sprintf("%s/ping", s.baseURL)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
@@ -59,9 +140,9 @@ func (s *WahaService) Ping() error {
 	return s.doRequest(req, nil)
 }
 
-func (s *WahaService) GetSessionStatus() (*models.SessionInfo, error) {
+func (s *WahaService) GetSessionStatus(ctx context.Context) (*models.SessionInfo, error) {
 	url := fmt.Sprintf("%s/api/sessions/%s", s.baseURL, s.sessionName)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -73,17 +154,17 @@ func (s *WahaService) GetSessionStatus() (*models.SessionInfo, error) {
 	return &sessionInfo, nil
 }
 
-func (s *WahaService) StartSession() error {
-	info, err := s.GetSessionStatus()
+func (s *WahaService) StartSession(ctx context.Context) error {
+	info, err := s.GetSessionStatus(ctx)
 
 	if err != nil {
-		if err := s.createSession(); err != nil {
+		if err := s.createSession(ctx); err != nil {
 			return fmt.Errorf("failed to create session after status check failed: %w", err)
 		}
 	} else if info != nil {
 		switch info.Status {
 		case "STOPPED", "FAILED":
-			if err := s.startExistingSession(); err != nil {
+			if err := s.startExistingSession(ctx); err != nil {
 				return err
 			}
 		case "WORKING", "SCAN_QR_CODE", "STARTING":
@@ -91,10 +172,10 @@ func (s *WahaService) StartSession() error {
 		}
 	}
 
-	return s.waitForSessionReady()
+	return s.waitForSessionReady(ctx)
 }
 
-func (s *WahaService) createSession() error {
+func (s *WahaService) createSession(ctx context.Context) error {
 	url := fmt.Sprintf("%s/api/sessions", s.baseURL)
 
 	payload := models.SessionCreateRequest{
@@ -104,7 +185,7 @@ func (s *WahaService) createSession() error {
 	}
 
 	jsonPayload, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return err
 	}
@@ -112,58 +193,85 @@ func (s *WahaService) createSession() error {
 	return s.doRequest(req, nil)
 }
 
-func (s *WahaService) startExistingSession() error {
+func (s *WahaService) startExistingSession(ctx context.Context) error {
 	url := fmt.Sprintf("%s/api/sessions/%s/start", s.baseURL, s.sessionName)
-	req, err := http.NewRequest("POST", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
 		return err
 	}
 	return s.doRequest(req, nil)
 }
 
-func (s *WahaService) StopSession() error {
+func (s *WahaService) StopSession(ctx context.Context) error {
 	url := fmt.Sprintf("%s/api/sessions/%s/stop", s.baseURL, s.sessionName)
-	req, err := http.NewRequest("POST", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
 		return err
 	}
 	return s.doRequest(req, nil)
 }
 
-func (s *WahaService) RestartSession() error {
+func (s *WahaService) RestartSession(ctx context.Context) error {
 	url := fmt.Sprintf("%s/api/sessions/%s/restart", s.baseURL, s.sessionName)
-	req, err := http.NewRequest("POST", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
 		return err
 	}
 	s.doRequest(req, nil)
 
-	return s.waitForSessionReady()
+	return s.waitForSessionReady(ctx)
 }
 
-func (s *WahaService) GetQRCode() ([]byte, error) {
+func (s *WahaService) GetQRCode(ctx context.Context) ([]byte, error) {
 	url := fmt.Sprintf("%s/api/%s/auth/qr?format=image", s.baseURL, s.sessionName)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 	s.addHeaders(req)
 
-	resp, err := s.httpClient.Do(req)
+	ctx, cancel := withDefaultTimeout(ctx, defaultRequestTimeout)
+	defer cancel()
+
+	resp, err := s.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return nil, newTransportError(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get QR code: %d %s", resp.StatusCode, string(body))
+		return nil, newWahaError(resp.StatusCode, body)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxQRSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxQRSize {
+		return nil, ErrQRTooLarge
+	}
+	return data, nil
+}
+
+// GetQR fetches the pairing QR as JSON, either as its raw value or as a
+// base64 image depending on format.
+func (s *WahaService) GetQR(ctx context.Context, format string) (*models.QRCode, error) {
+	url := fmt.Sprintf("%s/api/%s/auth/qr?format=%s", s.baseURL, s.sessionName, neturl.QueryEscape(format))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Accept", "application/json")
 
-	return io.ReadAll(resp.Body)
+	var qr models.QRCode
+	if err := s.doRequest(req, &qr); err != nil {
+		return nil, err
+	}
+	return &qr, nil
 }
 
-func (s *WahaService) RequestCode(phoneNumber string, method string) (*models.RequestCodeResponse, error) {
+func (s *WahaService) RequestCode(ctx context.Context, phoneNumber string, method string) (*models.RequestCodeResponse, error) {
 	url := fmt.Sprintf("%s/api/%s/auth/request-code", s.baseURL, s.sessionName)
 
 	payload := make(map[string]string)
@@ -178,7 +286,7 @@ func (s *WahaService) RequestCode(phoneNumber string, method string) (*models.Re
 
 	for i := 0; i < maxRetries; i++ {
 		jsonPayload, _ := json.Marshal(payload)
-		req, reqErr := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+		req, reqErr := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
 		if reqErr != nil {
 			return nil, reqErr
 		}
@@ -191,16 +299,20 @@ func (s *WahaService) RequestCode(phoneNumber string, method string) (*models.Re
 		}
 
 		if i < maxRetries-1 {
-			time.Sleep(2 * time.Second)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
 		}
 	}
 
 	return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries, err)
 }
 
-func (s *WahaService) GetMe() (*models.MeInfo, error) {
+func (s *WahaService) GetMe(ctx context.Context) (*models.MeInfo, error) {
 	url := fmt.Sprintf("%s/api/sessions/%s/me", s.baseURL, s.sessionName)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -214,54 +326,163 @@ func (s *WahaService) GetMe() (*models.MeInfo, error) {
 
 // --- Chatting Methods ---
 
-func (s *WahaService) SendText(chatId, text string) (*models.WAMessage, error) {
-	url := fmt.Sprintf("%s/api/sendText", s.baseURL)
+func (s *WahaService) SendText(ctx context.Context, chatId, text string) (*models.WAMessage, error) {
+	return s.ReplyText(ctx, chatId, "", text)
+}
 
+// ReplyText sends text quoting the message replyTo; an empty replyTo sends a
+// plain message.
+func (s *WahaService) ReplyText(ctx context.Context, chatId, replyTo, text string) (*models.WAMessage, error) {
 	payload := models.MessageTextRequest{
 		ChatID:  chatId,
 		Text:    text,
 		Session: s.sessionName,
+		ReplyTo: replyTo,
 	}
+	return s.sendMessage(ctx, "sendText", payload)
+}
 
-	jsonPayload, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return nil, err
+func (s *WahaService) SendImage(ctx context.Context, chatId string, image models.ImagePayload) (*models.WAMessage, error) {
+	payload := models.MessageImageRequest{
+		ChatID:  chatId,
+		Session: s.sessionName,
+		Caption: image.Caption,
+		File:    image.File,
+		ReplyTo: image.ReplyTo,
 	}
+	return s.sendMessage(ctx, "sendImage", payload)
+}
 
-	var response models.WAMessage
-	if err := s.doRequest(req, &response); err != nil {
-		return nil, err
+func (s *WahaService) SendVideo(ctx context.Context, chatId string, video models.VideoPayload) (*models.WAMessage, error) {
+	payload := models.MessageVideoRequest{
+		ChatID:  chatId,
+		Session: s.sessionName,
+		Caption: video.Caption,
+		File:    video.File,
+		AsNote:  video.AsNote,
+		Convert: video.Convert,
+		ReplyTo: video.ReplyTo,
 	}
-	return &response, nil
+	return s.sendMessage(ctx, "sendVideo", payload)
 }
 
-func (s *WahaService) SendImage(chatId string, image models.ImagePayload) (*models.WAMessage, error) {
-	url := fmt.Sprintf("%s/api/sendImage", s.baseURL)
+func (s *WahaService) SendVoice(ctx context.Context, chatId string, voice models.VoicePayload) (*models.WAMessage, error) {
+	payload := models.MessageVoiceRequest{
+		ChatID:  chatId,
+		Session: s.sessionName,
+		File:    voice.File,
+		Convert: voice.Convert,
+		ReplyTo: voice.ReplyTo,
+	}
+	return s.sendMessage(ctx, "sendVoice", payload)
+}
 
-	payload := models.MessageImageRequest{
+func (s *WahaService) SendFile(ctx context.Context, chatId string, file models.FilePayload) (*models.WAMessage, error) {
+	payload := models.MessageFileRequest{
 		ChatID:  chatId,
 		Session: s.sessionName,
-		Caption: image.Caption,
-		File:    image.File,
+		Caption: file.Caption,
+		File:    file.File,
+		ReplyTo: file.ReplyTo,
+	}
+	return s.sendMessage(ctx, "sendFile", payload)
+}
+
+func (s *WahaService) SendLocation(ctx context.Context, chatId string, location models.LocationPayload) (*models.WAMessage, error) {
+	payload := models.MessageLocationRequest{
+		ChatID:    chatId,
+		Session:   s.sessionName,
+		Latitude:  location.Latitude,
+		Longitude: location.Longitude,
+		Title:     location.Title,
+		ReplyTo:   location.ReplyTo,
+	}
+	return s.sendMessage(ctx, "sendLocation", payload)
+}
+
+func (s *WahaService) SendContactVcard(ctx context.Context, chatId string, contacts []models.ContactVcard) (*models.WAMessage, error) {
+	payload := models.MessageContactVcardRequest{
+		ChatID:   chatId,
+		Session:  s.sessionName,
+		Contacts: contacts,
+	}
+	return s.sendMessage(ctx, "sendContactVcard", payload)
+}
+
+func (s *WahaService) SendPoll(ctx context.Context, chatId string, poll models.PollPayload) (*models.WAMessage, error) {
+	payload := models.MessagePollRequest{
+		ChatID:  chatId,
+		Session: s.sessionName,
+		Poll:    poll.Poll,
+		ReplyTo: poll.ReplyTo,
+	}
+	return s.sendMessage(ctx, "sendPoll", payload)
+}
+
+// SendReaction reacts to a message with an emoji; an empty reaction removes
+// a previous one.
+func (s *WahaService) SendReaction(ctx context.Context, messageId, reaction string) error {
+	url := fmt.Sprintf("%s/api/reaction", s.baseURL)
+
+	payload := models.MessageReactionRequest{
+		MessageID: messageId,
+		Reaction:  reaction,
+		Session:   s.sessionName,
 	}
 
 	jsonPayload, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(jsonPayload))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return s.doRequest(req, nil)
+}
 
-	var response models.WAMessage
-	if err := s.doRequest(req, &response); err != nil {
-		return nil, err
+// --- Presence Methods ---
+
+func (s *WahaService) StartTyping(ctx context.Context, chatId string) error {
+	return s.postChatAction(ctx, "startTyping", models.ChatActionRequest{ChatID: chatId, Session: s.sessionName})
+}
+
+func (s *WahaService) StopTyping(ctx context.Context, chatId string) error {
+	return s.postChatAction(ctx, "stopTyping", models.ChatActionRequest{ChatID: chatId, Session: s.sessionName})
+}
+
+// SendSeen marks messages in chatId as read. Without ids WAHA marks the
+// whole chat as read.
+func (s *WahaService) SendSeen(ctx context.Context, chatId string, messageIds ...string) error {
+	payload := models.SendSeenRequest{
+		ChatID:     chatId,
+		Session:    s.sessionName,
+		MessageIDs: messageIds,
 	}
-	return &response, nil
+	return s.postChatAction(ctx, "sendSeen", payload)
+}
+
+// EditMessage replaces the text of a message sent by this session.
+func (s *WahaService) EditMessage(ctx context.Context, chatId, messageId, text string) error {
+	url := s.chatMessageURL(chatId, messageId)
+
+	jsonPayload, _ := json.Marshal(models.MessageEditRequest{Text: text})
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return err
+	}
+	return s.doRequest(req, nil)
 }
 
-func (s *WahaService) CheckNumberExists(phone string) (*models.WANumberExistResult, error) {
+// DeleteMessage deletes a message sent by this session for everyone.
+func (s *WahaService) DeleteMessage(ctx context.Context, chatId, messageId string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", s.chatMessageURL(chatId, messageId), nil)
+	if err != nil {
+		return err
+	}
+	return s.doRequest(req, nil)
+}
+
+func (s *WahaService) CheckNumberExists(ctx context.Context, phone string) (*models.WANumberExistResult, error) {
 	url := fmt.Sprintf("%s/api/contacts/check-exists?phone=%s&session=%s", s.baseURL, phone, s.sessionName)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -273,9 +494,82 @@ func (s *WahaService) CheckNumberExists(phone string) (*models.WANumberExistResu
 	return &result, nil
 }
 
-func (s *WahaService) GetChats() ([]models.ChatSummary, error) {
+func (s *WahaService) GetContacts(ctx context.Context) ([]models.WAContact, error) {
+	url := fmt.Sprintf("%s/api/contacts/all?session=%s", s.baseURL, s.sessionName)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var contacts []models.WAContact
+	if err := s.doRequest(req, &contacts); err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+func (s *WahaService) GetContact(ctx context.Context, contactId string) (*models.WAContact, error) {
+	url := fmt.Sprintf("%s/api/contacts?contactId=%s&session=%s", s.baseURL, neturl.QueryEscape(contactId), s.sessionName)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var contact models.WAContact
+	if err := s.doRequest(req, &contact); err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// GetContactAbout returns the contact's about text, or "" when it is hidden.
+func (s *WahaService) GetContactAbout(ctx context.Context, contactId string) (string, error) {
+	url := fmt.Sprintf("%s/api/contacts/about?contactId=%s&session=%s", s.baseURL, neturl.QueryEscape(contactId), s.sessionName)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var about models.ContactAbout
+	if err := s.doRequest(req, &about); err != nil {
+		return "", err
+	}
+	if about.About == nil {
+		return "", nil
+	}
+	return *about.About, nil
+}
+
+// GetProfilePicture returns the contact's picture URL, or "" when there is
+// none. WAHA caches pictures itself; refresh bypasses that cache.
+func (s *WahaService) GetProfilePicture(ctx context.Context, contactId string, refresh bool) (string, error) {
+	url := fmt.Sprintf("%s/api/contacts/profile-picture?contactId=%s&refresh=%t&session=%s", s.baseURL, neturl.QueryEscape(contactId), refresh, s.sessionName)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var picture models.ContactProfilePicture
+	if err := s.doRequest(req, &picture); err != nil {
+		return "", err
+	}
+	if picture.ProfilePictureURL == nil {
+		return "", nil
+	}
+	return *picture.ProfilePictureURL, nil
+}
+
+func (s *WahaService) BlockContact(ctx context.Context, contactId string) error {
+	return s.postChatAction(ctx, "contacts/block", models.ContactActionRequest{ContactID: contactId, Session: s.sessionName})
+}
+
+func (s *WahaService) UnblockContact(ctx context.Context, contactId string) error {
+	return s.postChatAction(ctx, "contacts/unblock", models.ContactActionRequest{ContactID: contactId, Session: s.sessionName})
+}
+
+func (s *WahaService) GetChats(ctx context.Context) ([]models.ChatSummary, error) {
 	url := fmt.Sprintf("%s/api/%s/chats/overview", s.baseURL, s.sessionName)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -287,9 +581,33 @@ func (s *WahaService) GetChats() ([]models.ChatSummary, error) {
 	return chats, nil
 }
 
-func (s *WahaService) GetGroups() ([]models.GroupInfo, error) {
+// GetChatMessages returns one page of a chat's messages, newest first. Page
+// through older messages by increasing query.Offset.
+func (s *WahaService) GetChatMessages(ctx context.Context, chatId string, query models.ChatMessagesQuery) ([]models.WAMessage, error) {
+	params := neturl.Values{}
+	params.Set("limit", fmt.Sprint(query.Limit))
+	params.Set("offset", fmt.Sprint(query.Offset))
+	params.Set("downloadMedia", fmt.Sprint(query.DownloadMedia))
+	if query.Since > 0 {
+		params.Set("filter.timestamp.gte", fmt.Sprint(query.Since))
+	}
+
+	url := fmt.Sprintf("%s/api/%s/chats/%s/messages?%s", s.baseURL, s.sessionName, neturl.PathEscape(chatId), params.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []models.WAMessage
+	if err := s.doRequest(req, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *WahaService) GetGroups(ctx context.Context) ([]models.GroupInfo, error) {
 	url := fmt.Sprintf("%s/api/%s/groups", s.baseURL, s.sessionName)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -301,8 +619,289 @@ func (s *WahaService) GetGroups() ([]models.GroupInfo, error) {
 	return groups, nil
 }
 
+// --- Group Methods ---
+
+func (s *WahaService) CreateGroup(ctx context.Context, name string, participants []string) (*models.GroupInfo, error) {
+	payload := models.GroupCreateRequest{
+		Name:         name,
+		Participants: groupParticipantIDs(participants),
+	}
+
+	var group models.GroupInfo
+	if err := s.groupRequest(ctx, "POST", "", payload, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (s *WahaService) GetGroup(ctx context.Context, groupId string) (*models.GroupInfo, error) {
+	var group models.GroupInfo
+	if err := s.groupRequest(ctx, "GET", groupId, nil, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (s *WahaService) AddGroupParticipants(ctx context.Context, groupId string, participants []string) error {
+	payload := models.GroupParticipantsRequest{Participants: groupParticipantIDs(participants)}
+	return s.groupRequest(ctx, "POST", groupId+"/participants/add", payload, nil)
+}
+
+func (s *WahaService) RemoveGroupParticipants(ctx context.Context, groupId string, participants []string) error {
+	payload := models.GroupParticipantsRequest{Participants: groupParticipantIDs(participants)}
+	return s.groupRequest(ctx, "POST", groupId+"/participants/remove", payload, nil)
+}
+
+func (s *WahaService) PromoteGroupAdmins(ctx context.Context, groupId string, participants []string) error {
+	payload := models.GroupParticipantsRequest{Participants: groupParticipantIDs(participants)}
+	return s.groupRequest(ctx, "POST", groupId+"/admin/promote", payload, nil)
+}
+
+func (s *WahaService) DemoteGroupAdmins(ctx context.Context, groupId string, participants []string) error {
+	payload := models.GroupParticipantsRequest{Participants: groupParticipantIDs(participants)}
+	return s.groupRequest(ctx, "POST", groupId+"/admin/demote", payload, nil)
+}
+
+func (s *WahaService) SetGroupSubject(ctx context.Context, groupId, subject string) error {
+	return s.groupRequest(ctx, "PUT", groupId+"/subject", models.GroupSubjectRequest{Subject: subject}, nil)
+}
+
+func (s *WahaService) SetGroupDescription(ctx context.Context, groupId, description string) error {
+	return s.groupRequest(ctx, "PUT", groupId+"/description", models.GroupDescriptionRequest{Description: description}, nil)
+}
+
+func (s *WahaService) SetGroupPicture(ctx context.Context, groupId string, file models.FileWrapper) error {
+	return s.groupRequest(ctx, "PUT", groupId+"/picture", models.GroupPictureRequest{File: file}, nil)
+}
+
+// GetGroupInviteCode returns the code part of the group's
+// https://chat.whatsapp.com/<code> invite link.
+func (s *WahaService) GetGroupInviteCode(ctx context.Context, groupId string) (string, error) {
+	var code string
+	if err := s.groupRequest(ctx, "GET", groupId+"/invite-code", nil, &code); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RevokeGroupInviteCode invalidates the current invite link and returns the
+// new code.
+func (s *WahaService) RevokeGroupInviteCode(ctx context.Context, groupId string) (string, error) {
+	var code string
+	if err := s.groupRequest(ctx, "POST", groupId+"/invite-code/revoke", nil, &code); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// --- Channel Methods ---
+
+// GetChannels lists the channels the session owns or follows, only those
+// where it has role if that is not empty.
+func (s *WahaService) GetChannels(ctx context.Context, role string) ([]models.Channel, error) {
+	url := fmt.Sprintf("%s/api/%s/channels", s.baseURL, s.sessionName)
+	if role != "" {
+		url += "?role=" + neturl.QueryEscape(role)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var channels []models.Channel
+	if err := s.doRequest(req, &channels); err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+func (s *WahaService) GetChannel(ctx context.Context, channelId string) (*models.Channel, error) {
+	url := fmt.Sprintf("%s/api/%s/channels/%s", s.baseURL, s.sessionName, neturl.PathEscape(channelId))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var channel models.Channel
+	if err := s.doRequest(req, &channel); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (s *WahaService) CreateChannel(ctx context.Context, channel models.ChannelCreateRequest) (*models.Channel, error) {
+	url := fmt.Sprintf("%s/api/%s/channels", s.baseURL, s.sessionName)
+
+	jsonPayload, _ := json.Marshal(channel)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, err
+	}
+
+	var created models.Channel
+	if err := s.doRequest(req, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// --- Status Methods ---
+
+// NewStatusMessageID reserves an id to post a status under, so it can be
+// deleted afterwards.
+func (s *WahaService) NewStatusMessageID(ctx context.Context) (string, error) {
+	var id models.StatusMessageID
+	if err := s.statusRequest(ctx, "GET", "new-message-id", nil, &id); err != nil {
+		return "", err
+	}
+	return id.ID, nil
+}
+
+func (s *WahaService) PostTextStatus(ctx context.Context, status models.StatusTextRequest) error {
+	return s.statusRequest(ctx, "POST", "text", status, nil)
+}
+
+func (s *WahaService) PostImageStatus(ctx context.Context, status models.StatusMediaRequest) error {
+	return s.statusRequest(ctx, "POST", "image", status, nil)
+}
+
+func (s *WahaService) PostVideoStatus(ctx context.Context, status models.StatusMediaRequest) error {
+	return s.statusRequest(ctx, "POST", "video", status, nil)
+}
+
+func (s *WahaService) DeleteStatus(ctx context.Context, statusId string) error {
+	return s.statusRequest(ctx, "POST", "delete", models.StatusDeleteRequest{ID: statusId}, nil)
+}
+
+// --- Media Methods ---
+
+func (s *WahaService) DownloadMedia(ctx context.Context, media models.WAMedia) ([]byte, error) {
+	if media.URL == "" {
+		return nil, fmt.Errorf("media has no url")
+	}
+
+	// WAHA builds file URLs from its own public base URL, which Lumi may not
+	// be able to reach; fetch the same path from the configured service URL.
+	// Only WAHA's file paths are fetched, so the API key never goes to a
+	// host the webhook payload names.
+	parsed, err := neturl.Parse(media.URL)
+	if err != nil || !strings.HasPrefix(parsed.Path, "/api/files/") || path.Clean(parsed.Path) != parsed.Path {
+		return nil, ErrInvalidMediaURL
+	}
+	url := s.baseURL + parsed.EscapedPath()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Api-Key", s.apiKey)
+
+	ctx, cancel := withDefaultTimeout(ctx, defaultRequestTimeout)
+	defer cancel()
+
+	resp, err := s.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, newTransportError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newWahaError(resp.StatusCode, body)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxMediaSize {
+		return nil, ErrMediaTooLarge
+	}
+	return data, nil
+}
+
 // --- Helpers ---
 
+func (s *WahaService) chatMessageURL(chatId, messageId string) string {
+	return fmt.Sprintf("%s/api/%s/chats/%s/messages/%s", s.baseURL, s.sessionName, neturl.PathEscape(chatId), neturl.PathEscape(messageId))
+}
+
+// groupRequest calls /api/{session}/groups/{path}, where path starts with the
+// group id, e.g. "123-456@g.us/subject".
+func (s *WahaService) groupRequest(ctx context.Context, method, path string, payload, v interface{}) error {
+	url := fmt.Sprintf("%s/api/%s/groups", s.baseURL, s.sessionName)
+	if path != "" {
+		url += "/" + path
+	}
+
+	var body io.Reader
+	if payload != nil {
+		jsonPayload, _ := json.Marshal(payload)
+		body = bytes.NewBuffer(jsonPayload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	return s.doRequest(req, v)
+}
+
+// statusRequest calls /api/{session}/status/{action}.
+func (s *WahaService) statusRequest(ctx context.Context, method, action string, payload, v interface{}) error {
+	url := fmt.Sprintf("%s/api/%s/status/%s", s.baseURL, s.sessionName, action)
+
+	var body io.Reader
+	if payload != nil {
+		jsonPayload, _ := json.Marshal(payload)
+		body = bytes.NewBuffer(jsonPayload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	return s.doRequest(req, v)
+}
+
+func groupParticipantIDs(participants []string) []models.GroupParticipantID {
+	ids := make([]models.GroupParticipantID, 0, len(participants))
+	for _, p := range participants {
+		ids = append(ids, models.GroupParticipantID{ID: p})
+	}
+	return ids
+}
+
+// postChatAction posts payload to a WAHA endpoint that returns no message.
+func (s *WahaService) postChatAction(ctx context.Context, endpoint string, payload interface{}) error {
+	url := fmt.Sprintf("%s/api/%s", s.baseURL, endpoint)
+
+	jsonPayload, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return err
+	}
+	return s.doRequest(req, nil)
+}
+
+// sendMessage posts payload to one of WAHA's /api/send* endpoints.
+func (s *WahaService) sendMessage(ctx context.Context, endpoint string, payload interface{}) (*models.WAMessage, error) {
+	url := fmt.Sprintf("%s/api/%s", s.baseURL, endpoint)
+
+	jsonPayload, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return nil, err
+	}
+
+	var response models.WAMessage
+	if err := s.doRequest(req, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
 func (s *WahaService) addHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Api-Key", s.apiKey)
@@ -311,15 +910,29 @@ func (s *WahaService) addHeaders(req *http.Request) {
 func (s *WahaService) doRequest(req *http.Request, v interface{}) error {
 	s.addHeaders(req)
 
-	resp, err := s.httpClient.Do(req)
+	ctx, cancel := withDefaultTimeout(req.Context(), defaultRequestTimeout)
+	defer cancel()
+
+	resp, err := s.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
-		return err
+		return newTransportError(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return newWahaError(resp.StatusCode, body)
+	}
+
+	// WAHA answers some endpoints (e.g. invite-code) with a bare string
+	// rather than JSON.
+	if text, ok := v.(*string); ok {
+		raw, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		*text = strings.Trim(strings.TrimSpace(string(raw)), `"`)
+		return nil
 	}
 
 	if v != nil {
@@ -331,17 +944,27 @@ func (s *WahaService) doRequest(req *http.Request, v interface{}) error {
 	return nil
 }
 
-func (s *WahaService) waitForSessionReady() error {
-	timeout := time.After(20 * time.Second)
+func (s *WahaService) waitForSessionReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, sessionReadyTimeout)
+	defer cancel()
+
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-timeout:
-			return fmt.Errorf("timeout waiting for session %s to be ready", s.sessionName)
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return &WahaError{
+					Code:     WahaErrSessionState,
+					Message:  "timeout waiting for session to be ready",
+					Session:  s.sessionName,
+					Expected: []string{"SCAN_QR_CODE", "WORKING"},
+				}
+			}
+			return ctx.Err()
 		case <-ticker.C:
-			status, err := s.GetSessionStatus()
+			status, err := s.GetSessionStatus(ctx)
 			if err != nil {
 				continue
 			}
@@ -350,7 +973,13 @@ func (s *WahaService) waitForSessionReady() error {
 				return nil
 			}
 			if status.Status == "FAILED" {
-				return fmt.Errorf("session failed to start")
+				return &WahaError{
+					Code:     WahaErrSessionState,
+					Message:  "session failed to start",
+					Session:  s.sessionName,
+					Status:   status.Status,
+					Expected: []string{"SCAN_QR_CODE", "WORKING"},
+				}
 			}
 			if status.Status == "STOPPED" {
 				continue
@@ -358,3 +987,11 @@ func (s *WahaService) waitForSessionReady() error {
 		}
 	}
 }
+
+// withDefaultTimeout bounds ctx by d unless the caller already set a deadline.
+func withDefaultTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
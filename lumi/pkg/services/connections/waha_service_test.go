@@ -0,0 +1,143 @@
+package connections
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// hangingServer accepts requests and doesn't answer them before the test
+// ends.
+func hangingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	return srv
+}
+
+func TestCallsHonourTheContext(t *testing.T) {
+	srv := hangingServer(t)
+	client := NewWahaClient(srv.URL, "key", "default")
+
+	calls := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"Ping", client.Ping},
+		{"GetQRCode", func(ctx context.Context) error { _, err := client.GetQRCode(ctx); return err }},
+		{"SendText", func(ctx context.Context) error { _, err := client.SendText(ctx, "15552223333@c.us", "hi"); return err }},
+		{"StartSession", client.StartSession},
+	}
+
+	for _, tt := range calls {
+		t.Run(tt.name, func(t *testing.T) {
+			tests := []struct {
+				name    string
+				ctx     func() (context.Context, context.CancelFunc)
+				wantErr error
+			}{
+				{"cancelled", func() (context.Context, context.CancelFunc) {
+					ctx, cancel := context.WithCancel(context.Background())
+					time.AfterFunc(50*time.Millisecond, cancel)
+					return ctx, cancel
+				}, context.Canceled},
+				{"deadline", func() (context.Context, context.CancelFunc) {
+					return context.WithTimeout(context.Background(), 50*time.Millisecond)
+				}, context.DeadlineExceeded},
+			}
+
+			for _, c := range tests {
+				ctx, cancel := c.ctx()
+				start := time.Now()
+				err := tt.call(ctx)
+				cancel()
+
+				if err == nil {
+					t.Fatalf("%s: succeeded against a server that never answers", c.name)
+				}
+				if time.Since(start) > 5*time.Second {
+					t.Errorf("%s: returned after %s", c.name, time.Since(start))
+				}
+				// StartSession wraps the creation error and reports its own
+				// timeout, so only the plain calls are checked for the cause.
+				if tt.name != "StartSession" && !errors.Is(err, c.wantErr) {
+					t.Errorf("%s: err = %v, want %v", c.name, err, c.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestWithDefaultTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline time.Duration // 0 for a caller without a deadline
+		want     time.Duration
+	}{
+		{"no deadline", 0, time.Minute},
+		{"caller deadline kept", time.Hour, time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := context.Background()
+			if tt.deadline != 0 {
+				var cancel context.CancelFunc
+				parent, cancel = context.WithTimeout(parent, tt.deadline)
+				defer cancel()
+			}
+
+			ctx, cancel := withDefaultTimeout(parent, time.Minute)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				t.Fatalf("no deadline set")
+			}
+			if got := time.Until(deadline); got > tt.want || got < tt.want-time.Second {
+				t.Errorf("deadline in %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetQRCodeSizeLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		err  error
+	}{
+		{"small", 4 << 10, nil},
+		{"at the limit", maxQRSize, nil},
+		{"too large", maxQRSize + 1, ErrQRTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				w.Write(make([]byte, tt.size))
+			}))
+			t.Cleanup(srv.Close)
+
+			qr, err := NewWahaClient(srv.URL, "key", "default").GetQRCode(context.Background())
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if err == nil && len(qr) != tt.size {
+				t.Errorf("read %d bytes, want %d", len(qr), tt.size)
+			}
+		})
+	}
+}
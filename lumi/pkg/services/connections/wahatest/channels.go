@@ -0,0 +1,75 @@
+package wahatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+// SetChannels replaces the channels the fake's sessions own or follow.
+func (s *Server) SetChannels(channels []models.Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels = channels
+}
+
+// handleChannels serves /api/{session}/channels[/{id}].
+func (s *Server) handleChannels(w http.ResponseWriter, r *http.Request, name, channelID string) {
+	if _, ok := s.workingSession(w, name); !ok {
+		return
+	}
+
+	switch {
+	case channelID == "" && r.Method == http.MethodGet:
+		role := r.URL.Query().Get("role")
+
+		s.mu.Lock()
+		channels := []models.Channel{}
+		for _, channel := range s.channels {
+			if role == "" || channel.Role == role {
+				channels = append(channels, channel)
+			}
+		}
+		s.mu.Unlock()
+
+		writeJSON(w, http.StatusOK, channels)
+
+	case channelID == "" && r.Method == http.MethodPost:
+		var req models.ChannelCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+			writeError(w, http.StatusBadRequest, "invalid channel payload", name, "")
+			return
+		}
+
+		s.mu.Lock()
+		s.channelSeq++
+		channel := models.Channel{
+			ID:          fmt.Sprintf("1203633%08d@newsletter", s.channelSeq),
+			Name:        req.Name,
+			Description: req.Description,
+			Invite:      fmt.Sprintf("https://whatsapp.com/channel/wahatest%08d", s.channelSeq),
+			Role:        models.ChannelRoleOwner,
+		}
+		s.channels = append(s.channels, channel)
+		s.mu.Unlock()
+
+		writeJSON(w, http.StatusCreated, channel)
+
+	case channelID != "" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		for _, channel := range s.channels {
+			if channel.ID == channelID {
+				writeJSON(w, http.StatusOK, channel)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "channel not found", name, "")
+
+	default:
+		writeError(w, http.StatusNotFound, "not found: "+r.Method+" "+r.URL.Path, "", "")
+	}
+}
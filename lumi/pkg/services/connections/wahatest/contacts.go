@@ -0,0 +1,89 @@
+package wahatest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+// SetContacts replaces the contact list served by /api/contacts/all.
+func (s *Server) SetContacts(contacts []models.WAContact) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contacts = contacts
+}
+
+// SetContactDetails sets the about text and profile picture URL of a
+// contact; empty values are served as hidden.
+func (s *Server) SetContactDetails(contactID, about, pictureURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.abouts[contactID] = about
+	s.pictures[contactID] = pictureURL
+}
+
+// handleContacts serves /api/contacts/{action}, except check-exists.
+func (s *Server) handleContacts(w http.ResponseWriter, r *http.Request, action string) {
+	var req models.ContactActionRequest
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ContactID == "" {
+			writeError(w, http.StatusBadRequest, "invalid payload", "", "")
+			return
+		}
+	} else {
+		req.Session = r.URL.Query().Get("session")
+		req.ContactID = r.URL.Query().Get("contactId")
+	}
+
+	if _, ok := s.workingSession(w, req.Session); !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case action == "all" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, append([]models.WAContact{}, s.contacts...))
+
+	case action == "" && r.Method == http.MethodGet:
+		if i := s.contactIndex(req.ContactID); i >= 0 {
+			writeJSON(w, http.StatusOK, s.contacts[i])
+			return
+		}
+		writeError(w, http.StatusNotFound, "contact not found", req.Session, "")
+
+	case action == "about" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, models.ContactAbout{About: optional(s.abouts[req.ContactID])})
+
+	case action == "profile-picture" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, models.ContactProfilePicture{ProfilePictureURL: optional(s.pictures[req.ContactID])})
+
+	case (action == "block" || action == "unblock") && r.Method == http.MethodPost:
+		if i := s.contactIndex(req.ContactID); i >= 0 {
+			s.contacts[i].IsBlocked = action == "block"
+		}
+		writeJSON(w, http.StatusOK, map[string]any{})
+
+	default:
+		writeError(w, http.StatusNotFound, "not found: "+r.Method+" "+r.URL.Path, req.Session, "")
+	}
+}
+
+// contactIndex finds a contact by id. Callers hold s.mu.
+func (s *Server) contactIndex(contactID string) int {
+	for i, c := range s.contacts {
+		if c.ID == contactID {
+			return i
+		}
+	}
+	return -1
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,172 @@
+package wahatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+// Group returns the fake's current state of a group.
+func (s *Server) Group(groupID string) (models.GroupInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.groupIndex(groupID); i >= 0 {
+		return s.groups[i], true
+	}
+	return models.GroupInfo{}, false
+}
+
+func (s *Server) handleCreateGroup(w http.ResponseWriter, r *http.Request, name string) {
+	sess, ok := s.workingSession(w, name)
+	if !ok {
+		return
+	}
+
+	var req models.GroupCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid group payload", name, "")
+		return
+	}
+
+	s.mu.Lock()
+	s.groupSeq++
+	group := models.GroupInfo{
+		ID:           fmt.Sprintf("1203630%08d@g.us", s.groupSeq),
+		Subject:      req.Name,
+		Owner:        sess.me.ID,
+		Creation:     time.Now().Unix(),
+		Participants: []models.GroupParticipant{{ID: sess.me.ID, Admin: "superadmin"}},
+	}
+	for _, p := range req.Participants {
+		group.Participants = append(group.Participants, models.GroupParticipant{ID: p.ID})
+	}
+	s.groups = append(s.groups, group)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, group)
+}
+
+// handleGroup serves /api/{session}/groups/{id}/{action}.
+func (s *Server) handleGroup(w http.ResponseWriter, r *http.Request, name, groupID, action string) {
+	if _, ok := s.workingSession(w, name); !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.groupIndex(groupID)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "group not found", name, "")
+		return
+	}
+	group := &s.groups[i]
+
+	var body struct {
+		Subject      string                      `json:"subject"`
+		Description  string                      `json:"description"`
+		File         models.FileWrapper          `json:"file"`
+		Participants []models.GroupParticipantID `json:"participants"`
+	}
+	if r.Method != http.MethodGet && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid payload", name, "")
+			return
+		}
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, group)
+
+	case action == "subject" && r.Method == http.MethodPut:
+		group.Subject = body.Subject
+		writeJSON(w, http.StatusOK, map[string]any{})
+
+	case action == "description" && r.Method == http.MethodPut:
+		group.Description = body.Description
+		writeJSON(w, http.StatusOK, map[string]any{})
+
+	case action == "picture" && r.Method == http.MethodPut:
+		writeJSON(w, http.StatusOK, map[string]any{"success": true})
+
+	case action == "participants/add":
+		for _, p := range body.Participants {
+			if participantIndex(group, p.ID) < 0 {
+				group.Participants = append(group.Participants, models.GroupParticipant{ID: p.ID})
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{})
+
+	case action == "participants/remove":
+		for _, p := range body.Participants {
+			if j := participantIndex(group, p.ID); j >= 0 {
+				group.Participants = append(group.Participants[:j], group.Participants[j+1:]...)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{})
+
+	case action == "admin/promote", action == "admin/demote":
+		role := "admin"
+		if action == "admin/demote" {
+			role = ""
+		}
+		for _, p := range body.Participants {
+			if j := participantIndex(group, p.ID); j >= 0 && group.Participants[j].Admin != "superadmin" {
+				group.Participants[j].Admin = role
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{})
+
+	case action == "invite-code" && r.Method == http.MethodGet:
+		writeText(w, s.inviteCode(groupID, false))
+
+	case action == "invite-code/revoke":
+		writeText(w, s.inviteCode(groupID, true))
+
+	default:
+		writeError(w, http.StatusNotFound, "not found: "+r.Method+" "+r.URL.Path, name, "")
+	}
+}
+
+// inviteCode returns the group's invite code, minting a new one on first
+// use or when rotate is set. Callers hold s.mu.
+func (s *Server) inviteCode(groupID string, rotate bool) string {
+	code, ok := s.inviteCodes[groupID]
+	if !ok || rotate {
+		s.groupSeq++
+		code = fmt.Sprintf("WAHATESTINVITE%06d", s.groupSeq)
+		s.inviteCodes[groupID] = code
+	}
+	return code
+}
+
+// groupIndex finds a group by id. Callers hold s.mu.
+func (s *Server) groupIndex(groupID string) int {
+	for i, g := range s.groups {
+		if g.ID == groupID {
+			return i
+		}
+	}
+	return -1
+}
+
+func participantIndex(group *models.GroupInfo, id string) int {
+	for i, p := range group.Participants {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeText answers with a bare string, like WAHA's invite-code endpoints.
+func writeText(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(text))
+}
@@ -0,0 +1,127 @@
+package wahatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+// AddChatMessages appends msgs to the history served by the chat messages
+// endpoint. Emitted and sent messages are added automatically.
+func (s *Server) AddChatMessages(chatID string, msgs ...models.WAMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[chatID] = append(s.messages[chatID], msgs...)
+}
+
+// EmitPollVote pushes a poll.vote event for a poll sent by the session, as
+// if voter picked options. No options means the vote was withdrawn.
+func (s *Server) EmitPollVote(sessionName, pollMessageID, voter string, options ...string) error {
+	chatID := voter
+	if parts := strings.Split(pollMessageID, "_"); len(parts) >= 3 {
+		chatID = parts[1]
+	}
+
+	vote := models.PollVote{
+		ID:              models.WAMessageID(s.nextMessageID(false, chatID)),
+		SelectedOptions: append([]string{}, options...),
+		Timestamp:       time.Now().Unix(),
+		From:            chatID,
+		To:              chatID,
+	}
+	if strings.HasSuffix(chatID, "@g.us") {
+		vote.Participant = voter
+	}
+
+	return s.Emit(sessionName, "poll.vote", models.PollVotePayload{
+		Vote: vote,
+		Poll: models.PollMessageRef{ID: models.WAMessageID(pollMessageID), To: chatID, FromMe: true},
+	})
+}
+
+// handleChatMessages serves /api/{session}/chats/{chatId}/messages newest
+// first, honouring limit, offset and filter.timestamp.gte.
+func (s *Server) handleChatMessages(w http.ResponseWriter, r *http.Request, name, chatID string) {
+	if _, ok := s.workingSession(w, name); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	since, _ := strconv.ParseInt(query.Get("filter.timestamp.gte"), 10, 64)
+	if limit <= 0 {
+		limit = 100
+	}
+
+	s.mu.Lock()
+	history := s.messages[chatID]
+	var matching []models.WAMessage
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Timestamp >= since {
+			matching = append(matching, history[i])
+		}
+	}
+	s.mu.Unlock()
+
+	page := []models.WAMessage{}
+	if offset < len(matching) {
+		page = matching[offset:min(offset+limit, len(matching))]
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleChatMessage edits (PUT) or deletes for everyone (DELETE) a message
+// the session sent. Deleted messages are dropped from the history.
+func (s *Server) handleChatMessage(w http.ResponseWriter, r *http.Request, name, chatID, messageID string) {
+	if _, ok := s.workingSession(w, name); !ok {
+		return
+	}
+
+	var req models.MessageEditRequest
+	if r.Method == http.MethodPut {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Text == "" {
+			writeError(w, http.StatusBadRequest, "invalid payload", name, "")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.messages[chatID]
+	i := 0
+	for i < len(history) && history[i].ID.String() != messageID {
+		i++
+	}
+	if i == len(history) {
+		writeError(w, http.StatusNotFound, "message not found", name, "")
+		return
+	}
+	if !history[i].FromMe {
+		writeError(w, http.StatusBadRequest, "only own messages can be changed", name, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		history[i].Body = req.Text
+	case http.MethodDelete:
+		s.messages[chatID] = append(history[:i], history[i+1:]...)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", name, "")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{})
+}
+
+func chatOf(msg models.WAMessage) string {
+	if msg.FromMe {
+		return msg.To
+	}
+	return msg.From
+}
@@ -0,0 +1,774 @@
+// Package wahatest provides an in-process fake of the WAHA HTTP API so that
+// code built on connections.WahaClient can be exercised without Docker.
+//
+// A typical setup points Lumi's config at the fake before building services:
+//
+//	srv := wahatest.NewServer()
+//	defer srv.Close()
+//	config.GConfig = &config.Config{WahaServiceURL: srv.URL, WahaAPIKey: srv.APIKey}
+package wahatest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/enums"
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+)
+
+const DefaultAPIKey = "wahatest-api-key"
+
+// SentMessage is a send* call recorded by the fake.
+type SentMessage struct {
+	ID       string
+	Endpoint string // e.g. "sendText", "sendImage"
+	Session  string
+	ChatID   string
+	Text     string
+	Payload  map[string]any
+}
+
+// ChatAction is a presence call (startTyping, stopTyping, sendSeen) recorded
+// by the fake.
+type ChatAction struct {
+	Endpoint   string
+	Session    string
+	ChatID     string
+	MessageIDs []string
+}
+
+type mediaFile struct {
+	mimetype string
+	data     []byte
+}
+
+type session struct {
+	status string
+	me     *models.MeInfo
+	qrSeq  int // bumped by RotateQR
+}
+
+type Server struct {
+	*httptest.Server
+
+	APIKey string
+
+	mu            sync.Mutex
+	sessions      map[string]*session
+	sent          []SentMessage
+	actions       []ChatAction
+	chats         []models.ChatSummary
+	groups        []models.GroupInfo
+	channels      []models.Channel
+	statuses      []PostedStatus
+	numbers       map[string]bool
+	failures      map[string]int
+	files         map[string]mediaFile
+	webhookURL    string
+	webhookSecret string
+	msgSeq        int
+	eventSeq      int
+	groupSeq      int
+	channelSeq    int
+	statusSeq     int
+	contacts      []models.WAContact
+	abouts        map[string]string
+	messages      map[string][]models.WAMessage // by chat id, oldest first
+	pictures      map[string]string
+	inviteCodes   map[string]string
+	sockets       map[*socket]struct{}
+}
+
+func NewServer() *Server {
+	s := &Server{
+		APIKey:      DefaultAPIKey,
+		sessions:    make(map[string]*session),
+		numbers:     make(map[string]bool),
+		failures:    make(map[string]int),
+		files:       make(map[string]mediaFile),
+		inviteCodes: make(map[string]string),
+		abouts:      make(map[string]string),
+		messages:    make(map[string][]models.WAMessage),
+		pictures:    make(map[string]string),
+		sockets:     make(map[*socket]struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.route))
+	return s
+}
+
+// Client returns a WahaClient bound to the given session on this server.
+func (s *Server) Client(sessionName string) connections.WahaClient {
+	return connections.NewWahaClient(s.URL, s.APIKey, sessionName)
+}
+
+// --- Test Controls ---
+
+// SetWebhook makes the fake deliver events to url, signing them with secret
+// when it is not empty.
+func (s *Server) SetWebhook(url, secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhookURL = url
+	s.webhookSecret = secret
+}
+
+func (s *Server) SetChats(chats []models.ChatSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats = chats
+}
+
+func (s *Server) SetGroups(groups []models.GroupInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups = groups
+}
+
+// SetNumberExists controls the answer of check-exists for a phone number.
+func (s *Server) SetNumberExists(phone string, exists bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.numbers[phone] = exists
+}
+
+// FailNext makes the next request to path (without query) answer status.
+func (s *Server) FailNext(path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = status
+}
+
+// AddMedia serves data from the fake's file endpoint and returns the media
+// descriptor to attach to an emitted message.
+func (s *Server) AddMedia(sessionName, filename, mimetype string, data []byte) models.WAMedia {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := fmt.Sprintf("/api/files/%s/%s", sessionName, filename)
+	s.files[path] = mediaFile{mimetype: mimetype, data: data}
+	return models.WAMedia{URL: s.URL + path, Mimetype: mimetype, Filename: filename}
+}
+
+// Status returns the current lifecycle state of a session, or "" if it
+// does not exist.
+func (s *Server) Status(sessionName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[sessionName]; ok {
+		return sess.status
+	}
+	return ""
+}
+
+// RotateQR replaces the session's pairing QR, as WAHA does every ~20s while
+// waiting for a scan.
+func (s *Server) RotateQR(sessionName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess, ok := s.sessions[sessionName]; ok {
+		sess.qrSeq++
+	}
+}
+
+// Pair simulates the phone scanning the QR code: the session moves to
+// WORKING and a session.status event is emitted.
+func (s *Server) Pair(sessionName string, me models.MeInfo) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionName]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("session %s does not exist", sessionName)
+	}
+	sess.status = enums.WAHA_SESSION_WORKING.String()
+	sess.me = &me
+	s.mu.Unlock()
+
+	return s.Emit(sessionName, "session.status", models.SessionStatusPayload{Status: enums.WAHA_SESSION_WORKING.String()})
+}
+
+// Sent returns a copy of every recorded send* call.
+func (s *Server) Sent() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SentMessage(nil), s.sent...)
+}
+
+// SentTo returns the recorded send* calls addressed to chatID.
+func (s *Server) SentTo(chatID string) []SentMessage {
+	var out []SentMessage
+	for _, m := range s.Sent() {
+		if m.ChatID == chatID {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Actions returns a copy of every recorded presence call.
+func (s *Server) Actions() []ChatAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ChatAction(nil), s.actions...)
+}
+
+// EmitMessage pushes a message.any event for msg into the configured webhook.
+func (s *Server) EmitMessage(sessionName string, msg models.WAMessage) error {
+	if msg.ID == "" {
+		msg.ID = models.WAMessageID(s.nextMessageID(msg.FromMe, msg.From))
+	}
+	if msg.Timestamp == 0 {
+		msg.Timestamp = time.Now().Unix()
+	}
+	s.AddChatMessages(chatOf(msg), msg)
+	return s.Emit(sessionName, "message.any", msg)
+}
+
+// Emit delivers an arbitrary event to connected websocket clients and the
+// configured webhook, the way WAHA would.
+func (s *Server) Emit(sessionName, event string, payload any) error {
+	s.mu.Lock()
+	url, secret := s.webhookURL, s.webhookSecret
+	s.eventSeq++
+	eventID := fmt.Sprintf("evt_%06d", s.eventSeq)
+	hasSockets := len(s.sockets) > 0
+	s.mu.Unlock()
+
+	if url == "" && !hasSockets {
+		return nil
+	}
+
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	body, err := json.Marshal(models.WAHAWebhook{
+		ID:        eventID,
+		Timestamp: now,
+		Event:     event,
+		Session:   sessionName,
+		Payload:   rawPayload,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.broadcast(sessionName, body)
+	if url == "" {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Request-Id", eventID)
+	req.Header.Set("X-Webhook-Timestamp", fmt.Sprint(now))
+
+	if secret != "" {
+		mac := hmac.New(sha512.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Webhook-Hmac", hex.EncodeToString(mac.Sum(nil)))
+		req.Header.Set("X-Webhook-Hmac-Algorithm", "sha512")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook %s rejected with status %d: %s", event, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// --- Routing ---
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	if status, ok := s.takeFailure(r.URL.Path); ok {
+		writeError(w, status, "injected failure", "", "")
+		return
+	}
+
+	// The socket takes its key as a query parameter, like WAHA's.
+	if r.URL.Path == "/ws" {
+		s.handleWS(w, r)
+		return
+	}
+
+	if s.APIKey != "" && r.URL.Path != "/ping" && r.Header.Get("X-Api-Key") != s.APIKey {
+		writeError(w, http.StatusUnauthorized, "invalid api key", "", "")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.URL.Path == "/ping":
+		writeJSON(w, http.StatusOK, map[string]string{"message": "pong"})
+
+	case len(parts) == 2 && parts[0] == "api" && parts[1] == "sessions" && r.Method == http.MethodPost:
+		s.handleCreateSession(w, r)
+
+	case len(parts) == 3 && parts[0] == "api" && parts[1] == "sessions" && r.Method == http.MethodGet:
+		s.handleGetSession(w, parts[2])
+
+	case len(parts) == 4 && parts[0] == "api" && parts[1] == "sessions" && r.Method == http.MethodPost:
+		s.handleSessionAction(w, parts[2], parts[3])
+
+	case len(parts) == 4 && parts[0] == "api" && parts[1] == "sessions" && parts[3] == "me":
+		s.handleGetMe(w, parts[2])
+
+	case len(parts) == 4 && parts[0] == "api" && parts[2] == "auth" && parts[3] == "qr":
+		s.handleQR(w, r, parts[1])
+
+	case len(parts) == 4 && parts[0] == "api" && parts[2] == "auth" && parts[3] == "request-code":
+		s.handleRequestCode(w, parts[1])
+
+	case len(parts) == 3 && parts[0] == "api" && parts[1] == "contacts" && parts[2] == "check-exists":
+		s.handleCheckExists(w, r)
+
+	case len(parts) >= 2 && parts[0] == "api" && parts[1] == "contacts":
+		s.handleContacts(w, r, strings.Join(parts[2:], "/"))
+
+	case len(parts) == 5 && parts[0] == "api" && parts[2] == "chats" && parts[4] == "messages" && r.Method == http.MethodGet:
+		s.handleChatMessages(w, r, parts[1], parts[3])
+
+	case len(parts) == 6 && parts[0] == "api" && parts[2] == "chats" && parts[4] == "messages":
+		s.handleChatMessage(w, r, parts[1], parts[3], parts[5])
+
+	case len(parts) == 4 && parts[0] == "api" && parts[2] == "chats" && parts[3] == "overview":
+		s.handleList(w, parts[1], func() any { return s.chats })
+
+	case len(parts) == 3 && parts[0] == "api" && parts[2] == "groups" && r.Method == http.MethodPost:
+		s.handleCreateGroup(w, r, parts[1])
+
+	case len(parts) == 3 && parts[0] == "api" && parts[2] == "groups":
+		s.handleList(w, parts[1], func() any { return s.groups })
+
+	case len(parts) >= 4 && parts[0] == "api" && parts[2] == "groups":
+		s.handleGroup(w, r, parts[1], parts[3], strings.Join(parts[4:], "/"))
+
+	case len(parts) == 3 && parts[0] == "api" && parts[2] == "channels":
+		s.handleChannels(w, r, parts[1], "")
+
+	case len(parts) == 4 && parts[0] == "api" && parts[2] == "channels":
+		s.handleChannels(w, r, parts[1], parts[3])
+
+	case len(parts) == 4 && parts[0] == "api" && parts[2] == "status":
+		s.handleStatus(w, r, parts[1], parts[3])
+
+	case len(parts) >= 3 && parts[0] == "api" && parts[1] == "files":
+		s.handleFile(w, r.URL.Path)
+
+	case len(parts) == 2 && parts[0] == "api" && (parts[1] == "startTyping" || parts[1] == "stopTyping" || parts[1] == "sendSeen") && r.Method == http.MethodPost:
+		s.handleChatAction(w, r, parts[1])
+
+	case len(parts) == 2 && parts[0] == "api" && strings.HasPrefix(parts[1], "send") && r.Method == http.MethodPost:
+		s.handleSend(w, r, parts[1])
+
+	case len(parts) == 2 && parts[0] == "api" && parts[1] == "reaction" && r.Method == http.MethodPut:
+		s.handleReaction(w, r)
+
+	default:
+		writeError(w, http.StatusNotFound, "not found: "+r.Method+" "+r.URL.Path, "", "")
+	}
+}
+
+// --- Session Lifecycle ---
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req models.SessionCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid session payload", "", "")
+		return
+	}
+
+	s.mu.Lock()
+	if _, exists := s.sessions[req.Name]; exists {
+		s.mu.Unlock()
+		writeError(w, http.StatusUnprocessableEntity, "session already exists", req.Name, "")
+		return
+	}
+	sess := &session{status: enums.WAHA_SESSION_STOPPED.String()}
+	s.sessions[req.Name] = sess
+	if req.Start {
+		sess.status = enums.WAHA_SESSION_STARTING.String()
+	}
+	info := sessionInfo(req.Name, sess)
+	s.mu.Unlock()
+
+	if req.Start {
+		s.Emit(req.Name, "session.status", models.SessionStatusPayload{Status: info.Status})
+	}
+	writeJSON(w, http.StatusCreated, info)
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, name string) {
+	s.mu.Lock()
+	sess, ok := s.sessions[name]
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "session not found", name, "")
+		return
+	}
+
+	// The engine boots between polls: STARTING settles into SCAN_QR_CODE.
+	booted := false
+	if sess.status == enums.WAHA_SESSION_STARTING.String() {
+		sess.status = enums.WAHA_SESSION_SCAN_QR_CODE.String()
+		booted = true
+	}
+	info := sessionInfo(name, sess)
+	s.mu.Unlock()
+
+	if booted {
+		s.Emit(name, "session.status", models.SessionStatusPayload{Status: info.Status})
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleSessionAction(w http.ResponseWriter, name, action string) {
+	s.mu.Lock()
+	sess, ok := s.sessions[name]
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "session not found", name, "")
+		return
+	}
+
+	switch action {
+	case "start", "restart":
+		sess.status = enums.WAHA_SESSION_STARTING.String()
+		if sess.me != nil {
+			// Already authenticated devices come straight back up.
+			sess.status = enums.WAHA_SESSION_WORKING.String()
+		}
+	case "stop":
+		sess.status = enums.WAHA_SESSION_STOPPED.String()
+	case "logout":
+		sess.status = enums.WAHA_SESSION_STOPPED.String()
+		sess.me = nil
+	default:
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "unknown action "+action, name, "")
+		return
+	}
+	info := sessionInfo(name, sess)
+	s.mu.Unlock()
+
+	s.Emit(name, "session.status", models.SessionStatusPayload{Status: info.Status})
+	writeJSON(w, http.StatusCreated, info)
+}
+
+func (s *Server) handleGetMe(w http.ResponseWriter, name string) {
+	sess, ok := s.workingSession(w, name)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, sess.me)
+}
+
+func (s *Server) handleQR(w http.ResponseWriter, r *http.Request, name string) {
+	s.mu.Lock()
+	sess, ok := s.sessions[name]
+	if !ok || sess.status != enums.WAHA_SESSION_SCAN_QR_CODE.String() {
+		status := ""
+		if ok {
+			status = sess.status
+		}
+		s.mu.Unlock()
+		writeError(w, http.StatusUnprocessableEntity, "Session status is not as expected.", name, status, enums.WAHA_SESSION_SCAN_QR_CODE.String())
+		return
+	}
+	if sess.qrSeq == 0 {
+		sess.qrSeq = 1
+	}
+	seq := sess.qrSeq
+	s.mu.Unlock()
+
+	if r.URL.Query().Get("format") == "raw" {
+		writeJSON(w, http.StatusOK, models.QRCode{Value: qrValue(name, seq)})
+		return
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, qrImage(seq))
+	if r.Header.Get("Accept") == "application/json" {
+		writeJSON(w, http.StatusOK, models.QRCode{Mimetype: "image/png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) handleRequestCode(w http.ResponseWriter, name string) {
+	s.mu.Lock()
+	sess, ok := s.sessions[name]
+	if !ok || sess.status != enums.WAHA_SESSION_SCAN_QR_CODE.String() {
+		status := ""
+		if ok {
+			status = sess.status
+		}
+		s.mu.Unlock()
+		writeError(w, http.StatusUnprocessableEntity, "Session status is not as expected.", name, status, enums.WAHA_SESSION_SCAN_QR_CODE.String())
+		return
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, models.RequestCodeResponse{Code: "ABCD-1234"})
+}
+
+// --- Chatting ---
+
+func (s *Server) handleCheckExists(w http.ResponseWriter, r *http.Request) {
+	phone := r.URL.Query().Get("phone")
+	if _, ok := s.workingSession(w, r.URL.Query().Get("session")); !ok {
+		return
+	}
+
+	s.mu.Lock()
+	exists, known := s.numbers[phone]
+	s.mu.Unlock()
+
+	result := models.WANumberExistResult{NumberExists: !known || exists}
+	if result.NumberExists {
+		result.ChatID = phone + "@c.us"
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleList(w http.ResponseWriter, name string, list func() any) {
+	if _, ok := s.workingSession(w, name); !ok {
+		return
+	}
+
+	s.mu.Lock()
+	data := list()
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request, endpoint string) {
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload", "", "")
+		return
+	}
+
+	sessionName, _ := payload["session"].(string)
+	sess, ok := s.workingSession(w, sessionName)
+	if !ok {
+		return
+	}
+
+	chatID, _ := payload["chatId"].(string)
+	text, _ := payload["text"].(string)
+	if caption, ok := payload["caption"].(string); ok && text == "" {
+		text = caption
+	}
+	if poll, ok := payload["poll"].(map[string]any); ok && text == "" {
+		text, _ = poll["name"].(string)
+	}
+
+	id := s.nextMessageID(true, chatID)
+
+	s.mu.Lock()
+	s.sent = append(s.sent, SentMessage{
+		ID:       id,
+		Endpoint: endpoint,
+		Session:  sessionName,
+		ChatID:   chatID,
+		Text:     text,
+		Payload:  payload,
+	})
+	s.mu.Unlock()
+
+	msg := models.WAMessage{
+		ID:        models.WAMessageID(id),
+		Timestamp: time.Now().Unix(),
+		From:      sess.me.ID,
+		To:        chatID,
+		Body:      text,
+		FromMe:    true,
+		Source:    "api",
+	}
+	s.AddChatMessages(chatID, msg)
+
+	writeJSON(w, http.StatusCreated, msg)
+}
+
+// handleReaction records a reaction as a SentMessage whose Text is the emoji
+// and whose ChatID is taken from the reacted message id.
+func (s *Server) handleReaction(w http.ResponseWriter, r *http.Request) {
+	var req models.MessageReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid payload", "", "")
+		return
+	}
+
+	if _, ok := s.workingSession(w, req.Session); !ok {
+		return
+	}
+
+	// Message ids look like "<fromMe>_<chatId>_<id>[_<participant>]".
+	chatID := ""
+	if parts := strings.Split(req.MessageID, "_"); len(parts) >= 3 {
+		chatID = parts[1]
+	}
+
+	s.mu.Lock()
+	s.sent = append(s.sent, SentMessage{
+		Endpoint: "reaction",
+		Session:  req.Session,
+		ChatID:   chatID,
+		Text:     req.Reaction,
+		Payload:  map[string]any{"messageId": req.MessageID, "reaction": req.Reaction},
+	})
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{})
+}
+
+func (s *Server) handleChatAction(w http.ResponseWriter, r *http.Request, endpoint string) {
+	var req models.SendSeenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ChatID == "" {
+		writeError(w, http.StatusBadRequest, "invalid payload", "", "")
+		return
+	}
+
+	if _, ok := s.workingSession(w, req.Session); !ok {
+		return
+	}
+
+	s.mu.Lock()
+	s.actions = append(s.actions, ChatAction{
+		Endpoint:   endpoint,
+		Session:    req.Session,
+		ChatID:     req.ChatID,
+		MessageIDs: req.MessageIDs,
+	})
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{})
+}
+
+// --- Media ---
+
+func (s *Server) handleFile(w http.ResponseWriter, path string) {
+	s.mu.Lock()
+	file, ok := s.files[path]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "file not found", "", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", file.mimetype)
+	w.WriteHeader(http.StatusOK)
+	w.Write(file.data)
+}
+
+// --- Helpers ---
+
+func (s *Server) workingSession(w http.ResponseWriter, name string) (*session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "session not found", name, "")
+		return nil, false
+	}
+	if sess.status != enums.WAHA_SESSION_WORKING.String() {
+		writeError(w, http.StatusUnprocessableEntity, "Session status is not as expected.", name, sess.status, enums.WAHA_SESSION_WORKING.String())
+		return nil, false
+	}
+
+	snapshot := *sess
+	return &snapshot, true
+}
+
+func (s *Server) takeFailure(path string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.failures[path]
+	if ok {
+		delete(s.failures, path)
+	}
+	return status, ok
+}
+
+func (s *Server) nextMessageID(fromMe bool, chatID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgSeq++
+	return fmt.Sprintf("%t_%s_WAHATEST%06d", fromMe, chatID, s.msgSeq)
+}
+
+func sessionInfo(name string, sess *session) models.SessionInfo {
+	return models.SessionInfo{Name: name, Status: sess.status, Me: sess.me}
+}
+
+func qrValue(name string, seq int) string {
+	return fmt.Sprintf("2@%s,%d", name, seq)
+}
+
+// qrImage renders a tiny PNG whose colour changes with every rotation, so
+// callers can tell consecutive QR codes apart.
+func qrImage(seq int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(seq * 37)})
+		}
+	}
+	return img
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers in WAHA's error shape.
+func writeError(w http.ResponseWriter, status int, message, sessionName, sessionStatus string, expected ...string) {
+	body := map[string]any{"error": message}
+	if sessionName != "" {
+		body["session"] = sessionName
+	}
+	if sessionStatus != "" {
+		body["status"] = sessionStatus
+	}
+	if len(expected) > 0 {
+		body["expected"] = expected
+	}
+	writeJSON(w, status, body)
+}
@@ -0,0 +1,295 @@
+package wahatest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/enums"
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+)
+
+var me = models.MeInfo{ID: "15550001111@c.us", PushName: "Lumi"}
+
+func TestSessionLifecycle(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := srv.Client("default")
+
+	steps := []struct {
+		name   string
+		do     func() error
+		status enums.WAHA_SESSION_STATUS
+	}{
+		{"start", func() error { return client.StartSession(ctx) }, enums.WAHA_SESSION_SCAN_QR_CODE},
+		{"pair", func() error { return srv.Pair("default", me) }, enums.WAHA_SESSION_WORKING},
+		{"stop", func() error { return client.StopSession(ctx) }, enums.WAHA_SESSION_STOPPED},
+		// A paired device comes straight back up.
+		{"start again", func() error { return client.StartSession(ctx) }, enums.WAHA_SESSION_WORKING},
+	}
+
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := srv.Status("default"); got != step.status.String() {
+			t.Fatalf("status after %s = %q, want %q", step.name, got, step.status)
+		}
+	}
+
+	profile, err := client.GetMe(ctx)
+	if err != nil {
+		t.Fatalf("GetMe: %v", err)
+	}
+	if profile.ID != me.ID {
+		t.Errorf("GetMe = %+v, want %s", profile, me.ID)
+	}
+}
+
+func TestPairingNeedsTheQRCode(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := srv.Client("default")
+
+	if _, err := client.GetQRCode(ctx); err == nil {
+		t.Errorf("GetQRCode before the session started succeeded")
+	}
+
+	if err := client.StartSession(ctx); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	qr, err := client.GetQRCode(ctx)
+	if err != nil {
+		t.Fatalf("GetQRCode: %v", err)
+	}
+	if !bytes.HasPrefix(qr, []byte("\x89PNG")) {
+		t.Errorf("QR code is not a PNG image")
+	}
+
+	if _, err := client.GetMe(ctx); err == nil {
+		t.Errorf("GetMe before pairing succeeded")
+	}
+	if err := srv.Pair("unknown", me); err == nil {
+		t.Errorf("pairing a session that doesn't exist succeeded")
+	}
+}
+
+func TestWebhookPush(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	var (
+		mu       sync.Mutex
+		received []models.WAHAWebhook
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mac := hmac.New(sha512.New, []byte("secret"))
+		mac.Write(body)
+		if r.Header.Get("X-Webhook-Hmac") != hex.EncodeToString(mac.Sum(nil)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+
+		var webhook models.WAHAWebhook
+		if err := json.Unmarshal(body, &webhook); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		received = append(received, webhook)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	srv.SetWebhook(hook.URL, "secret")
+
+	if err := srv.Client("default").StartSession(context.Background()); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if err := srv.Pair("default", me); err != nil {
+		t.Fatalf("Pair: %v", err)
+	}
+	if err := srv.EmitMessage("default", models.WAMessage{From: "15552223333@c.us", To: me.ID, Body: "hello"}); err != nil {
+		t.Fatalf("EmitMessage: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var statuses []string
+	for _, webhook := range received {
+		if webhook.Event == "session.status" {
+			var payload models.SessionStatusPayload
+			json.Unmarshal(webhook.Payload, &payload)
+			statuses = append(statuses, payload.Status)
+		}
+	}
+	if len(statuses) == 0 || statuses[len(statuses)-1] != enums.WAHA_SESSION_WORKING.String() {
+		t.Errorf("session.status events = %v, want them to end in WORKING", statuses)
+	}
+
+	last := received[len(received)-1]
+	if last.Event != "message.any" || last.Session != "default" || last.ID == "" {
+		t.Fatalf("last webhook = %+v, want a message.any on default with an id", last)
+	}
+	var msg models.WAMessage
+	if err := json.Unmarshal(last.Payload, &msg); err != nil {
+		t.Fatalf("decoding the message: %v", err)
+	}
+	if msg.Body != "hello" || msg.ID == "" || msg.Timestamp == 0 {
+		t.Errorf("message = %+v, want \"hello\" with an id and a timestamp", msg)
+	}
+
+	srv.SetWebhook(hook.URL, "wrong")
+	if err := srv.Emit("default", "message.any", msg); err == nil {
+		t.Errorf("Emit with a rejected signature succeeded")
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	events := make(chan models.WAHAWebhook, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go connections.NewEventStream(srv.URL, srv.APIKey).Run(ctx, func(ctx context.Context, event models.WAHAWebhook) {
+		events <- event
+	})
+
+	waitForSockets := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for srv.Sockets() != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("%d sockets connected, want %d", srv.Sockets(), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// A dropped socket is dialled again after the backoff, and events keep
+	// flowing.
+	steps := []struct {
+		name string
+		drop bool
+		body string
+	}{
+		{"connected", false, "first"},
+		{"after WAHA restarted", true, "second"},
+	}
+
+	for _, step := range steps {
+		if step.drop {
+			srv.DropSockets()
+			waitForSockets(0)
+		}
+		waitForSockets(1)
+
+		if err := srv.EmitMessage("default", models.WAMessage{From: "15552223333@c.us", To: me.ID, Body: step.body}); err != nil {
+			t.Fatalf("%s: EmitMessage: %v", step.name, err)
+		}
+
+		select {
+		case event := <-events:
+			var msg models.WAMessage
+			json.Unmarshal(event.Payload, &msg)
+			if event.Event != "message.any" || event.Session != "default" || msg.Body != step.body {
+				t.Errorf("%s: event %+v with %+v, want message.any %q on default", step.name, event, msg, step.body)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no event received", step.name)
+		}
+	}
+
+	cancel()
+	waitForSockets(0)
+}
+
+func TestEventStreamNeedsTheAPIKey(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	connections.NewEventStream(srv.URL, "wrong").Run(ctx, func(ctx context.Context, event models.WAHAWebhook) {})
+
+	if n := srv.Sockets(); n != 0 {
+		t.Errorf("%d sockets connected with a wrong API key", n)
+	}
+}
+
+func TestRecordsSends(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := srv.Client("default")
+
+	if _, err := client.SendText(ctx, "15552223333@c.us", "before pairing"); err == nil {
+		t.Errorf("SendText on a session that isn't working succeeded")
+	}
+
+	if err := client.StartSession(ctx); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if err := srv.Pair("default", me); err != nil {
+		t.Fatalf("Pair: %v", err)
+	}
+
+	sends := []struct {
+		endpoint, chatID, text string
+		send                   func(connections.WahaClient) (*models.WAMessage, error)
+	}{
+		{"sendText", "15552223333@c.us", "hi", func(c connections.WahaClient) (*models.WAMessage, error) {
+			return c.SendText(ctx, "15552223333@c.us", "hi")
+		}},
+		{"sendImage", "120363000000000000@g.us", "look", func(c connections.WahaClient) (*models.WAMessage, error) {
+			return c.SendImage(ctx, "120363000000000000@g.us", models.ImagePayload{Caption: "look"})
+		}},
+	}
+
+	for i, tt := range sends {
+		sent, err := tt.send(client)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.endpoint, err)
+		}
+
+		recorded := srv.SentTo(tt.chatID)
+		if len(recorded) != 1 {
+			t.Fatalf("%s: recorded %d sends to %s, want 1", tt.endpoint, len(recorded), tt.chatID)
+		}
+		got := recorded[0]
+		if got.ID != sent.ID.String() || got.Endpoint != tt.endpoint || got.Session != "default" || got.Text != tt.text {
+			t.Errorf("%s: recorded %+v", tt.endpoint, got)
+		}
+		if len(srv.Sent()) != i+1 {
+			t.Errorf("%s: %d sends recorded in total, want %d", tt.endpoint, len(srv.Sent()), i+1)
+		}
+	}
+
+	srv.FailNext("/api/sendText", http.StatusInternalServerError)
+	if _, err := client.SendText(ctx, "15552223333@c.us", "lost"); err == nil {
+		t.Errorf("SendText after FailNext succeeded")
+	}
+	if got := len(srv.Sent()); got != len(sends) {
+		t.Errorf("a failed send was recorded: %d sends", got)
+	}
+}
@@ -0,0 +1,99 @@
+package wahatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+// PostedStatus is a status/* call recorded by the fake.
+type PostedStatus struct {
+	ID      string
+	Kind    string // "text", "image", "video"
+	Session string
+	Text    string // text or caption
+	Payload map[string]any
+	Deleted bool
+}
+
+// Statuses returns a copy of every status posted on the fake.
+func (s *Server) Statuses() []PostedStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PostedStatus(nil), s.statuses...)
+}
+
+// ViewStatus simulates viewer seeing the status posted under statusID by
+// emitting the message.ack WAHA sends for it.
+func (s *Server) ViewStatus(sessionName, statusID, viewer string) error {
+	return s.Emit(sessionName, "message.ack", models.MessageAckPayload{
+		ID:          models.WAMessageID(fmt.Sprintf("true_%s_%s_%s", models.StatusBroadcastID, statusID, viewer)),
+		From:        models.StatusBroadcastID,
+		Participant: viewer,
+		FromMe:      true,
+		Ack:         3,
+		AckName:     "READ",
+	})
+}
+
+// handleStatus serves /api/{session}/status/{action}.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request, name, action string) {
+	if _, ok := s.workingSession(w, name); !ok {
+		return
+	}
+
+	if action == "new-message-id" && r.Method == http.MethodGet {
+		s.mu.Lock()
+		s.statusSeq++
+		id := fmt.Sprintf("STATUS%06d", s.statusSeq)
+		s.mu.Unlock()
+
+		writeJSON(w, http.StatusOK, models.StatusMessageID{ID: id})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusNotFound, "not found: "+r.Method+" "+r.URL.Path, "", "")
+		return
+	}
+
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid status payload", name, "")
+		return
+	}
+	id, _ := payload["id"].(string)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch action {
+	case "text", "image", "video":
+		text, _ := payload["text"].(string)
+		if action != "text" {
+			text, _ = payload["caption"].(string)
+		}
+		if id == "" {
+			s.statusSeq++
+			id = fmt.Sprintf("STATUS%06d", s.statusSeq)
+		}
+		s.statuses = append(s.statuses, PostedStatus{ID: id, Kind: action, Session: name, Text: text, Payload: payload})
+		writeJSON(w, http.StatusCreated, map[string]any{"id": id, "timestamp": time.Now().Unix()})
+
+	case "delete":
+		for i := range s.statuses {
+			if s.statuses[i].ID == id && s.statuses[i].Session == name {
+				s.statuses[i].Deleted = true
+				writeJSON(w, http.StatusCreated, map[string]any{})
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "status not found", name, "")
+
+	default:
+		writeError(w, http.StatusNotFound, "not found: "+r.Method+" "+r.URL.Path, "", "")
+	}
+}
@@ -0,0 +1,99 @@
+package wahatest
+
+import (
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{}
+
+// socket is a client of the fake's /ws event stream.
+type socket struct {
+	conn    *websocket.Conn
+	session string // "" or "*" for all sessions
+	events  chan []byte
+}
+
+// DropSockets disconnects every websocket client, as a WAHA restart would.
+func (s *Server) DropSockets() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sock := range s.sockets {
+		sock.conn.Close()
+	}
+}
+
+// Sockets returns how many websocket clients are connected.
+func (s *Server) Sockets() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sockets)
+}
+
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("x-api-key")
+	if key == "" {
+		key = r.Header.Get("X-Api-Key")
+	}
+	if s.APIKey != "" && key != s.APIKey {
+		writeError(w, http.StatusUnauthorized, "invalid api key", "", "")
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	sock := &socket{conn: conn, session: r.URL.Query().Get("session"), events: make(chan []byte, 64)}
+	s.mu.Lock()
+	s.sockets[sock] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.sockets, sock)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	// Reading is only needed to notice the client going away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case body := <-sock.events:
+			if err := conn.WriteMessage(websocket.TextMessage, body); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// broadcast queues an event for the sockets following its session.
+func (s *Server) broadcast(sessionName string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sock := range s.sockets {
+		if sock.session != "" && sock.session != "*" && sock.session != sessionName {
+			continue
+		}
+		select {
+		case sock.events <- body:
+		default:
+		}
+	}
+}
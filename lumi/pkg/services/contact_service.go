@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	contactListTTL      = 10 * time.Minute
+	contactDetailsTTL   = 6 * time.Hour
+	numberCheckTTL      = 24 * time.Hour
+	MaxBulkNumberChecks = 50
+)
+
+var (
+	ErrNoNumbers       = errors.New("at least one phone number is required")
+	ErrTooManyNumbers  = errors.New("too many phone numbers in one request")
+	ErrContactNotFound = errors.New("contact not found")
+)
+
+// ContactService serves the contacts directory out of the contacts table,
+// going to WAHA only when the cached copy is older than its TTL.
+type ContactService struct {
+	sessionService *SessionService
+
+	mu      sync.Mutex
+	numbers map[string]models.NumberCheck // keyed by session name + phone
+}
+
+func NewContactService(sessionService *SessionService) *ContactService {
+	return &ContactService{
+		sessionService: sessionService,
+		numbers:        make(map[string]models.NumberCheck),
+	}
+}
+
+// ListContacts returns the session's contacts, optionally filtered by a
+// name or number fragment. The list is re-synced from WAHA when it is stale
+// or refresh is set; if that fails, the cached list is served instead.
+func (s *ContactService) ListContacts(ctx context.Context, userID uint, query string, refresh bool) ([]models.Contact, error) {
+	session, err := s.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return nil, err
+	}
+	sessionName := session.WahaSessionName
+
+	if refresh || s.listIsStale(sessionName) {
+		if err := s.syncContacts(ctx, sessionName); err != nil {
+			if refresh {
+				return nil, err
+			}
+			log.Printf("Contacts: serving cached list for %s, sync failed: %v", sessionName, err)
+		}
+	}
+
+	dbQuery := db.DB.Where("session_name = ?", sessionName)
+	if query = strings.TrimSpace(query); query != "" {
+		like := "%" + strings.ToLower(query) + "%"
+		dbQuery = dbQuery.Where("LOWER(name) LIKE ? OR LOWER(push_name) LIKE ? OR number LIKE ?", like, like, like)
+	}
+
+	var contacts []models.Contact
+	if err := dbQuery.Order("name asc, push_name asc, number asc").Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+	return contacts, nil
+}
+
+// GetContact returns one contact including its about text and profile
+// picture, fetching those from WAHA when missing, stale or refresh is set.
+func (s *ContactService) GetContact(ctx context.Context, userID uint, contactID string, refresh bool) (*models.Contact, error) {
+	session, err := s.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return nil, err
+	}
+	sessionName := session.WahaSessionName
+	client := s.sessionService.Client(sessionName)
+
+	var contact models.Contact
+	err = db.DB.Where("session_name = ? AND contact_id = ?", sessionName, contactID).First(&contact).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		remote, err := client.GetContact(ctx, contactID)
+		if err != nil {
+			return nil, err
+		}
+		if remote.ID == "" {
+			return nil, ErrContactNotFound
+		}
+		contact = contactFromRemote(sessionName, *remote, time.Now())
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !refresh && contact.DetailsAt != nil && time.Since(*contact.DetailsAt) < contactDetailsTTL {
+		return &contact, nil
+	}
+
+	// About and picture are often hidden by privacy settings; a failure to
+	// fetch one should not hide the rest of the contact.
+	if about, err := client.GetContactAbout(ctx, contactID); err != nil {
+		log.Printf("Contacts: failed to fetch about of %s: %v", contactID, err)
+	} else {
+		contact.About = about
+	}
+	if picture, err := client.GetProfilePicture(ctx, contactID, refresh); err != nil {
+		log.Printf("Contacts: failed to fetch profile picture of %s: %v", contactID, err)
+	} else {
+		contact.PictureURL = picture
+	}
+
+	now := time.Now()
+	contact.DetailsAt = &now
+	if err := db.DB.Save(&contact).Error; err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// CheckNumbers reports for each phone number whether it is on WhatsApp.
+// Answers are cached for numberCheckTTL.
+func (s *ContactService) CheckNumbers(ctx context.Context, userID uint, phones []string) ([]models.NumberCheck, error) {
+	var digits []string
+	seen := make(map[string]bool)
+	for _, phone := range phones {
+		d := utils.PhoneDigits(phone)
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		digits = append(digits, d)
+	}
+
+	if len(digits) == 0 {
+		return nil, ErrNoNumbers
+	}
+	if len(digits) > MaxBulkNumberChecks {
+		return nil, ErrTooManyNumbers
+	}
+
+	session, err := s.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return nil, err
+	}
+	client := s.sessionService.Client(session.WahaSessionName)
+
+	results := make([]models.NumberCheck, 0, len(digits))
+	for _, phone := range digits {
+		key := session.WahaSessionName + "|" + phone
+
+		s.mu.Lock()
+		cached, ok := s.numbers[key]
+		s.mu.Unlock()
+
+		if ok && time.Since(cached.CheckedAt) < numberCheckTTL {
+			results = append(results, cached)
+			continue
+		}
+
+		res, err := client.CheckNumberExists(ctx, phone)
+		if err != nil {
+			return nil, err
+		}
+
+		check := models.NumberCheck{Phone: phone, Exists: res.NumberExists, ChatID: res.ChatID, CheckedAt: time.Now()}
+		s.mu.Lock()
+		s.numbers[key] = check
+		s.mu.Unlock()
+
+		results = append(results, check)
+	}
+	return results, nil
+}
+
+// SetBlocked blocks or unblocks a contact and keeps the cached copy in step.
+func (s *ContactService) SetBlocked(ctx context.Context, userID uint, contactID string, blocked bool) error {
+	session, err := s.sessionService.GetOrCreateUserSession(userID)
+	if err != nil {
+		return err
+	}
+	client := s.sessionService.Client(session.WahaSessionName)
+
+	if blocked {
+		err = client.BlockContact(ctx, contactID)
+	} else {
+		err = client.UnblockContact(ctx, contactID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return db.DB.Model(&models.Contact{}).
+		Where("session_name = ? AND contact_id = ?", session.WahaSessionName, contactID).
+		Update("is_blocked", blocked).Error
+}
+
+func (s *ContactService) listIsStale(sessionName string) bool {
+	var latest models.Contact
+	err := db.DB.Where("session_name = ?", sessionName).Order("synced_at desc").First(&latest).Error
+	if err != nil {
+		return true
+	}
+	return time.Since(latest.SyncedAt) > contactListTTL
+}
+
+// syncContacts upserts WAHA's contact list into the cache. About and picture
+// are left alone, and contacts WAHA no longer returns are removed.
+func (s *ContactService) syncContacts(ctx context.Context, sessionName string) error {
+	remote, err := s.sessionService.Client(sessionName).GetContacts(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Truncated so the value read back from the database compares equal
+	// in the cleanup below.
+	now := time.Now().Truncate(time.Millisecond)
+	contacts := make([]models.Contact, 0, len(remote))
+	for _, c := range remote {
+		if c.IsGroup || c.ID == "" {
+			continue
+		}
+		contacts = append(contacts, contactFromRemote(sessionName, c, now))
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if len(contacts) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "session_name"}, {Name: "contact_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"number", "name", "push_name", "is_my_contact", "is_blocked", "synced_at", "updated_at", "deleted_at"}),
+			}).CreateInBatches(&contacts, 100).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("session_name = ? AND synced_at < ?", sessionName, now).Delete(&models.Contact{}).Error
+	})
+}
+
+func contactFromRemote(sessionName string, c connModel.WAContact, syncedAt time.Time) models.Contact {
+	number := c.Number
+	if number == "" {
+		number, _, _ = strings.Cut(c.ID, "@")
+	}
+
+	return models.Contact{
+		SessionName: sessionName,
+		ContactID:   c.ID,
+		Number:      number,
+		Name:        c.Name,
+		PushName:    c.PushName,
+		IsMyContact: c.IsMyContact,
+		IsBlocked:   c.IsBlocked,
+		SyncedAt:    syncedAt,
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/db/dbtest"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections/wahatest"
+)
+
+// newContactTest returns a contact service whose user 1 has a paired
+// wahatest session.
+func newContactTest(t *testing.T) (*ContactService, *wahatest.Server) {
+	t.Helper()
+
+	dbtest.Open(t, &models.WhatsAppSession{}, &models.Contact{})
+
+	srv := wahatest.NewServer()
+	t.Cleanup(srv.Close)
+	useConfig(t, &config.Config{WahaServiceURL: srv.URL, WahaAPIKey: srv.APIKey, WahaSessionName: "lumi"})
+
+	sessions := NewSessionService()
+	session, err := sessions.GetOrCreateUserSession(1)
+	if err != nil {
+		t.Fatalf("GetOrCreateUserSession: %v", err)
+	}
+	if err := sessions.Client(session.WahaSessionName).StartSession(context.Background()); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if err := srv.Pair(session.WahaSessionName, connModel.MeInfo{ID: "15550001111@c.us"}); err != nil {
+		t.Fatalf("Pair: %v", err)
+	}
+
+	return NewContactService(sessions), srv
+}
+
+func TestListContacts(t *testing.T) {
+	contacts, srv := newContactTest(t)
+	ctx := context.Background()
+
+	srv.SetContacts([]connModel.WAContact{
+		{ID: "15552223333@c.us", Number: "15552223333", Name: "Ada"},
+		{ID: "15554445555@c.us", PushName: "Grace"},
+		{ID: "120363000000000000@g.us", Name: "Book club", IsGroup: true},
+	})
+
+	tests := []struct {
+		name    string
+		query   string
+		refresh bool
+		want    []string
+	}{
+		{"first call syncs", "", false, []string{"15554445555@c.us", "15552223333@c.us"}},
+		{"by name", "ada", false, []string{"15552223333@c.us"}},
+		{"by push name", "GRACE", false, []string{"15554445555@c.us"}},
+		{"by number", "4445", false, []string{"15554445555@c.us"}},
+		{"no match", "linus", false, nil},
+	}
+	for _, tt := range tests {
+		got, err := contacts.ListContacts(ctx, 1, tt.query, tt.refresh)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ids := contactIDs(got); fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: contacts %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+
+	// The cached list is served until it goes stale or a refresh is asked
+	// for; a refresh drops contacts WAHA no longer returns.
+	srv.SetContacts([]connModel.WAContact{{ID: "15552223333@c.us", Name: "Ada"}})
+
+	cached, _ := contacts.ListContacts(ctx, 1, "", false)
+	if len(cached) != 2 {
+		t.Errorf("cached list has %d contacts, want 2", len(cached))
+	}
+	refreshed, err := contacts.ListContacts(ctx, 1, "", true)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if ids := contactIDs(refreshed); fmt.Sprint(ids) != "[15552223333@c.us]" {
+		t.Errorf("refreshed list = %v, want only Ada", ids)
+	}
+
+	srv.FailNext("/api/contacts/all", http.StatusInternalServerError)
+	if _, err := contacts.ListContacts(ctx, 1, "", true); err == nil {
+		t.Errorf("a failed refresh succeeded")
+	}
+}
+
+func TestCheckNumbers(t *testing.T) {
+	contacts, srv := newContactTest(t)
+	ctx := context.Background()
+
+	srv.SetNumberExists("15552223333", true)
+	srv.SetNumberExists("15559990000", false)
+
+	tooMany := make([]string, MaxBulkNumberChecks+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("1555000%04d", i)
+	}
+
+	tests := []struct {
+		name   string
+		phones []string
+		want   []bool
+		err    error
+	}{
+		{"formatted and duplicate", []string{"+1 555 222-3333", "15552223333", "15559990000"}, []bool{true, false}, nil},
+		{"no digits", []string{"", "n/a"}, nil, ErrNoNumbers},
+		{"too many", tooMany, nil, ErrTooManyNumbers},
+	}
+	for _, tt := range tests {
+		got, err := contacts.CheckNumbers(ctx, 1, tt.phones)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+		var exists []bool
+		for _, check := range got {
+			exists = append(exists, check.Exists)
+		}
+		if fmt.Sprint(exists) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: exists %v, want %v", tt.name, exists, tt.want)
+		}
+	}
+
+	// A cached answer does not go back to WAHA.
+	srv.FailNext("/api/contacts/check-exists", http.StatusInternalServerError)
+	if _, err := contacts.CheckNumbers(ctx, 1, []string{"15552223333"}); err != nil {
+		t.Errorf("cached check went to WAHA: %v", err)
+	}
+}
+
+func TestSetBlocked(t *testing.T) {
+	contacts, srv := newContactTest(t)
+	ctx := context.Background()
+
+	srv.SetContacts([]connModel.WAContact{{ID: "15552223333@c.us", Name: "Ada"}})
+	if _, err := contacts.ListContacts(ctx, 1, "", true); err != nil {
+		t.Fatalf("ListContacts: %v", err)
+	}
+
+	for _, blocked := range []bool{true, false} {
+		if err := contacts.SetBlocked(ctx, 1, "15552223333@c.us", blocked); err != nil {
+			t.Fatalf("SetBlocked(%v): %v", blocked, err)
+		}
+		list, _ := contacts.ListContacts(ctx, 1, "", false)
+		if len(list) != 1 || list[0].IsBlocked != blocked {
+			t.Errorf("after SetBlocked(%v) the cache has %+v", blocked, list)
+		}
+	}
+}
+
+func contactIDs(contacts []models.Contact) []string {
+	var ids []string
+	for _, c := range contacts {
+		ids = append(ids, c.ContactID)
+	}
+	return ids
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// WAHA gives up retrying a webhook well within a day.
+	dedupTTL        = 24 * time.Hour
+	dedupPruneEvery = time.Hour
+)
+
+// DedupService remembers which webhook events and messages were already
+// handled, so retried deliveries and the message/message.any pair don't
+// make the bot answer twice. It is the only place webhooks are
+// deduplicated, and it survives restarts.
+type DedupService struct {
+	since time.Time
+	stats map[string]*models.DedupStats // per session
+	mu    sync.Mutex
+}
+
+func NewDedupService() *DedupService {
+	return &DedupService{
+		since: time.Now(),
+		stats: make(map[string]*models.DedupStats),
+	}
+}
+
+// FirstEvent records the webhook event id and reports whether it is the
+// first delivery of it.
+func (s *DedupService) FirstEvent(sessionName, eventID string) bool {
+	s.count(sessionName, func(stats *models.DedupStats) { stats.Events++ })
+	if eventID == "" {
+		return true
+	}
+
+	first := s.claim("event:" + eventID)
+	if !first {
+		s.count(sessionName, func(stats *models.DedupStats) { stats.DuplicateEvents++ })
+	}
+	return first
+}
+
+// FirstMessage records the message id and reports whether the message is
+// seen for the first time on the session.
+func (s *DedupService) FirstMessage(sessionName, messageID string) bool {
+	if messageID == "" {
+		return true
+	}
+
+	first := s.claim(fmt.Sprintf("message:%s:%s", sessionName, messageID))
+	if !first {
+		s.count(sessionName, func(stats *models.DedupStats) { stats.DuplicateMessages++ })
+	}
+	return first
+}
+
+// ForgetMessage drops the record of a message that was claimed but could
+// not be handled, so a redelivery gets through.
+func (s *DedupService) ForgetMessage(sessionName, messageID string) {
+	key := fmt.Sprintf("message:%s:%s", sessionName, messageID)
+	if err := db.DB.Delete(&models.ProcessedWebhook{Key: key}).Error; err != nil {
+		log.Printf("Dedup: failed to forget %s: %v", key, err)
+	}
+}
+
+// Stats returns the counters of the given session.
+func (s *DedupService) Stats(sessionName string) models.DedupStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := models.DedupStats{Since: s.since}
+	if counted, ok := s.stats[sessionName]; ok {
+		stats = *counted
+	}
+	return stats
+}
+
+func (s *DedupService) count(sessionName string, add func(stats *models.DedupStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.stats[sessionName]
+	if !ok {
+		stats = &models.DedupStats{Since: s.since}
+		s.stats[sessionName] = stats
+	}
+	add(stats)
+}
+
+// Start prunes expired entries until ctx is cancelled.
+func (s *DedupService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(dedupPruneEvery)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.prune()
+			}
+		}
+	}()
+}
+
+// claim inserts key unless a live entry exists. When the database can't
+// tell, the event is let through: answering twice beats not answering.
+func (s *DedupService) claim(key string) bool {
+	now := time.Now()
+	entry := models.ProcessedWebhook{Key: key, ExpiresAt: now.Add(dedupTTL)}
+
+	// An expired entry is taken over rather than treated as a duplicate.
+	result := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at", "created_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Lt{Column: "processed_webhooks.expires_at", Value: now}}},
+	}).Create(&entry)
+	if result.Error != nil {
+		log.Printf("Dedup: failed to record %s: %v", key, result.Error)
+		return true
+	}
+	return result.RowsAffected > 0
+}
+
+func (s *DedupService) prune() {
+	result := db.DB.Where("expires_at < ?", time.Now()).Delete(&models.ProcessedWebhook{})
+	if result.Error != nil {
+		log.Printf("Dedup: failed to prune: %v", result.Error)
+	}
+}
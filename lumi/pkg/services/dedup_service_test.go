@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/db/dbtest"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+)
+
+func TestDedup(t *testing.T) {
+	dbtest.Open(t, &models.ProcessedWebhook{})
+	dedup := NewDedupService()
+
+	// An entry past its TTL no longer counts as handled.
+	if err := db.DB.Create(&models.ProcessedWebhook{Key: "event:evt_old", ExpiresAt: time.Now().Add(-time.Minute)}).Error; err != nil {
+		t.Fatalf("creating expired entry: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		first   func() bool
+		isFirst bool
+	}{
+		{"new event", func() bool { return dedup.FirstEvent("alice", "evt_1") }, true},
+		{"redelivered event", func() bool { return dedup.FirstEvent("alice", "evt_1") }, false},
+		{"event without id", func() bool { return dedup.FirstEvent("alice", "") }, true},
+		{"expired event", func() bool { return dedup.FirstEvent("alice", "evt_old") }, true},
+		{"new message", func() bool { return dedup.FirstMessage("alice", "false_1@c.us_AAA") }, true},
+		{"message seen again", func() bool { return dedup.FirstMessage("alice", "false_1@c.us_AAA") }, false},
+		{"same message on another session", func() bool { return dedup.FirstMessage("bob", "false_1@c.us_AAA") }, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.first(); got != tt.isFirst {
+			t.Errorf("%s: first = %v, want %v", tt.name, got, tt.isFirst)
+		}
+	}
+
+	alice, bob := dedup.Stats("alice"), dedup.Stats("bob")
+	if alice.Events != 4 || alice.DuplicateEvents != 1 || alice.DuplicateMessages != 1 {
+		t.Errorf("alice's stats = %+v, want 4 events, 1 duplicate event and 1 duplicate message", alice)
+	}
+	if bob.Events != 0 || bob.DuplicateEvents != 0 || bob.DuplicateMessages != 0 {
+		t.Errorf("bob's stats = %+v, want none", bob)
+	}
+
+	dedup.prune()
+	var left int64
+	db.DB.Model(&models.ProcessedWebhook{}).Count(&left)
+	if left != 4 {
+		t.Errorf("%d entries after pruning, want the 4 live ones", left)
+	}
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/events"
+)
+
+// EventDispatcher takes WAHA events however they arrived, webhook or
+// websocket, and publishes the new ones of known sessions on the bus.
+type EventDispatcher struct {
+	bus            *events.Bus
+	sessionService *SessionService
+	dedupService   *DedupService
+}
+
+func NewEventDispatcher(bus *events.Bus, sessionService *SessionService, dedupService *DedupService) *EventDispatcher {
+	return &EventDispatcher{
+		bus:            bus,
+		sessionService: sessionService,
+		dedupService:   dedupService,
+	}
+}
+
+func (d *EventDispatcher) Dispatch(ctx context.Context, webhook connModel.WAHAWebhook) {
+	if _, err := d.sessionService.GetSessionByName(webhook.Session); err != nil {
+		log.Printf("Ignoring %s event for unknown session %s: %v", webhook.Event, webhook.Session, err)
+		return
+	}
+
+	// WAHA retries deliveries it didn't see acknowledged in time.
+	if !d.dedupService.FirstEvent(webhook.Session, webhook.ID) {
+		log.Printf("Skipping duplicate %s event %s", webhook.Event, webhook.ID)
+		return
+	}
+
+	event, err := events.FromWebhook(webhook)
+	if err != nil {
+		log.Printf("Failed to decode %s event: %v", webhook.Event, err)
+		return
+	}
+
+	d.bus.Publish(ctx, event)
+}
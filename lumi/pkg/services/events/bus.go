@@ -0,0 +1,133 @@
+// Package events turns WAHA webhooks into typed events and fans them out to
+// the features interested in them.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+// WAHA webhook events with a typed payload.
+const (
+	SessionStatus   = "session.status"   // connModel.SessionStatusPayload
+	Message         = "message"          // connModel.WAMessage, incoming only
+	MessageAny      = "message.any"      // connModel.WAMessage
+	MessageAck      = "message.ack"      // connModel.MessageAckPayload
+	MessageReaction = "message.reaction" // connModel.MessageReactionPayload
+	MessageRevoked  = "message.revoked"  // connModel.MessageRevokedPayload
+	PollVote        = "poll.vote"        // connModel.PollVotePayload
+	GroupJoin       = "group.join"       // connModel.GroupNotificationPayload
+	GroupLeave      = "group.leave"      // connModel.GroupNotificationPayload
+	PresenceUpdate  = "presence.update"  // connModel.PresenceUpdatePayload
+	CallReceived    = "call.received"    // connModel.CallPayload
+	LabelUpsert     = "label.upsert"     // connModel.Label
+)
+
+var decoders = map[string]func(json.RawMessage) (any, error){
+	SessionStatus:   decode[connModel.SessionStatusPayload],
+	Message:         decode[connModel.WAMessage],
+	MessageAny:      decode[connModel.WAMessage],
+	MessageAck:      decode[connModel.MessageAckPayload],
+	MessageReaction: decode[connModel.MessageReactionPayload],
+	MessageRevoked:  decode[connModel.MessageRevokedPayload],
+	PollVote:        decode[connModel.PollVotePayload],
+	GroupJoin:       decode[connModel.GroupNotificationPayload],
+	GroupLeave:      decode[connModel.GroupNotificationPayload],
+	PresenceUpdate:  decode[connModel.PresenceUpdatePayload],
+	CallReceived:    decode[connModel.CallPayload],
+	LabelUpsert:     decode[connModel.Label],
+}
+
+func decode[T any](raw json.RawMessage) (any, error) {
+	var payload T
+	err := json.Unmarshal(raw, &payload)
+	return payload, err
+}
+
+// Event is a webhook event. Payload holds the typed payload listed next to
+// the event's name, or the raw JSON for events without one.
+type Event struct {
+	ID        string
+	Name      string
+	Session   string
+	Timestamp time.Time
+	Payload   any
+}
+
+// FromWebhook decodes a webhook into an Event.
+func FromWebhook(webhook connModel.WAHAWebhook) (Event, error) {
+	event := Event{
+		ID:        webhook.ID,
+		Name:      webhook.Event,
+		Session:   webhook.Session,
+		Timestamp: time.UnixMilli(webhook.Timestamp),
+		Payload:   webhook.Payload,
+	}
+
+	if decode, ok := decoders[webhook.Event]; ok {
+		payload, err := decode(webhook.Payload)
+		if err != nil {
+			return event, err
+		}
+		event.Payload = payload
+	}
+	return event, nil
+}
+
+type Handler func(ctx context.Context, event Event)
+
+// Bus delivers events to the handlers subscribed to their name. Handlers
+// run in the publisher's goroutine and should hand long work off.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[string][]Handler)}
+}
+
+func (b *Bus) Subscribe(name string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[name] = append(b.handlers[name], handler)
+}
+
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	b.mu.RLock()
+	handlers := b.handlers[event.Name]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		b.run(ctx, event, handler)
+	}
+}
+
+// run isolates handlers from each other: one panicking doesn't keep the
+// event from the rest.
+func (b *Bus) run(ctx context.Context, event Event, handler Handler) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Events: handler for %s panicked: %v", event.Name, r)
+		}
+	}()
+	handler(ctx, event)
+}
+
+// On subscribes fn to the events named name, whose payload must be a T.
+func On[T any](bus *Bus, name string, fn func(ctx context.Context, session string, payload T)) {
+	bus.Subscribe(name, func(ctx context.Context, event Event) {
+		payload, ok := event.Payload.(T)
+		if !ok {
+			log.Printf("Events: %s carries %T, not %T", name, event.Payload, payload)
+			return
+		}
+		fn(ctx, event.Session, payload)
+	})
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+func TestFromWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		payload string
+		want    any
+		fails   bool
+	}{
+		{"session status", SessionStatus, `{"status":"WORKING"}`, connModel.SessionStatusPayload{Status: "WORKING"}, false},
+		{"revoked", MessageRevoked, `{"revokedMessageId":"AAA"}`, connModel.MessageRevokedPayload{RevokedMessageID: "AAA"}, false},
+		{"ack", MessageAck, `{"id":"true_1@c.us_AAA","ack":3,"ackName":"READ"}`, connModel.MessageAckPayload{ID: "true_1@c.us_AAA", Ack: 3, AckName: "READ"}, false},
+		{"event without a type keeps the raw JSON", "chat.archive", `{"id":"1@c.us"}`, json.RawMessage(`{"id":"1@c.us"}`), false},
+		{"malformed payload", SessionStatus, `{"status":1}`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := FromWebhook(connModel.WAHAWebhook{ID: "evt_1", Event: tt.event, Session: "default", Timestamp: 1700000000000, Payload: json.RawMessage(tt.payload)})
+			if (err != nil) != tt.fails {
+				t.Fatalf("error = %v, want failure %v", err, tt.fails)
+			}
+			if tt.fails {
+				return
+			}
+			if event.Name != tt.event || event.Session != "default" || !event.Timestamp.Equal(time.UnixMilli(1700000000000)) {
+				t.Errorf("event = %+v", event)
+			}
+			if !reflect.DeepEqual(event.Payload, tt.want) {
+				t.Errorf("payload = %#v, want %#v", event.Payload, tt.want)
+			}
+		})
+	}
+}
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+
+	var got []string
+	bus.Subscribe(SessionStatus, func(ctx context.Context, event Event) { panic("broken subscriber") })
+	On(bus, SessionStatus, func(ctx context.Context, session string, payload connModel.SessionStatusPayload) {
+		got = append(got, session+" "+payload.Status)
+	})
+	On(bus, SessionStatus, func(ctx context.Context, session string, payload connModel.WAMessage) {
+		got = append(got, "wrong payload type")
+	})
+
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{"typed payload", Event{Name: SessionStatus, Session: "default", Payload: connModel.SessionStatusPayload{Status: "WORKING"}}, []string{"default WORKING"}},
+		{"payload of another type", Event{Name: SessionStatus, Session: "default", Payload: json.RawMessage(`{}`)}, nil},
+		{"no subscribers", Event{Name: PollVote, Session: "default"}, nil},
+	}
+
+	for _, tt := range tests {
+		got = nil
+		bus.Publish(context.Background(), tt.event)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: delivered %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+)
+
+const groupInviteBaseURL = "https://chat.whatsapp.com/"
+
+var ErrNoParticipants = errors.New("at least one participant is required")
+
+type GroupService struct {
+	sessionService *SessionService
+}
+
+func NewGroupService(sessionService *SessionService) *GroupService {
+	return &GroupService{sessionService: sessionService}
+}
+
+func (s *GroupService) ListGroups(ctx context.Context, userID uint) ([]connModel.GroupInfo, error) {
+	client, err := s.sessionService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetGroups(ctx)
+}
+
+func (s *GroupService) GetGroup(ctx context.Context, userID uint, groupID string) (*connModel.GroupInfo, error) {
+	client, err := s.sessionService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetGroup(ctx, groupID)
+}
+
+func (s *GroupService) CreateGroup(ctx context.Context, userID uint, name string, participants []string) (*connModel.GroupInfo, error) {
+	ids, err := participantIDs(participants)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.sessionService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return client.CreateGroup(ctx, name, ids)
+}
+
+// UpdateParticipants applies one of "add", "remove", "promote" or "demote"
+// to the given participants and returns the group as it is afterwards.
+func (s *GroupService) UpdateParticipants(ctx context.Context, userID uint, groupID, action string, participants []string) (*connModel.GroupInfo, error) {
+	ids, err := participantIDs(participants)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.sessionService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case "add":
+		err = client.AddGroupParticipants(ctx, groupID, ids)
+	case "remove":
+		err = client.RemoveGroupParticipants(ctx, groupID, ids)
+	case "promote":
+		err = client.PromoteGroupAdmins(ctx, groupID, ids)
+	case "demote":
+		err = client.DemoteGroupAdmins(ctx, groupID, ids)
+	default:
+		return nil, errors.New("unknown participant action: " + action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetGroup(ctx, groupID)
+}
+
+// UpdateGroupInfo changes whichever of subject and description are set.
+func (s *GroupService) UpdateGroupInfo(ctx context.Context, userID uint, groupID string, subject, description *string) (*connModel.GroupInfo, error) {
+	client, err := s.sessionService.ClientForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if subject != nil {
+		if err := client.SetGroupSubject(ctx, groupID, *subject); err != nil {
+			return nil, err
+		}
+	}
+	if description != nil {
+		if err := client.SetGroupDescription(ctx, groupID, *description); err != nil {
+			return nil, err
+		}
+	}
+
+	return client.GetGroup(ctx, groupID)
+}
+
+func (s *GroupService) SetGroupPicture(ctx context.Context, userID uint, groupID string, file connModel.FileWrapper) error {
+	client, err := s.sessionService.ClientForUser(userID)
+	if err != nil {
+		return err
+	}
+	return client.SetGroupPicture(ctx, groupID, file)
+}
+
+// GetInviteLink returns the group's invite link, revoking the current one
+// first when revoke is set.
+func (s *GroupService) GetInviteLink(ctx context.Context, userID uint, groupID string, revoke bool) (string, error) {
+	client, err := s.sessionService.ClientForUser(userID)
+	if err != nil {
+		return "", err
+	}
+
+	var code string
+	if revoke {
+		code, err = client.RevokeGroupInviteCode(ctx, groupID)
+	} else {
+		code, err = client.GetGroupInviteCode(ctx, groupID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(code, "http") {
+		return code, nil
+	}
+	return groupInviteBaseURL + code, nil
+}
+
+// participantIDs turns phone numbers like "+91 98765 43210" into WhatsApp
+// ids; values that already are ids are kept as-is.
+func participantIDs(participants []string) ([]string, error) {
+	ids := make([]string, 0, len(participants))
+	for _, p := range participants {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		if !strings.Contains(p, "@") {
+			p = utils.PhoneDigits(p) + "@c.us"
+		}
+		ids = append(ids, p)
+	}
+
+	if len(ids) == 0 {
+		return nil, ErrNoParticipants
+	}
+	return ids, nil
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParticipantIDs(t *testing.T) {
+	tests := []struct {
+		name         string
+		participants []string
+		want         []string
+		err          error
+	}{
+		{"phone number", []string{"+91 98765 43210"}, []string{"919876543210@c.us"}, nil},
+		{"dashes and brackets", []string{"(555) 222-3333"}, []string{"5552223333@c.us"}, nil},
+		{"ids are kept", []string{"15552223333@c.us", "120363000000000000@g.us"}, []string{"15552223333@c.us", "120363000000000000@g.us"}, nil},
+		{"blanks are skipped", []string{" ", "15552223333"}, []string{"15552223333@c.us"}, nil},
+		{"empty", nil, nil, ErrNoParticipants},
+		{"only blanks", []string{"", "  "}, nil, ErrNoParticipants},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := participantIDs(tt.participants)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("participantIDs(%q) = %q, want %q", tt.participants, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"time"
@@ -24,11 +25,11 @@ func NewHealthService(wc connections.WahaClient) *HealthService {
 	}
 }
 
-func (h *HealthService) GetHealth() (*views.HealthResponse, error) {
+func (h *HealthService) GetHealth(ctx context.Context) (*views.HealthResponse, error) {
 	var servicesList []views.Health
 
 	// 1. Check Waha Service
-	servicesList = append(servicesList, h.checkWahaService())
+	servicesList = append(servicesList, h.checkWahaService(ctx))
 
 	// 2. Check Database
 	servicesList = append(servicesList, h.checkDBService())
@@ -45,8 +46,8 @@ func (h *HealthService) GetHealth() (*views.HealthResponse, error) {
 	}, nil
 }
 
-func (h *HealthService) checkWahaService() views.Health {
-	err := h.wahaClient.Ping()
+func (h *HealthService) checkWahaService(ctx context.Context) views.Health {
+	err := h.wahaClient.Ping(ctx)
 	if err != nil {
 		return views.Health{
 			Name:    "waha-service",
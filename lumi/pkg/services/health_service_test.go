@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/db/dbtest"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections/wahatest"
+	"github.com/Mahaveer86619/lumi/pkg/views"
+)
+
+func TestGetHealth(t *testing.T) {
+	dbtest.Open(t)
+
+	srv := wahatest.NewServer()
+	defer srv.Close()
+
+	health := NewHealthService(srv.Client("default"))
+
+	tests := []struct {
+		name     string
+		pingFail int // status of a failed WAHA ping, 0 for none
+		wahaUp   bool
+	}{
+		{"all up", 0, true},
+		{"waha down", http.StatusServiceUnavailable, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.pingFail != 0 {
+				srv.FailNext("/ping", tt.pingFail)
+			}
+
+			resp, err := health.GetHealth(context.Background())
+			if err != nil {
+				t.Fatalf("GetHealth: %v", err)
+			}
+
+			want := map[string]bool{"waha-service": tt.wahaUp, "database": true, "lumi-service": true}
+			for name, up := range want {
+				service := findHealth(resp.Services, name)
+				if service == nil {
+					t.Errorf("%s is missing from %+v", name, resp.Services)
+				} else if service.IsUp != up {
+					t.Errorf("%s up = %v (%s), want %v", name, service.IsUp, service.Message, up)
+				}
+			}
+		})
+	}
+}
+
+func findHealth(services []views.Health, name string) *views.Health {
+	for i := range services {
+		if services[i].Name == name {
+			return &services[i]
+		}
+	}
+	return nil
+}
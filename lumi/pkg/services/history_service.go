@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+const (
+	historyPageSize     = 50
+	historyMaxMessages  = 200
+	historyWindow       = 48 * time.Hour
+	historySyncInterval = 15 * time.Minute
+)
+
+// HistoryService backfills ChatMessage from the messages WAHA already has,
+// so the bot gets the conversation that happened before it was woken up.
+type HistoryService struct {
+	sessionService *SessionService
+	chatService    *ChatService
+}
+
+func NewHistoryService(sessionService *SessionService, chatService *ChatService) *HistoryService {
+	return &HistoryService{
+		sessionService: sessionService,
+		chatService:    chatService,
+	}
+}
+
+// SyncChat imports the chat's messages of the last historyWindow, at most
+// historyMaxMessages of them, and returns how many were new. Messages from
+// before the chat's history was last cleared are left out.
+func (s *HistoryService) SyncChat(ctx context.Context, sessionName, chatID string) (int, error) {
+	chat, err := s.chatService.GetRegisteredChat(sessionName, chatID)
+	if err != nil {
+		return 0, err
+	}
+
+	client := s.sessionService.Client(sessionName)
+	since := time.Now().Add(-historyWindow).Unix()
+	if chat.HistoryClearedAt != nil && chat.HistoryClearedAt.Unix() > since {
+		since = chat.HistoryClearedAt.Unix()
+	}
+
+	imported := 0
+	for offset := 0; offset < historyMaxMessages; offset += historyPageSize {
+		page, err := client.GetChatMessages(ctx, chatID, connModel.ChatMessagesQuery{
+			Limit:  historyPageSize,
+			Offset: offset,
+			Since:  since,
+		})
+		if err != nil {
+			return imported, err
+		}
+
+		n, err := s.importPage(sessionName, chatID, since, page)
+		imported += n
+		if err != nil {
+			return imported, err
+		}
+
+		if len(page) < historyPageSize {
+			break
+		}
+	}
+	return imported, nil
+}
+
+// Start periodically syncs the registered chats of every connected session
+// until ctx is cancelled.
+func (s *HistoryService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(historySyncInterval)
+		defer ticker.Stop()
+
+		for {
+			s.syncAll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *HistoryService) syncAll(ctx context.Context) {
+	sessions, err := s.sessionService.ListWorkingSessions()
+	if err != nil {
+		log.Printf("History: failed to load sessions: %v", err)
+		return
+	}
+
+	for _, session := range sessions {
+		chats, err := s.chatService.GetRegisteredChats(session.WahaSessionName)
+		if err != nil {
+			log.Printf("History: failed to load registered chats of %s: %v", session.WahaSessionName, err)
+			continue
+		}
+
+		for _, chat := range chats {
+			if ctx.Err() != nil {
+				return
+			}
+
+			n, err := s.SyncChat(ctx, session.WahaSessionName, chat.ChatID)
+			if err != nil {
+				log.Printf("History: failed to sync %s on %s: %v", chat.ChatID, session.WahaSessionName, err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("History: imported %d messages for %s", n, chat.ChatID)
+			}
+		}
+	}
+}
+
+func (s *HistoryService) importPage(sessionName, chatID string, since int64, page []connModel.WAMessage) (int, error) {
+	botReplies, err := s.botReplyIDs(page)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for _, msg := range page {
+		text := strings.TrimSpace(msg.Body)
+		if text == "" || msg.ID == "" || msg.Timestamp <= since {
+			continue
+		}
+
+		role := "user"
+		if botReplies[msg.ID.String()] {
+			role = "model"
+		}
+
+		isNew, err := s.chatService.SaveWAMessage(sessionName, chatID, role, text, msg.ID.String(), time.Unix(msg.Timestamp, 0))
+		if err != nil {
+			return imported, err
+		}
+		if isNew {
+			imported++
+		}
+	}
+	return imported, nil
+}
+
+// botReplyIDs picks the messages of page that Lumi sent itself, recognised
+// by their id in the outbox.
+func (s *HistoryService) botReplyIDs(page []connModel.WAMessage) (map[string]bool, error) {
+	var ids []string
+	for _, msg := range page {
+		if msg.FromMe {
+			ids = append(ids, msg.ID.String())
+		}
+	}
+
+	replies := make(map[string]bool)
+	if len(ids) == 0 {
+		return replies, nil
+	}
+
+	var sent []string
+	err := db.DB.Model(&models.OutboxMessage{}).
+		Where("wa_message_id IN ? AND record_history = ?", ids, true).
+		Pluck("wa_message_id", &sent).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range sent {
+		replies[id] = true
+	}
+	return replies, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
+)
+
+func TestSyncChat(t *testing.T) {
+	_, chats, srv := newOutboxTest(t)
+	history := NewHistoryService(chats.SessionService, chats)
+	ctx := context.Background()
+
+	const friend, me = "15552223333@c.us", "15550001111@c.us"
+	if _, err := chats.RegisterChat("default", messenger.PlatformWhatsApp, friend, "Friend", "chat"); err != nil {
+		t.Fatalf("RegisterChat: %v", err)
+	}
+
+	// Lumi's own replies are recognised by their id in the outbox.
+	reply := models.OutboxMessage{SessionName: "default", ChatID: friend, Kind: "text", Payload: "{}", RecordHistory: true, Status: models.OutboxStatusSent, WAMessageID: "true_friend_reply"}
+	if err := db.DB.Create(&reply).Error; err != nil {
+		t.Fatalf("creating outbox message: %v", err)
+	}
+
+	now := time.Now()
+	srv.AddChatMessages(friend,
+		connModel.WAMessage{ID: "false_friend_old", From: friend, To: me, Body: "last week", Timestamp: now.Add(-7 * 24 * time.Hour).Unix()},
+		connModel.WAMessage{ID: "false_friend_question", From: friend, To: me, Body: "what's up?", Timestamp: now.Add(-time.Hour).Unix()},
+		connModel.WAMessage{ID: "true_friend_reply", From: me, To: friend, FromMe: true, Body: "not much", Timestamp: now.Add(-59 * time.Minute).Unix()},
+		connModel.WAMessage{ID: "true_friend_typed", From: me, To: friend, FromMe: true, Body: "typed by hand", Timestamp: now.Add(-58 * time.Minute).Unix()},
+		connModel.WAMessage{ID: "false_friend_sticker", From: friend, To: me, Timestamp: now.Add(-57 * time.Minute).Unix()},
+	)
+
+	imported, err := history.SyncChat(ctx, "default", friend)
+	if err != nil {
+		t.Fatalf("SyncChat: %v", err)
+	}
+	if imported != 3 {
+		t.Errorf("imported %d messages, want 3", imported)
+	}
+
+	stored, _ := chats.GetChatHistory("default", friend, 10)
+	want := []struct{ role, content string }{
+		{"user", "what's up?"},
+		{"model", "not much"},
+		{"user", "typed by hand"},
+	}
+	if len(stored) != len(want) {
+		t.Fatalf("history = %+v, want %d messages", stored, len(want))
+	}
+	for i, w := range want {
+		if stored[i].Role != w.role || stored[i].Content != w.content {
+			t.Errorf("message %d = %s %q, want %s %q", i, stored[i].Role, stored[i].Content, w.role, w.content)
+		}
+	}
+
+	// Syncing again imports nothing new, and a cleared history stays
+	// cleared apart from what was said afterwards.
+	if again, err := history.SyncChat(ctx, "default", friend); err != nil || again != 0 {
+		t.Errorf("second sync imported %d, %v; want 0", again, err)
+	}
+
+	if err := chats.ClearHistory("default", friend); err != nil {
+		t.Fatalf("ClearHistory: %v", err)
+	}
+	srv.AddChatMessages(friend, connModel.WAMessage{ID: "false_friend_later", From: friend, To: me, Body: "still there?", Timestamp: time.Now().Add(time.Second).Unix()})
+
+	if after, err := history.SyncChat(ctx, "default", friend); err != nil || after != 1 {
+		t.Errorf("sync after clearing imported %d, %v; want 1", after, err)
+	}
+
+	if _, err := history.SyncChat(ctx, "default", "15559990000@c.us"); err == nil {
+		t.Errorf("syncing an unregistered chat succeeded")
+	}
+}
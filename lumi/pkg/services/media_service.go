@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/storage"
+	"gorm.io/gorm"
+)
+
+var ErrMediaNotStorable = errors.New("media type is not stored")
+
+type MediaService struct {
+	storage        storage.Storage
+	sessionService *SessionService
+}
+
+func NewMediaService(store storage.Storage, sessionService *SessionService) *MediaService {
+	return &MediaService{
+		storage:        store,
+		sessionService: sessionService,
+	}
+}
+
+// StoreMessageMedia downloads the attachment of msg from WAHA and stores it.
+// A message that was already stored is returned as-is.
+func (s *MediaService) StoreMessageMedia(ctx context.Context, sessionName, chatID string, msg connModel.WAMessage) (*models.MediaFile, error) {
+	if msg.Media == nil || msg.Media.URL == "" {
+		return nil, fmt.Errorf("message %s has no downloadable media", msg.ID)
+	}
+
+	if !IsStorableMedia(msg.Media.Mimetype) {
+		return nil, ErrMediaNotStorable
+	}
+
+	var existing models.MediaFile
+	err := db.DB.Where("message_id = ?", msg.ID.String()).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	data, err := s.sessionService.Client(sessionName).DownloadMedia(ctx, *msg.Media)
+	if err != nil {
+		return nil, err
+	}
+
+	key := path.Join(sessionName, chatID, msg.ID.String()+mediaExtension(msg.Media))
+	size, err := s.storage.Save(ctx, key, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store media: %w", err)
+	}
+
+	file := models.MediaFile{
+		SessionName: sessionName,
+		ChatID:      chatID,
+		MessageID:   msg.ID.String(),
+		Mimetype:    msg.Media.Mimetype,
+		Filename:    msg.Media.Filename,
+		Size:        size,
+		StorageKey:  key,
+	}
+
+	if err := db.DB.Create(&file).Error; err != nil {
+		s.storage.Delete(ctx, key)
+		return nil, err
+	}
+	return &file, nil
+}
+
+// ListSessionMedia returns the media stored for a session, optionally
+// narrowed to a single chat.
+func (s *MediaService) ListSessionMedia(sessionName, chatID string) ([]models.MediaFile, error) {
+	query := db.DB.Where("session_name = ?", sessionName)
+	if chatID != "" {
+		query = query.Where("chat_id = ?", chatID)
+	}
+
+	var files []models.MediaFile
+	if err := query.Order("created_at desc").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (s *MediaService) GetSessionMedia(sessionName string, id uint) (*models.MediaFile, error) {
+	var file models.MediaFile
+	if err := db.DB.Where("id = ? AND session_name = ?", id, sessionName).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (s *MediaService) Open(ctx context.Context, file *models.MediaFile) (io.ReadCloser, error) {
+	return s.storage.Open(ctx, file.StorageKey)
+}
+
+// IsStorableMedia reports whether a mimetype is one we keep: images, audio
+// and documents. Videos and stickers are skipped.
+func IsStorableMedia(mimetype string) bool {
+	switch {
+	case strings.HasPrefix(mimetype, "image/"):
+		return mimetype != "image/webp" // stickers
+	case strings.HasPrefix(mimetype, "audio/"),
+		strings.HasPrefix(mimetype, "application/"),
+		strings.HasPrefix(mimetype, "text/"):
+		return true
+	}
+	return false
+}
+
+func mediaExtension(media *connModel.WAMedia) string {
+	if ext := path.Ext(media.Filename); ext != "" {
+		return ext
+	}
+
+	mimetype, _, _ := strings.Cut(media.Mimetype, ";")
+	if exts, err := mime.ExtensionsByType(mimetype); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
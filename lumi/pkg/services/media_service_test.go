@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/db/dbtest"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections/wahatest"
+	"github.com/Mahaveer86619/lumi/pkg/services/storage"
+)
+
+func TestStoreMessageMedia(t *testing.T) {
+	dbtest.Open(t, &models.MediaFile{})
+
+	srv := wahatest.NewServer()
+	defer srv.Close()
+	useConfig(t, &config.Config{WahaServiceURL: srv.URL, WahaAPIKey: srv.APIKey})
+
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	media := NewMediaService(store, NewSessionService())
+
+	const chatID = "15552223333@c.us"
+	png := srv.AddMedia("default", "photo.png", "image/png", []byte("\x89PNG"))
+	pdf := srv.AddMedia("default", "doc", "application/pdf", []byte("%PDF"))
+	// WAHA names files by its public URL; the path is fetched from the
+	// configured service instead.
+	public := png
+	public.URL = "https://waha.example.com/api/files/default/photo.png"
+	foreign := png
+	foreign.URL = "https://attacker.example/steal"
+	escaping := png
+	escaping.URL = srv.URL + "/api/files/../sessions"
+
+	tests := []struct {
+		name    string
+		media   *connModel.WAMedia
+		key     string // expected storage key when stored
+		data    string
+		wantErr error // nil for any error when key is ""
+	}{
+		{"image", &png, "default/" + chatID + "/m1.png", "\x89PNG", nil},
+		{"document without filename", &pdf, "default/" + chatID + "/m2.pdf", "%PDF", nil},
+		{"public WAHA url", &public, "default/" + chatID + "/m3.png", "\x89PNG", nil},
+		{"sticker", &connModel.WAMedia{URL: png.URL, Mimetype: "image/webp"}, "", "", ErrMediaNotStorable},
+		{"video", &connModel.WAMedia{URL: png.URL, Mimetype: "video/mp4"}, "", "", ErrMediaNotStorable},
+		{"foreign host", &foreign, "", "", connections.ErrInvalidMediaURL},
+		{"path escaping the file endpoint", &escaping, "", "", connections.ErrInvalidMediaURL},
+		{"no media", nil, "", "", nil},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := connModel.WAMessage{ID: connModel.WAMessageID(fmt.Sprintf("m%d", i+1)), HasMedia: true, Media: tt.media}
+
+			file, err := media.StoreMessageMedia(context.Background(), "default", chatID, msg)
+			if tt.key == "" {
+				if err == nil {
+					t.Fatalf("stored %+v, want an error", file)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StoreMessageMedia: %v", err)
+			}
+			if file.StorageKey != tt.key || file.Size != int64(len(tt.data)) {
+				t.Errorf("stored %+v, want key %s of %d bytes", file, tt.key, len(tt.data))
+			}
+
+			reader, err := media.Open(context.Background(), file)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer reader.Close()
+			if data, _ := io.ReadAll(reader); string(data) != tt.data {
+				t.Errorf("stored data = %q, want %q", data, tt.data)
+			}
+
+			again, err := media.StoreMessageMedia(context.Background(), "default", chatID, msg)
+			if err != nil || again.ID != file.ID {
+				t.Errorf("storing again = %+v, %v; want the first file back", again, err)
+			}
+		})
+	}
+
+	files, err := media.ListSessionMedia("default", chatID)
+	if err != nil || len(files) != 3 {
+		t.Errorf("ListSessionMedia = %d files, %v; want 3", len(files), err)
+	}
+	if files, _ := media.ListSessionMedia("other", ""); len(files) != 0 {
+		t.Errorf("another session sees %d files", len(files))
+	}
+}
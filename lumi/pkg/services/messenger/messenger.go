@@ -0,0 +1,67 @@
+// Package messenger hides the chat platform behind one interface, so Lumi
+// can hold the same conversations on WhatsApp and Telegram.
+//
+// It covers what the bot does in a conversation: reading messages, replying
+// and showing it is typing. Media, polls, the outbox and the remote chat
+// lists are WhatsApp-only features and keep using connections.WahaClient.
+package messenger
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	PlatformWhatsApp = "whatsapp"
+	PlatformTelegram = "telegram"
+)
+
+// IsPlatform reports whether name is a platform Lumi supports.
+func IsPlatform(name string) bool {
+	return name == PlatformWhatsApp || name == PlatformTelegram
+}
+
+// ErrInvalidID is returned for chat or message ids that can't belong to the
+// platform.
+var ErrInvalidID = errors.New("invalid id")
+
+type Chat struct {
+	ID      string
+	Name    string
+	IsGroup bool
+}
+
+// Message is a message on any platform. IDs are unique across all chats of
+// the platform, as WhatsApp's are.
+type Message struct {
+	ID       string
+	ChatID   string
+	From     string // sender id
+	Text     string // body or media caption
+	FromMe   bool
+	IsGroup  bool
+	HasMedia bool
+	SentAt   time.Time
+}
+
+// Messenger is one account on a platform, e.g. a WAHA session or a Telegram
+// bot, and what Lumi can do with it.
+type Messenger interface {
+	Platform() string
+
+	Chat(ctx context.Context, chatID string) (*Chat, error)
+
+	// SendText sends text to chatID, quoting the message replyTo unless it
+	// is empty.
+	SendText(ctx context.Context, chatID, replyTo, text string) (*Message, error)
+
+	// MarkRead marks messageID as read, on platforms with read receipts.
+	MarkRead(ctx context.Context, chatID, messageID string) error
+
+	// StartTyping shows "typing…" in chatID for TypingTTL, or until
+	// StopTyping or the next message sent there.
+	StartTyping(ctx context.Context, chatID string) error
+	StopTyping(ctx context.Context, chatID string) error
+	TypingTTL() time.Duration
+}
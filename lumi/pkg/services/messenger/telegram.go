@@ -0,0 +1,190 @@
+package messenger
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+)
+
+const (
+	pollTimeout    = 30 * time.Second
+	pollMinBackoff = time.Second
+	pollMaxBackoff = time.Minute
+)
+
+// Telegram is a Messenger over a Telegram bot. Chat ids are Telegram's
+// numeric ids; message ids are "<chat id>_<message id>", since Telegram
+// numbers messages per chat.
+type Telegram struct {
+	client connections.TelegramClient
+}
+
+func NewTelegram(client connections.TelegramClient) *Telegram {
+	return &Telegram{client: client}
+}
+
+func (t *Telegram) Platform() string {
+	return PlatformTelegram
+}
+
+func (t *Telegram) Chat(ctx context.Context, chatID string) (*Chat, error) {
+	id, err := parseTelegramChatID(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := t.client.GetChat(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &Chat{ID: chatID, Name: chat.Name(), IsGroup: chat.IsGroup()}, nil
+}
+
+func (t *Telegram) SendText(ctx context.Context, chatID, replyTo, text string) (*Message, error) {
+	id, err := parseTelegramChatID(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	req := connModel.TGSendMessageRequest{ChatID: id, Text: text}
+	if replyTo != "" {
+		_, messageID, err := parseTelegramMessageID(replyTo)
+		if err != nil {
+			return nil, err
+		}
+		// A deleted question shouldn't cost the answer.
+		req.ReplyParameters = &connModel.TGReplyParameters{MessageID: messageID, AllowSendingWithoutReply: true}
+	}
+
+	sent, err := t.client.SendMessage(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := FromTGMessage(*sent)
+	msg.FromMe = true
+	return &msg, nil
+}
+
+// MarkRead does nothing: bots have no read receipts.
+func (t *Telegram) MarkRead(ctx context.Context, chatID, messageID string) error {
+	return nil
+}
+
+func (t *Telegram) StartTyping(ctx context.Context, chatID string) error {
+	id, err := parseTelegramChatID(chatID)
+	if err != nil {
+		return err
+	}
+	return t.client.SendChatAction(ctx, id, "typing")
+}
+
+// StopTyping does nothing: Telegram has no call for it, and the typing
+// state ends with the next message anyway.
+func (t *Telegram) StopTyping(ctx context.Context, chatID string) error {
+	return nil
+}
+
+func (t *Telegram) TypingTTL() time.Duration {
+	return 5 * time.Second
+}
+
+// Listen long-polls the bot's updates and hands every message to handle
+// until ctx is cancelled. Failed polls are retried with exponential
+// backoff, or after the delay Telegram asks for.
+func (t *Telegram) Listen(ctx context.Context, handle func(ctx context.Context, msg Message)) {
+	if me, err := t.client.GetMe(ctx); err == nil {
+		log.Printf("Telegram: receiving messages for @%s", me.Username)
+	}
+
+	var offset int64
+	backoff := pollMinBackoff
+
+	for {
+		updates, err := t.client.GetUpdates(ctx, offset, pollTimeout)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			wait := backoff
+			if tgErr, ok := connections.AsTelegramError(err); ok && tgErr.RetryAfter > 0 {
+				wait = tgErr.RetryAfter
+			}
+			log.Printf("Telegram: polling updates failed (%v), retrying in %s", err, wait)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			backoff = min(backoff*2, pollMaxBackoff)
+			continue
+		}
+		backoff = pollMinBackoff
+
+		for _, update := range updates {
+			// Asking for offset update_id+1 confirms everything before.
+			offset = update.UpdateID + 1
+			if update.Message != nil {
+				handle(ctx, FromTGMessage(*update.Message))
+			}
+		}
+	}
+}
+
+// FromTGMessage converts a Telegram message.
+func FromTGMessage(msg connModel.TGMessage) Message {
+	chatID := strconv.FormatInt(msg.Chat.ID, 10)
+
+	text := msg.Text
+	if text == "" {
+		text = msg.Caption
+	}
+
+	from := chatID
+	if msg.From != nil {
+		from = strconv.FormatInt(msg.From.ID, 10)
+	}
+
+	return Message{
+		ID:       fmt.Sprintf("%s_%d", chatID, msg.MessageID),
+		ChatID:   chatID,
+		From:     from,
+		Text:     text,
+		IsGroup:  msg.Chat.IsGroup(),
+		HasMedia: msg.HasMedia(),
+		SentAt:   time.Unix(msg.Date, 0),
+	}
+}
+
+func parseTelegramChatID(chatID string) (int64, error) {
+	id, err := strconv.ParseInt(chatID, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: telegram chat id %q", ErrInvalidID, chatID)
+	}
+	return id, nil
+}
+
+func parseTelegramMessageID(messageID string) (int64, int, error) {
+	i := strings.LastIndex(messageID, "_")
+	if i < 0 {
+		return 0, 0, fmt.Errorf("%w: telegram message id %q", ErrInvalidID, messageID)
+	}
+
+	chatID, err := parseTelegramChatID(messageID[:i])
+	if err != nil {
+		return 0, 0, err
+	}
+	id, err := strconv.Atoi(messageID[i+1:])
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: telegram message id %q", ErrInvalidID, messageID)
+	}
+	return chatID, id, nil
+}
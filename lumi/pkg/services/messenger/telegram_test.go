@@ -0,0 +1,165 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	models "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections/telegramtest"
+)
+
+var alice = models.TGUser{ID: 42, FirstName: "Alice"}
+
+func TestTelegramChat(t *testing.T) {
+	srv := newTelegramServer(t)
+	srv.AddChat(models.TGChat{ID: -100, Type: models.TGChatSupergroup, Title: "Book club"})
+
+	tg := NewTelegram(srv.Client())
+
+	chat, err := tg.Chat(context.Background(), "-100")
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if chat.Name != "Book club" || !chat.IsGroup {
+		t.Errorf("Chat = %+v, want the group \"Book club\"", chat)
+	}
+
+	if _, err := tg.Chat(context.Background(), "123@c.us"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Chat with a WhatsApp id: err = %v, want ErrInvalidID", err)
+	}
+}
+
+func TestTelegramSendText(t *testing.T) {
+	srv := newTelegramServer(t)
+	srv.Receive(42, alice, "hi")
+
+	tg := NewTelegram(srv.Client())
+
+	sent, err := tg.SendText(context.Background(), "42", "", "hello")
+	if err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	if sent.ID != "42_2" || sent.ChatID != "42" || sent.Text != "hello" || !sent.FromMe {
+		t.Errorf("SendText = %+v, want message 42_2 from Lumi", sent)
+	}
+
+	got := srv.SentTo(42)
+	if len(got) != 1 || got[0].Text != "hello" || got[0].ReplyToMessage != nil {
+		t.Errorf("sent %+v, want one message without a reply", got)
+	}
+}
+
+func TestTelegramSendTextReply(t *testing.T) {
+	srv := newTelegramServer(t)
+	question := FromTGMessage(*srv.Receive(42, alice, "what's up?").Message)
+
+	tg := NewTelegram(srv.Client())
+
+	if _, err := tg.SendText(context.Background(), "42", question.ID, "not much"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+
+	got := srv.SentTo(42)
+	if len(got) != 1 || got[0].ReplyToMessage == nil {
+		t.Fatalf("sent %+v, want one reply", got)
+	}
+	if got[0].ReplyToMessage.MessageID != 1 {
+		t.Errorf("replied to message %d, want 1 (%s)", got[0].ReplyToMessage.MessageID, question.ID)
+	}
+
+	if _, err := tg.SendText(context.Background(), "42", "false_123@c.us_ABC", "x"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("reply to a WhatsApp id: err = %v, want ErrInvalidID", err)
+	}
+}
+
+func TestTelegramListen(t *testing.T) {
+	srv := newTelegramServer(t)
+	srv.AddChat(models.TGChat{ID: -100, Type: models.TGChatGroup, Title: "Book club"})
+
+	tg := NewTelegram(srv.Client())
+	received := listen(t, tg)
+
+	srv.Receive(42, alice, "first")
+	srv.Receive(-100, alice, "second")
+
+	first, second := next(t, received), next(t, received)
+	if first.ID != "42_1" || first.ChatID != "42" || first.From != "42" || first.Text != "first" || first.IsGroup {
+		t.Errorf("first message = %+v", first)
+	}
+	if second.ChatID != "-100" || second.From != "42" || second.Text != "second" || !second.IsGroup {
+		t.Errorf("second message = %+v", second)
+	}
+
+	// The next poll confirms what was handled.
+	waitFor(t, func() bool { return srv.Pending() == 0 })
+}
+
+func TestTelegramListenRetriesFailedPolls(t *testing.T) {
+	srv := newTelegramServer(t)
+	srv.FailNext("getUpdates", http.StatusTooManyRequests)
+	srv.Receive(42, alice, "still there?")
+
+	tg := NewTelegram(srv.Client())
+	received := listen(t, tg)
+
+	if msg := next(t, received); msg.Text != "still there?" {
+		t.Errorf("received %+v after the retry", msg)
+	}
+}
+
+// newTelegramServer starts a fake Bot API that is closed after the test's
+// listeners have stopped.
+func newTelegramServer(t *testing.T) *telegramtest.Server {
+	srv := telegramtest.NewServer()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// listen runs tg.Listen until the test ends and returns what it receives.
+func listen(t *testing.T, tg *Telegram) <-chan Message {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	received := make(chan Message, 10)
+
+	go func() {
+		defer close(done)
+		tg.Listen(ctx, func(ctx context.Context, msg Message) {
+			received <- msg
+		})
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return received
+}
+
+func next(t *testing.T, received <-chan Message) Message {
+	t.Helper()
+
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return Message{}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
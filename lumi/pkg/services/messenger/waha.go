@@ -0,0 +1,108 @@
+package messenger
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+)
+
+// Waha is a Messenger over one WAHA session.
+type Waha struct {
+	client connections.WahaClient
+}
+
+func NewWaha(client connections.WahaClient) *Waha {
+	return &Waha{client: client}
+}
+
+func (w *Waha) Platform() string {
+	return PlatformWhatsApp
+}
+
+func (w *Waha) Chat(ctx context.Context, chatID string) (*Chat, error) {
+	if IsWhatsAppGroup(chatID) {
+		group, err := w.client.GetGroup(ctx, chatID)
+		if err != nil {
+			return nil, err
+		}
+		return &Chat{ID: group.ID, Name: group.Subject, IsGroup: true}, nil
+	}
+
+	contact, err := w.client.GetContact(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	name := contact.Name
+	if name == "" {
+		name = contact.PushName
+	}
+	return &Chat{ID: contact.ID, Name: name}, nil
+}
+
+func (w *Waha) SendText(ctx context.Context, chatID, replyTo, text string) (*Message, error) {
+	var sent *connModel.WAMessage
+	var err error
+	if replyTo != "" {
+		sent, err = w.client.ReplyText(ctx, chatID, replyTo, text)
+	} else {
+		sent, err = w.client.SendText(ctx, chatID, text)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	msg := FromWAMessage(*sent)
+	if msg.ChatID == "" {
+		msg.ChatID = chatID
+	}
+	return &msg, nil
+}
+
+func (w *Waha) MarkRead(ctx context.Context, chatID, messageID string) error {
+	return w.client.SendSeen(ctx, chatID, messageID)
+}
+
+func (w *Waha) StartTyping(ctx context.Context, chatID string) error {
+	return w.client.StartTyping(ctx, chatID)
+}
+
+func (w *Waha) StopTyping(ctx context.Context, chatID string) error {
+	return w.client.StopTyping(ctx, chatID)
+}
+
+// TypingTTL is how long WhatsApp keeps a typing state, roughly.
+func (w *Waha) TypingTTL() time.Duration {
+	return 25 * time.Second
+}
+
+// FromWAMessage converts a WAHA message. Its chat is the other side of the
+// conversation, which for messages sent from the phone is the recipient.
+func FromWAMessage(msg connModel.WAMessage) Message {
+	chatID := msg.From
+	if msg.FromMe {
+		chatID = msg.To
+	}
+
+	sentAt := time.Now()
+	if msg.Timestamp > 0 {
+		sentAt = time.Unix(msg.Timestamp, 0)
+	}
+
+	return Message{
+		ID:       msg.ID.String(),
+		ChatID:   chatID,
+		From:     msg.From,
+		Text:     msg.Body,
+		FromMe:   msg.FromMe,
+		IsGroup:  IsWhatsAppGroup(chatID),
+		HasMedia: msg.HasMedia,
+		SentAt:   sentAt,
+	}
+}
+
+func IsWhatsAppGroup(chatID string) bool {
+	return strings.HasSuffix(chatID, "@g.us")
+}
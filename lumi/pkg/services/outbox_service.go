@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"gorm.io/gorm"
+)
+
+const (
+	OutboxKindText     = "text"
+	OutboxKindImage    = "image"
+	OutboxKindVideo    = "video"
+	OutboxKindVoice    = "voice"
+	OutboxKindFile     = "file"
+	OutboxKindLocation = "location"
+	OutboxKindContact  = "contact"
+	OutboxKindPoll     = "poll"
+
+	outboxMaxAttempts  = 8
+	outboxBaseBackoff  = 5 * time.Second
+	outboxMaxBackoff   = 10 * time.Minute
+	outboxPollInterval = 2 * time.Second
+	outboxBatchSize    = 20
+)
+
+var ErrOutboxNotRetryable = errors.New("only failed or dead messages can be retried")
+
+type OutboxService struct {
+	sessionService *SessionService
+	chatService    *ChatService
+	wake           chan struct{}
+}
+
+func NewOutboxService(sessionService *SessionService, chatService *ChatService) *OutboxService {
+	return &OutboxService{
+		sessionService: sessionService,
+		chatService:    chatService,
+		wake:           make(chan struct{}, 1),
+	}
+}
+
+// EnqueueText queues a text message, optionally quoting replyTo. With
+// recordHistory the text is saved as a "model" message once delivered.
+func (s *OutboxService) EnqueueText(sessionName, chatID, replyTo, text string, recordHistory bool) (*models.OutboxMessage, error) {
+	return s.enqueue(sessionName, chatID, OutboxKindText, text, textRequest(sessionName, chatID, replyTo, text), recordHistory)
+}
+
+func textRequest(sessionName, chatID, replyTo, text string) connModel.MessageTextRequest {
+	return connModel.MessageTextRequest{
+		ChatID:  chatID,
+		Text:    text,
+		Session: sessionName,
+		ReplyTo: replyTo,
+	}
+}
+
+// Enqueue queues one of the connections send requests (MessageImageRequest,
+// MessageVideoRequest, ...) under the given kind.
+func (s *OutboxService) Enqueue(sessionName, chatID, kind, text string, payload any) (*models.OutboxMessage, error) {
+	return s.enqueue(sessionName, chatID, kind, text, payload, false)
+}
+
+func (s *OutboxService) enqueue(sessionName, chatID, kind, text string, payload any, recordHistory bool) (*models.OutboxMessage, error) {
+	item, err := s.enqueueIn(db.DB, sessionName, chatID, kind, text, payload, recordHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify()
+	return item, nil
+}
+
+// enqueueIn queues a message as part of the transaction tx. The caller
+// wakes the worker once tx is committed.
+func (s *OutboxService) enqueueIn(tx *gorm.DB, sessionName, chatID, kind, text string, payload any, recordHistory bool) (*models.OutboxMessage, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	item := models.OutboxMessage{
+		SessionName:   sessionName,
+		ChatID:        chatID,
+		Kind:          kind,
+		Text:          text,
+		Payload:       string(raw),
+		RecordHistory: recordHistory,
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+
+	if err := tx.Create(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *OutboxService) ListSessionOutbox(sessionName, status string) ([]models.OutboxMessage, error) {
+	query := db.DB.Where("session_name = ?", sessionName)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var items []models.OutboxMessage
+	if err := query.Order("created_at desc").Limit(200).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Retry puts a failed or dead message back into the queue with a fresh set
+// of attempts.
+func (s *OutboxService) Retry(sessionName string, id uint) (*models.OutboxMessage, error) {
+	var item models.OutboxMessage
+	if err := db.DB.Where("id = ? AND session_name = ?", id, sessionName).First(&item).Error; err != nil {
+		return nil, err
+	}
+
+	if item.Status != models.OutboxStatusFailed && item.Status != models.OutboxStatusDead {
+		return nil, ErrOutboxNotRetryable
+	}
+
+	item.Status = models.OutboxStatusPending
+	item.Attempts = 0
+	item.NextAttemptAt = time.Now()
+	if err := db.DB.Save(&item).Error; err != nil {
+		return nil, err
+	}
+
+	s.notify()
+	return &item, nil
+}
+
+// Start runs the delivery worker until ctx is cancelled.
+func (s *OutboxService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(outboxPollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.processDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+func (s *OutboxService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// processDue delivers the due messages in the order they were queued. A
+// message waits while an earlier one to the same chat is waiting for a
+// retry, so a chat never gets its messages out of order; only giving up on
+// a message (dead) lets the ones after it through.
+func (s *OutboxService) processDue(ctx context.Context) {
+	now := time.Now()
+	retrying := []string{models.OutboxStatusPending, models.OutboxStatusFailed}
+
+	var items []models.OutboxMessage
+	err := db.DB.
+		Where("status IN ? AND next_attempt_at <= ?", retrying, now).
+		Where(`NOT EXISTS (SELECT 1 FROM outbox_messages earlier
+			WHERE earlier.session_name = outbox_messages.session_name AND earlier.chat_id = outbox_messages.chat_id
+			AND earlier.id < outbox_messages.id AND earlier.status IN ? AND earlier.next_attempt_at > ? AND earlier.deleted_at IS NULL)`, retrying, now).
+		Order("id asc").
+		Limit(outboxBatchSize).
+		Find(&items).Error
+	if err != nil {
+		log.Printf("Outbox: failed to load due messages: %v", err)
+		return
+	}
+
+	// Once a send fails for a retry, the rest of that session's messages
+	// wait for the next pass instead of each running into the same outage.
+	failedSessions := make(map[string]bool)
+	for i := range items {
+		if ctx.Err() != nil {
+			return
+		}
+
+		item := &items[i]
+		if failedSessions[item.SessionName] {
+			continue
+		}
+		if !s.deliver(ctx, item) && item.Status == models.OutboxStatusFailed {
+			failedSessions[item.SessionName] = true
+		}
+	}
+}
+
+// deliver sends item and records the outcome; it reports whether the
+// message went out.
+func (s *OutboxService) deliver(ctx context.Context, item *models.OutboxMessage) bool {
+	client := s.sessionService.Client(item.SessionName)
+	sent, err := dispatchOutbox(ctx, client, item)
+
+	// Shutting down is not the message's fault; leave it for the next run.
+	if ctx.Err() != nil {
+		return false
+	}
+
+	item.Attempts++
+	now := time.Now()
+
+	if err != nil {
+		item.LastError = err.Error()
+		switch {
+		case !outboxRetryable(err):
+			item.Status = models.OutboxStatusDead
+			log.Printf("Outbox: message %d to %s was rejected: %v", item.ID, item.ChatID, err)
+		case item.Attempts >= outboxMaxAttempts:
+			item.Status = models.OutboxStatusDead
+			log.Printf("Outbox: giving up on message %d to %s after %d attempts: %v", item.ID, item.ChatID, item.Attempts, err)
+		default:
+			item.Status = models.OutboxStatusFailed
+			item.NextAttemptAt = now.Add(outboxBackoff(item.Attempts))
+		}
+	} else {
+		item.Status = models.OutboxStatusSent
+		item.LastError = ""
+		item.SentAt = &now
+		if sent != nil {
+			item.WAMessageID = sent.ID.String()
+		}
+	}
+
+	if err := db.DB.Save(item).Error; err != nil {
+		log.Printf("Outbox: failed to update message %d: %v", item.ID, err)
+	}
+
+	if item.Status == models.OutboxStatusSent && item.RecordHistory {
+		if _, err := s.chatService.SaveWAMessage(item.SessionName, item.ChatID, "model", item.Text, item.WAMessageID, now); err != nil {
+			log.Printf("Outbox: failed to record history for %s: %v", item.ChatID, err)
+		}
+	}
+	return item.Status == models.OutboxStatusSent
+}
+
+// outboxRetryable reports whether a failed send may go through later:
+// WAHA was unreachable or answered 5xx, or the session was not connected.
+// Anything else, e.g. a 4xx for a chat that does not exist, fails the same
+// way every time.
+func outboxRetryable(err error) bool {
+	wahaErr, ok := connections.AsWahaError(err)
+	return ok && (wahaErr.Code == connections.WahaErrUnavailable || wahaErr.Code == connections.WahaErrSessionState)
+}
+
+func dispatchOutbox(ctx context.Context, client connections.WahaClient, item *models.OutboxMessage) (*connModel.WAMessage, error) {
+	payload := []byte(item.Payload)
+
+	switch item.Kind {
+	case OutboxKindText:
+		var req connModel.MessageTextRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return client.ReplyText(ctx, req.ChatID, req.ReplyTo, req.Text)
+
+	case OutboxKindImage:
+		var req connModel.MessageImageRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return client.SendImage(ctx, req.ChatID, connModel.ImagePayload{Caption: req.Caption, File: req.File, ReplyTo: req.ReplyTo})
+
+	case OutboxKindVideo:
+		var req connModel.MessageVideoRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return client.SendVideo(ctx, req.ChatID, connModel.VideoPayload{Caption: req.Caption, File: req.File, AsNote: req.AsNote, Convert: req.Convert, ReplyTo: req.ReplyTo})
+
+	case OutboxKindVoice:
+		var req connModel.MessageVoiceRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return client.SendVoice(ctx, req.ChatID, connModel.VoicePayload{File: req.File, Convert: req.Convert, ReplyTo: req.ReplyTo})
+
+	case OutboxKindFile:
+		var req connModel.MessageFileRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return client.SendFile(ctx, req.ChatID, connModel.FilePayload{Caption: req.Caption, File: req.File, ReplyTo: req.ReplyTo})
+
+	case OutboxKindLocation:
+		var req connModel.MessageLocationRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return client.SendLocation(ctx, req.ChatID, connModel.LocationPayload{Latitude: req.Latitude, Longitude: req.Longitude, Title: req.Title, ReplyTo: req.ReplyTo})
+
+	case OutboxKindContact:
+		var req connModel.MessageContactVcardRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return client.SendContactVcard(ctx, req.ChatID, req.Contacts)
+
+	case OutboxKindPoll:
+		var req connModel.MessagePollRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return client.SendPoll(ctx, req.ChatID, connModel.PollPayload{Poll: req.Poll, ReplyTo: req.ReplyTo})
+	}
+
+	return nil, fmt.Errorf("unknown outbox message kind %q", item.Kind)
+}
+
+// outboxBackoff doubles the delay with every attempt, capped at
+// outboxMaxBackoff.
+func outboxBackoff(attempts int) time.Duration {
+	backoff := time.Duration(float64(outboxBaseBackoff) * math.Pow(2, float64(attempts-1)))
+	if backoff > outboxMaxBackoff || backoff <= 0 {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/db/dbtest"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections/wahatest"
+)
+
+// newOutboxTest returns an outbox sending through the paired wahatest
+// session "default". Tests drive it with processDue instead of Start.
+func newOutboxTest(t *testing.T) (*OutboxService, *ChatService, *wahatest.Server) {
+	t.Helper()
+
+	dbtest.Open(t, &models.RegisteredChat{}, &models.ChatMessage{}, &models.OutboxMessage{})
+
+	srv := wahatest.NewServer()
+	t.Cleanup(srv.Close)
+	useConfig(t, &config.Config{WahaServiceURL: srv.URL, WahaAPIKey: srv.APIKey})
+
+	sessions := NewSessionService()
+	if err := sessions.Client("default").StartSession(context.Background()); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+	if err := srv.Pair("default", connModel.MeInfo{ID: "15550001111@c.us"}); err != nil {
+		t.Fatalf("Pair: %v", err)
+	}
+
+	chats := NewChatService(sessions)
+	return NewOutboxService(sessions, chats), chats, srv
+}
+
+func outboxItem(t *testing.T, id uint) models.OutboxMessage {
+	t.Helper()
+
+	var item models.OutboxMessage
+	if err := db.DB.First(&item, id).Error; err != nil {
+		t.Fatalf("loading outbox message %d: %v", id, err)
+	}
+	return item
+}
+
+// makeDue moves a retry of the message to now.
+func makeDue(t *testing.T, id uint) {
+	t.Helper()
+
+	if err := db.DB.Model(&models.OutboxMessage{}).Where("id = ?", id).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatalf("making message %d due: %v", id, err)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{4, 40 * time.Second},
+		{7, 320 * time.Second},
+		{8, outboxMaxBackoff},
+		{60, outboxMaxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempts); got != tt.want {
+			t.Errorf("outboxBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestOutboxKeepsChatOrder(t *testing.T) {
+	outbox, _, srv := newOutboxTest(t)
+	ctx := context.Background()
+	const alice, bob = "15552223333@c.us", "15554445555@c.us"
+
+	first, _ := outbox.EnqueueText("default", alice, "", "first", false)
+	second, _ := outbox.EnqueueText("default", alice, "", "second", false)
+	other, _ := outbox.EnqueueText("default", bob, "", "other", false)
+
+	steps := []struct {
+		name   string
+		before func()
+		want   map[uint]string // status per message after the pass
+	}{
+		{
+			// The failure stops the pass for the whole session.
+			"WAHA fails", func() { srv.FailNext("/api/sendText", http.StatusBadGateway) },
+			map[uint]string{first.ID: models.OutboxStatusFailed, second.ID: models.OutboxStatusPending, other.ID: models.OutboxStatusPending},
+		},
+		{
+			// second waits behind the retry of first; bob's chat is free.
+			"first backing off", func() {},
+			map[uint]string{first.ID: models.OutboxStatusFailed, second.ID: models.OutboxStatusPending, other.ID: models.OutboxStatusSent},
+		},
+		{
+			"first due again", func() { makeDue(t, first.ID) },
+			map[uint]string{first.ID: models.OutboxStatusSent, second.ID: models.OutboxStatusSent, other.ID: models.OutboxStatusSent},
+		},
+	}
+
+	for _, step := range steps {
+		step.before()
+		outbox.processDue(ctx)
+
+		for id, status := range step.want {
+			if got := outboxItem(t, id); got.Status != status {
+				t.Errorf("%s: message %d (%s) is %s, want %s", step.name, id, got.Text, got.Status, status)
+			}
+		}
+	}
+
+	sent := srv.SentTo(alice)
+	if len(sent) != 2 || sent[0].Text != "first" || sent[1].Text != "second" {
+		t.Errorf("sent to alice = %+v, want first then second", sent)
+	}
+	if item := outboxItem(t, first.ID); item.Attempts != 2 || item.WAMessageID != sent[0].ID || item.SentAt == nil || item.LastError != "" {
+		t.Errorf("first = %+v, want it sent on its second attempt", item)
+	}
+}
+
+func TestOutboxGivesUp(t *testing.T) {
+	outbox, _, srv := newOutboxTest(t)
+	ctx := context.Background()
+	const chatID = "15552223333@c.us"
+
+	doomed, _ := outbox.EnqueueText("default", chatID, "", "doomed", false)
+	next, _ := outbox.EnqueueText("default", chatID, "", "next", false)
+	db.DB.Model(doomed).Update("attempts", outboxMaxAttempts-1)
+
+	srv.FailNext("/api/sendText", http.StatusInternalServerError)
+	outbox.processDue(ctx)
+
+	if item := outboxItem(t, doomed.ID); item.Status != models.OutboxStatusDead || item.LastError == "" {
+		t.Errorf("doomed = %+v, want it dead with its error", item)
+	}
+
+	// A dead message no longer holds up the chat.
+	outbox.processDue(ctx)
+	if item := outboxItem(t, next.ID); item.Status != models.OutboxStatusSent {
+		t.Errorf("next is %s, want sent", item.Status)
+	}
+}
+
+func TestOutboxRetriesOnlyTransientFailures(t *testing.T) {
+	outbox, _, srv := newOutboxTest(t)
+	ctx := context.Background()
+	const chatID = "15552223333@c.us"
+
+	// A session that is started but not paired answers with its state.
+	if err := outbox.sessionService.Client("unpaired").StartSession(ctx); err != nil {
+		t.Fatalf("StartSession: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		session string
+		fail    int // status WAHA answers sendText with, 0 for none
+		want    string
+	}{
+		{"bad request", "default", http.StatusBadRequest, models.OutboxStatusDead},
+		{"unknown chat", "default", http.StatusNotFound, models.OutboxStatusDead},
+		{"unprocessable", "default", http.StatusUnprocessableEntity, models.OutboxStatusDead},
+		{"WAHA failing", "default", http.StatusInternalServerError, models.OutboxStatusFailed},
+		{"WAHA unavailable", "default", http.StatusServiceUnavailable, models.OutboxStatusFailed},
+		{"session not connected", "unpaired", 0, models.OutboxStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := outbox.EnqueueText(tt.session, chatID, "", tt.name, false)
+			if err != nil {
+				t.Fatalf("EnqueueText: %v", err)
+			}
+			if tt.fail != 0 {
+				srv.FailNext("/api/sendText", tt.fail)
+			}
+
+			if outbox.deliver(ctx, item) {
+				t.Fatalf("delivered despite the failure")
+			}
+			if got := outboxItem(t, item.ID); got.Status != tt.want || got.Attempts != 1 || got.LastError == "" {
+				t.Errorf("message is %s after %d attempts (%q), want %s after 1", got.Status, got.Attempts, got.LastError, tt.want)
+			}
+
+			// Don't let the message hold up the next case.
+			db.DB.Model(item).Update("status", models.OutboxStatusDead)
+		})
+	}
+
+	// A rejected message doesn't hold up the rest of the session.
+	rejected, _ := outbox.EnqueueText("default", chatID, "", "rejected", false)
+	next, _ := outbox.EnqueueText("default", "15554445555@c.us", "", "next", false)
+	srv.FailNext("/api/sendText", http.StatusBadRequest)
+	outbox.processDue(ctx)
+	if got := outboxItem(t, rejected.ID); got.Status != models.OutboxStatusDead {
+		t.Errorf("rejected is %s, want dead", got.Status)
+	}
+	if got := outboxItem(t, next.ID); got.Status != models.OutboxStatusSent {
+		t.Errorf("next is %s, want sent in the same pass", got.Status)
+	}
+}
+
+func TestOutboxRecordsHistory(t *testing.T) {
+	outbox, chats, _ := newOutboxTest(t)
+	const chatID = "15552223333@c.us"
+
+	tests := []struct {
+		text    string
+		record  bool
+		history int
+	}{
+		{"a notice", false, 0},
+		{"an answer", true, 1},
+	}
+
+	for _, tt := range tests {
+		if _, err := outbox.EnqueueText("default", chatID, "", tt.text, tt.record); err != nil {
+			t.Fatalf("EnqueueText: %v", err)
+		}
+		outbox.processDue(context.Background())
+
+		history, _ := chats.GetChatHistory("default", chatID, 10)
+		if len(history) != tt.history {
+			t.Errorf("after %q: %d history messages, want %d", tt.text, len(history), tt.history)
+		}
+	}
+}
+
+func TestOutboxRetry(t *testing.T) {
+	outbox, _, _ := newOutboxTest(t)
+
+	tests := []struct {
+		status  string
+		session string // session asking for the retry
+		wantErr error  // nil for success, ErrOutboxNotRetryable or any error
+		fails   bool
+	}{
+		{models.OutboxStatusFailed, "default", nil, false},
+		{models.OutboxStatusDead, "default", nil, false},
+		{models.OutboxStatusPending, "default", ErrOutboxNotRetryable, true},
+		{models.OutboxStatusSent, "default", ErrOutboxNotRetryable, true},
+		{models.OutboxStatusDead, "other", nil, true},
+	}
+
+	for _, tt := range tests {
+		item, _ := outbox.EnqueueText("default", "15552223333@c.us", "", "hi", false)
+		db.DB.Model(item).Updates(map[string]any{"status": tt.status, "attempts": 3, "next_attempt_at": time.Now().Add(time.Hour)})
+
+		retried, err := outbox.Retry(tt.session, item.ID)
+		if tt.fails {
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("retrying a %s message from %s: err = %v, want %v", tt.status, tt.session, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("retrying a %s message: %v", tt.status, err)
+		}
+		if retried.Status != models.OutboxStatusPending || retried.Attempts != 0 || retried.NextAttemptAt.After(time.Now()) {
+			t.Errorf("retried %s message = %+v, want it pending and due now", tt.status, retried)
+		}
+	}
+}
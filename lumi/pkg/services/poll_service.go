@@ -0,0 +1,315 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WhatsApp accepts between 2 and 12 options per poll.
+const (
+	pollMinOptions = 2
+	pollMaxOptions = 12
+
+	// How long a vote for a poll Lumi doesn't know is kept in case the
+	// outbox has yet to record the poll's WhatsApp id. Votes on polls
+	// Lumi didn't send are dropped after that.
+	pendingVoteTTL = 24 * time.Hour
+)
+
+var (
+	ErrInvalidPoll  = fmt.Errorf("a poll needs a question and %d to %d distinct options", pollMinOptions, pollMaxOptions)
+	ErrPollNotFound = errors.New("poll not found")
+)
+
+type PollService struct {
+	outboxService *OutboxService
+}
+
+func NewPollService(outboxService *OutboxService) *PollService {
+	return &PollService{outboxService: outboxService}
+}
+
+// CreatePoll stores a poll and queues it for delivery to chatID. Both rows
+// are written in one transaction, so a vote can never arrive for a poll
+// message whose poll is missing.
+func (s *PollService) CreatePoll(sessionName, chatID, question string, options []string, multipleAnswers bool) (*models.Poll, *models.OutboxMessage, error) {
+	question = strings.TrimSpace(question)
+	var cleaned []string
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" || slices.Contains(cleaned, option) {
+			return nil, nil, ErrInvalidPoll
+		}
+		cleaned = append(cleaned, option)
+	}
+	if question == "" || len(cleaned) < pollMinOptions || len(cleaned) > pollMaxOptions {
+		return nil, nil, ErrInvalidPoll
+	}
+
+	payload := connModel.MessagePollRequest{
+		ChatID:  chatID,
+		Session: sessionName,
+		Poll: connModel.PollOptions{
+			Name:            question,
+			Options:         cleaned,
+			MultipleAnswers: multipleAnswers,
+		},
+	}
+
+	poll := models.Poll{
+		SessionName:     sessionName,
+		ChatID:          chatID,
+		Question:        question,
+		Options:         cleaned,
+		MultipleAnswers: multipleAnswers,
+	}
+	var item *models.OutboxMessage
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&poll).Error; err != nil {
+			return err
+		}
+
+		var err error
+		item, err = s.outboxService.enqueueIn(tx, sessionName, chatID, OutboxKindPoll, question, payload, false)
+		if err != nil {
+			return err
+		}
+
+		poll.OutboxMessageID = item.ID
+		return tx.Model(&poll).Update("outbox_message_id", item.ID).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.outboxService.notify()
+	return &poll, item, nil
+}
+
+// RecordVote stores a poll.vote event received on the given session,
+// replacing the voter's earlier vote. A vote can beat the outbox to
+// recording the poll's WhatsApp id; it is then kept until the poll is
+// known, see reconcileVotes, as long as the chat has a poll still waiting
+// for its id. Votes on polls Lumi did not send are ignored.
+func (s *PollService) RecordVote(sessionName string, payload connModel.PollVotePayload) error {
+	// In groups "from" is the group and the voter is the participant.
+	voter := payload.Vote.Participant
+	if voter == "" {
+		voter = payload.Vote.From
+	}
+	if payload.Vote.FromMe {
+		voter = "me"
+	}
+
+	votedAt := time.Now()
+	if payload.Vote.Timestamp > 0 {
+		votedAt = time.Unix(payload.Vote.Timestamp, 0)
+	}
+
+	pollMessageID := payload.Poll.ID.String()
+	poll, err := s.findByMessageID(sessionName, pollMessageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		chatID := payload.Poll.ID.ChatID()
+		outstanding, err := s.hasOutstandingPoll(sessionName, chatID)
+		if err != nil || !outstanding {
+			return err
+		}
+
+		pending := models.PendingPollVote{
+			SessionName:     sessionName,
+			PollMessageID:   pollMessageID,
+			ChatID:          chatID,
+			Voter:           voter,
+			SelectedOptions: payload.Vote.SelectedOptions,
+			VotedAt:         votedAt,
+		}
+		return db.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "session_name"}, {Name: "poll_message_id"}, {Name: "voter"}},
+			DoUpdates: clause.AssignmentColumns([]string{"selected_options", "voted_at", "updated_at"}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "pending_poll_votes.voted_at <= excluded.voted_at"}}},
+		}).Create(&pending).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	return saveVote(db.DB, poll.ID, voter, payload.Vote.SelectedOptions, votedAt)
+}
+
+// saveVote stores a voter's choice unless a later one is already stored;
+// votes are not guaranteed to be recorded in the order they were cast.
+func saveVote(tx *gorm.DB, pollID uint, voter string, selected []string, votedAt time.Time) error {
+	vote := models.PollVote{
+		PollID:          pollID,
+		Voter:           voter,
+		SelectedOptions: selected,
+		VotedAt:         votedAt,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "poll_id"}, {Name: "voter"}},
+		DoUpdates: clause.AssignmentColumns([]string{"selected_options", "voted_at", "updated_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "poll_votes.voted_at <= excluded.voted_at"}}},
+	}).Create(&vote).Error
+}
+
+// reconcileVotes moves the session's pending votes to their polls once the
+// outbox has recorded the polls' WhatsApp ids. Pending votes that expired,
+// or whose chat no longer has a poll waiting for its id, are dropped.
+func (s *PollService) reconcileVotes(sessionName string) error {
+	var matched []struct {
+		models.PendingPollVote
+		PollID uint
+	}
+	err := db.DB.Model(&models.PendingPollVote{}).
+		Select("pending_poll_votes.*, polls.id AS poll_id").
+		Joins("JOIN outbox_messages ON outbox_messages.session_name = pending_poll_votes.session_name AND outbox_messages.wa_message_id = pending_poll_votes.poll_message_id").
+		Joins("JOIN polls ON polls.outbox_message_id = outbox_messages.id AND polls.deleted_at IS NULL").
+		Where("pending_poll_votes.session_name = ? AND outbox_messages.deleted_at IS NULL", sessionName).
+		Scan(&matched).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, vote := range matched {
+			if err := saveVote(tx, vote.PollID, vote.Voter, vote.SelectedOptions, vote.VotedAt); err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&vote.PendingPollVote).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return db.DB.Unscoped().
+		Where("session_name = ?", sessionName).
+		Where("created_at < ? OR chat_id NOT IN (?)", time.Now().Add(-pendingVoteTTL), outstandingPolls(db.DB, sessionName).Select("polls.chat_id")).
+		Delete(&models.PendingPollVote{}).Error
+}
+
+// hasOutstandingPoll reports whether the session has a poll in chatID whose
+// WhatsApp id the outbox has not recorded yet.
+func (s *PollService) hasOutstandingPoll(sessionName, chatID string) (bool, error) {
+	var count int64
+	err := outstandingPolls(db.DB, sessionName).Where("polls.chat_id = ?", chatID).Count(&count).Error
+	return count > 0, err
+}
+
+func outstandingPolls(tx *gorm.DB, sessionName string) *gorm.DB {
+	return tx.Model(&models.Poll{}).
+		Joins("JOIN outbox_messages ON outbox_messages.id = polls.outbox_message_id").
+		Where("polls.session_name = ? AND outbox_messages.wa_message_id = ''", sessionName)
+}
+
+func (s *PollService) ListPolls(sessionName, chatID string) ([]models.Poll, error) {
+	query := db.DB.Where("session_name = ?", sessionName)
+	if chatID != "" {
+		query = query.Where("chat_id = ?", chatID)
+	}
+
+	var polls []models.Poll
+	if err := query.Order("created_at desc").Find(&polls).Error; err != nil {
+		return nil, err
+	}
+	return polls, nil
+}
+
+func (s *PollService) GetTally(sessionName string, id uint) (*models.PollTally, error) {
+	if err := s.reconcileVotes(sessionName); err != nil {
+		return nil, err
+	}
+
+	var poll models.Poll
+	err := db.DB.Preload("Votes").Where("id = ? AND session_name = ?", id, sessionName).First(&poll).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tally(poll), nil
+}
+
+// LatestTally returns the tally of the newest poll the session sent to
+// chatID.
+func (s *PollService) LatestTally(sessionName, chatID string) (*models.PollTally, error) {
+	if err := s.reconcileVotes(sessionName); err != nil {
+		return nil, err
+	}
+
+	var poll models.Poll
+	err := db.DB.Preload("Votes").Where("session_name = ? AND chat_id = ?", sessionName, chatID).Order("created_at desc").First(&poll).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tally(poll), nil
+}
+
+// AnnounceResults queues a message with the poll's current results in the
+// chat the poll was sent to.
+func (s *PollService) AnnounceResults(t *models.PollTally) (*models.OutboxMessage, error) {
+	return s.outboxService.EnqueueText(t.Poll.SessionName, t.Poll.ChatID, "", FormatPollResults(t), false)
+}
+
+// FormatPollResults renders a tally as a WhatsApp message.
+func FormatPollResults(t *models.PollTally) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 *%s*\n", t.Poll.Question)
+	for _, c := range t.Counts {
+		fmt.Fprintf(&b, "\n%s — %d", c.Option, c.Votes)
+	}
+	fmt.Fprintf(&b, "\n\n%d voter(s)", t.Voters)
+	return b.String()
+}
+
+// findByMessageID resolves a poll of the session by the WhatsApp id its
+// outbox message got.
+func (s *PollService) findByMessageID(sessionName, waMessageID string) (*models.Poll, error) {
+	var poll models.Poll
+	err := db.DB.
+		Joins("JOIN outbox_messages ON outbox_messages.id = polls.outbox_message_id").
+		Where("polls.session_name = ? AND outbox_messages.wa_message_id = ?", sessionName, waMessageID).
+		First(&poll).Error
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+func tally(poll models.Poll) *models.PollTally {
+	counts := make([]models.PollOptionCount, len(poll.Options))
+	for i, option := range poll.Options {
+		counts[i].Option = option
+	}
+
+	voters := 0
+	for _, vote := range poll.Votes {
+		if len(vote.SelectedOptions) == 0 {
+			continue
+		}
+		voters++
+		for i, option := range poll.Options {
+			if slices.Contains(vote.SelectedOptions, option) {
+				counts[i].Votes++
+			}
+		}
+	}
+
+	return &models.PollTally{Poll: poll, Counts: counts, Voters: voters}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+func newPollTest(t *testing.T) (*PollService, *OutboxService) {
+	t.Helper()
+
+	outbox, _, _ := newOutboxTest(t)
+	if err := db.DB.AutoMigrate(&models.Poll{}, &models.PollVote{}, &models.PendingPollVote{}); err != nil {
+		t.Fatalf("migrating polls: %v", err)
+	}
+	return NewPollService(outbox), outbox
+}
+
+func TestCreatePoll(t *testing.T) {
+	polls, _ := newPollTest(t)
+
+	tooMany := make([]string, pollMaxOptions+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprint("option ", i)
+	}
+
+	tests := []struct {
+		name     string
+		question string
+		options  []string
+		err      error
+	}{
+		{"valid", " Lunch? ", []string{" pizza ", "sushi"}, nil},
+		{"no question", " ", []string{"pizza", "sushi"}, ErrInvalidPoll},
+		{"one option", "Lunch?", []string{"pizza"}, ErrInvalidPoll},
+		{"blank option", "Lunch?", []string{"pizza", " "}, ErrInvalidPoll},
+		{"duplicate options", "Lunch?", []string{"pizza", "pizza "}, ErrInvalidPoll},
+		{"too many options", "Lunch?", tooMany, ErrInvalidPoll},
+	}
+
+	for _, tt := range tests {
+		poll, item, err := polls.CreatePoll("default", "15552223333@c.us", tt.question, tt.options, false)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+		if err != nil {
+			continue
+		}
+		if poll.Question != "Lunch?" || fmt.Sprint(poll.Options) != "[pizza sushi]" || poll.OutboxMessageID != item.ID || item.Kind != OutboxKindPoll {
+			t.Errorf("%s: poll %+v queued as %+v", tt.name, poll, item)
+		}
+
+		var stored models.Poll
+		if err := db.DB.First(&stored, poll.ID).Error; err != nil || stored.OutboxMessageID != item.ID {
+			t.Errorf("%s: stored poll %+v (%v) not linked to outbox message %d", tt.name, stored, err, item.ID)
+		}
+	}
+}
+
+func TestPollTally(t *testing.T) {
+	polls, outbox := newPollTest(t)
+	ctx := context.Background()
+	const group = "120363000000000000@g.us"
+
+	poll, item, err := polls.CreatePoll("default", group, "Lunch?", []string{"pizza", "sushi", "salad"}, true)
+	if err != nil {
+		t.Fatalf("CreatePoll: %v", err)
+	}
+
+	base := time.Now().Add(-time.Hour)
+	vote := func(voter string, minutes int, options ...string) connModel.PollVotePayload {
+		return connModel.PollVotePayload{
+			Vote: connModel.PollVote{From: group, Participant: voter, SelectedOptions: options, Timestamp: base.Add(time.Duration(minutes) * time.Minute).Unix()},
+			Poll: connModel.PollMessageRef{ID: connModel.WAMessageID(outboxItem(t, item.ID).WAMessageID)},
+		}
+	}
+
+	// ada votes before the outbox recorded the poll's id; the vote is
+	// kept and counted once the poll is known.
+	early := vote("ada@c.us", 0, "pizza")
+	early.Poll.ID = "true_" + group + "_POLL"
+	if err := polls.RecordVote("default", early); err != nil {
+		t.Fatalf("early vote: %v", err)
+	}
+	outbox.processDue(ctx)
+	if err := db.DB.Model(&models.OutboxMessage{}).Where("id = ?", item.ID).Update("wa_message_id", early.Poll.ID).Error; err != nil {
+		t.Fatalf("setting the poll's id: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		vote   connModel.PollVotePayload
+		counts string // votes per option after the vote
+		voters int
+	}{
+		{"ben", vote("ben@c.us", 1, "pizza", "sushi"), "[2 1 0]", 2},
+		{"cy", vote("cy@c.us", 2, "salad"), "[2 1 1]", 3},
+		{"ben changes the vote", vote("ben@c.us", 3, "salad"), "[1 0 2]", 3},
+		{"ben's older vote arrives late", vote("ben@c.us", 2, "sushi"), "[1 0 2]", 3},
+		{"cy retracts", vote("cy@c.us", 4), "[1 0 1]", 2},
+		{"a poll Lumi did not send", func() connModel.PollVotePayload {
+			v := vote("dee@c.us", 5, "pizza")
+			v.Poll.ID = "true_other_POLL"
+			return v
+		}(), "[1 0 1]", 2},
+	}
+
+	for _, tt := range tests {
+		if err := polls.RecordVote("default", tt.vote); err != nil {
+			t.Fatalf("%s: RecordVote: %v", tt.name, err)
+		}
+
+		tally, err := polls.GetTally("default", poll.ID)
+		if err != nil {
+			t.Fatalf("%s: GetTally: %v", tt.name, err)
+		}
+		var counts []int
+		for _, c := range tally.Counts {
+			counts = append(counts, c.Votes)
+		}
+		if fmt.Sprint(counts) != tt.counts || tally.Voters != tt.voters {
+			t.Errorf("%s: counts %v with %d voters, want %s with %d", tt.name, counts, tally.Voters, tt.counts, tt.voters)
+		}
+	}
+
+	latest, err := polls.LatestTally("default", group)
+	if err != nil || latest.Poll.ID != poll.ID {
+		t.Fatalf("LatestTally = %+v, %v; want poll %d", latest, err, poll.ID)
+	}
+	if _, err := polls.LatestTally("other", group); !errors.Is(err, ErrPollNotFound) {
+		t.Errorf("LatestTally on another session: %v, want ErrPollNotFound", err)
+	}
+	if _, err := polls.GetTally("other", poll.ID); !errors.Is(err, ErrPollNotFound) {
+		t.Errorf("GetTally on another session: %v, want ErrPollNotFound", err)
+	}
+
+	want := "📊 *Lunch?*\n\npizza — 1\nsushi — 0\nsalad — 1\n\n2 voter(s)"
+	if got := FormatPollResults(latest); got != want {
+		t.Errorf("FormatPollResults = %q, want %q", got, want)
+	}
+}
+
+func TestPendingPollVotes(t *testing.T) {
+	polls, outbox := newPollTest(t)
+	ctx := context.Background()
+	const group = "120363000000000000@g.us"
+
+	_, item, err := polls.CreatePoll("default", group, "Lunch?", []string{"pizza", "sushi"}, false)
+	if err != nil {
+		t.Fatalf("CreatePoll: %v", err)
+	}
+
+	vote := func(pollID, voter string) connModel.PollVotePayload {
+		return connModel.PollVotePayload{
+			Vote: connModel.PollVote{From: group, Participant: voter, SelectedOptions: []string{"pizza"}},
+			Poll: connModel.PollMessageRef{ID: connModel.WAMessageID(pollID)},
+		}
+	}
+	pending := func() []string {
+		t.Helper()
+		var voters []string
+		if err := db.DB.Model(&models.PendingPollVote{}).Order("voter").Pluck("voter", &voters).Error; err != nil {
+			t.Fatalf("listing pending votes: %v", err)
+		}
+		return voters
+	}
+
+	// While the poll waits for its id, votes in its chat are kept and votes
+	// elsewhere are not.
+	steps := []struct {
+		name  string
+		vote  connModel.PollVotePayload
+		voter []string
+	}{
+		{"chat with a queued poll", vote("true_"+group+"_A", "ada@c.us"), []string{"ada@c.us"}},
+		{"another poll of the chat", vote("true_"+group+"_B", "ben@c.us"), []string{"ada@c.us", "ben@c.us"}},
+		{"chat without a poll", vote("true_15552223333@c.us_C", "cy@c.us"), []string{"ada@c.us", "ben@c.us"}},
+		{"id without a chat", vote("C", "dee@c.us"), []string{"ada@c.us", "ben@c.us"}},
+	}
+	for _, step := range steps {
+		if err := polls.RecordVote("default", step.vote); err != nil {
+			t.Fatalf("%s: RecordVote: %v", step.name, err)
+		}
+		if got := pending(); fmt.Sprint(got) != fmt.Sprint(step.voter) {
+			t.Errorf("%s: pending voters %v, want %v", step.name, got, step.voter)
+		}
+	}
+
+	// An expired vote is dropped even while the poll is still queued.
+	if err := db.DB.Model(&models.PendingPollVote{}).Where("voter = ?", "ben@c.us").Update("created_at", time.Now().Add(-pendingVoteTTL-time.Minute)).Error; err != nil {
+		t.Fatalf("aging ben's vote: %v", err)
+	}
+	if err := polls.reconcileVotes("default"); err != nil {
+		t.Fatalf("reconcileVotes: %v", err)
+	}
+	if got := pending(); fmt.Sprint(got) != "[ada@c.us]" {
+		t.Errorf("pending voters after expiry %v, want [ada@c.us]", got)
+	}
+
+	// Once the poll is sent under another id, nothing in the chat waits
+	// for an id any more and ada's vote is dropped.
+	outbox.processDue(ctx)
+	if id := outboxItem(t, item.ID).WAMessageID; id == "" || id == "true_"+group+"_A" {
+		t.Fatalf("poll sent as %q", id)
+	}
+	if err := polls.reconcileVotes("default"); err != nil {
+		t.Fatalf("reconcileVotes: %v", err)
+	}
+	if got := pending(); len(got) != 0 {
+		t.Errorf("pending voters once the poll was sent %v, want none", got)
+	}
+}
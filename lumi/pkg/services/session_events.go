@@ -0,0 +1,73 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+const (
+	SessionEventStatus  = "status"
+	SessionEventProfile = "profile"
+)
+
+// sessionEventBuffer is how many events a slow subscriber may lag behind
+// before further events are dropped for it.
+const sessionEventBuffer = 16
+
+// SessionEvent is a change of a WhatsApp session pushed to live subscribers.
+type SessionEvent struct {
+	Type    string
+	Session string
+	Status  string              // set for SessionEventStatus
+	Profile *connections.MeInfo // set for SessionEventProfile
+	At      time.Time
+}
+
+// sessionBroker fans session events out to the subscribers of each session.
+type sessionBroker struct {
+	mu   sync.Mutex
+	subs map[string]map[chan SessionEvent]struct{}
+}
+
+func newSessionBroker() *sessionBroker {
+	return &sessionBroker{subs: make(map[string]map[chan SessionEvent]struct{})}
+}
+
+func (b *sessionBroker) subscribe(sessionName string) (<-chan SessionEvent, func()) {
+	ch := make(chan SessionEvent, sessionEventBuffer)
+
+	b.mu.Lock()
+	if b.subs[sessionName] == nil {
+		b.subs[sessionName] = make(map[chan SessionEvent]struct{})
+	}
+	b.subs[sessionName][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs[sessionName], ch)
+			if len(b.subs[sessionName]) == 0 {
+				delete(b.subs, sessionName)
+			}
+		})
+	}
+	return ch, unsubscribe
+}
+
+// publish never blocks: a subscriber whose buffer is full misses the event.
+func (b *sessionBroker) publish(event SessionEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[event.Session] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/enums"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusSourceWebhook = "webhook"
+	StatusSourceSync    = "sync"
+)
+
+type SessionService struct {
+	mu      sync.Mutex
+	clients map[string]connections.WahaClient
+	events  *sessionBroker
+}
+
+func NewSessionService() *SessionService {
+	return &SessionService{
+		clients: make(map[string]connections.WahaClient),
+		events:  newSessionBroker(),
+	}
+}
+
+// GetOrCreateUserSession returns the WhatsApp session owned by the user,
+// creating the record (not the WAHA session itself) on first use.
+func (s *SessionService) GetOrCreateUserSession(userID uint) (*models.WhatsAppSession, error) {
+	var session models.WhatsAppSession
+	err := db.DB.Where(models.WhatsAppSession{UserID: userID}).
+		Attrs(models.WhatsAppSession{WahaSessionName: sessionNameForUser(userID)}).
+		FirstOrCreate(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *SessionService) GetUserSession(userID uint) (*models.WhatsAppSession, error) {
+	var session models.WhatsAppSession
+	if err := db.DB.Where("user_id = ?", userID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *SessionService) GetSessionByName(sessionName string) (*models.WhatsAppSession, error) {
+	var session models.WhatsAppSession
+	if err := db.DB.Where("waha_session_name = ?", sessionName).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListWorkingSessions returns the sessions that are currently connected.
+func (s *SessionService) ListWorkingSessions() ([]models.WhatsAppSession, error) {
+	var sessions []models.WhatsAppSession
+	if err := db.DB.Where("status = ?", enums.WAHA_SESSION_WORKING.String()).Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RecordStatus stores the session's new status and, if it changed, logs
+// the transition and notifies subscribers. It reports whether it changed.
+func (s *SessionService) RecordStatus(sessionName, status, source string) (bool, error) {
+	now := time.Now()
+	changed := false
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var session models.WhatsAppSession
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("waha_session_name = ?", sessionName).
+			First(&session).Error
+		if err != nil {
+			return err
+		}
+		if session.Status == status {
+			return nil
+		}
+		from := session.Status
+
+		err = tx.Model(&session).Updates(map[string]any{"status": status, "status_at": now}).Error
+		if err != nil {
+			return err
+		}
+
+		changed = true
+		return tx.Create(&models.SessionStatusChange{
+			SessionName: sessionName,
+			FromStatus:  from,
+			ToStatus:    status,
+			Source:      source,
+			ChangedAt:   now,
+		}).Error
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+
+	s.events.publish(SessionEvent{Type: SessionEventStatus, Session: sessionName, Status: status, At: now})
+	return true, nil
+}
+
+// StatusHistory returns the session's latest status transitions, newest
+// first.
+func (s *SessionService) StatusHistory(sessionName string, limit int) ([]models.SessionStatusChange, error) {
+	var changes []models.SessionStatusChange
+	err := db.DB.Where("session_name = ?", sessionName).
+		Order("changed_at desc").
+		Limit(limit).
+		Find(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// SyncStatuses asks WAHA for the status of every known session, so the
+// stored status is right even if webhooks were missed while Lumi was down.
+func (s *SessionService) SyncStatuses(ctx context.Context) {
+	var sessions []models.WhatsAppSession
+	if err := db.DB.Find(&sessions).Error; err != nil {
+		log.Printf("Session: failed to load sessions: %v", err)
+		return
+	}
+
+	for _, session := range sessions {
+		if ctx.Err() != nil {
+			return
+		}
+
+		status := enums.WAHA_SESSION_STOPPED.String()
+		info, err := s.Client(session.WahaSessionName).GetSessionStatus(ctx)
+		if err == nil {
+			status = info.Status
+		} else if !connections.IsNotFoundError(err) {
+			log.Printf("Session: failed to get status of %s: %v", session.WahaSessionName, err)
+			continue
+		}
+
+		if _, err := s.RecordStatus(session.WahaSessionName, status, StatusSourceSync); err != nil {
+			log.Printf("Session: failed to record status of %s: %v", session.WahaSessionName, err)
+		}
+	}
+}
+
+// Subscribe streams the session's status and profile events until the
+// returned function is called.
+func (s *SessionService) Subscribe(sessionName string) (<-chan SessionEvent, func()) {
+	return s.events.subscribe(sessionName)
+}
+
+// PublishProfile tells subscribers which account the session is logged in as.
+func (s *SessionService) PublishProfile(sessionName string, profile *connModel.MeInfo) {
+	s.events.publish(SessionEvent{Type: SessionEventProfile, Session: sessionName, Profile: profile, At: time.Now()})
+}
+
+func (s *SessionService) UpdateDeviceID(sessionName, deviceID string) error {
+	return db.DB.Model(&models.WhatsAppSession{}).
+		Where("waha_session_name = ?", sessionName).
+		Update("device_id", deviceID).Error
+}
+
+// Client returns the WAHA client bound to the given session name.
+func (s *SessionService) Client(sessionName string) connections.WahaClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, ok := s.clients[sessionName]
+	if !ok {
+		client = connections.NewWahaService(sessionName)
+		s.clients[sessionName] = client
+	}
+	return client
+}
+
+// ClientForUser resolves the user's session and returns a client bound to it.
+func (s *SessionService) ClientForUser(userID uint) (connections.WahaClient, error) {
+	session, err := s.GetOrCreateUserSession(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve whatsapp session: %w", err)
+	}
+	return s.Client(session.WahaSessionName), nil
+}
+
+func sessionNameForUser(userID uint) string {
+	return fmt.Sprintf("%s_%s", config.GConfig.WahaSessionName, utils.Mask(userID))
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Mahaveer86619/lumi/pkg/config"
+	"github.com/Mahaveer86619/lumi/pkg/db/dbtest"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+)
+
+// useConfig replaces config.GConfig for the duration of the test.
+func useConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+
+	previous := config.GConfig
+	config.GConfig = cfg
+	t.Cleanup(func() { config.GConfig = previous })
+}
+
+func TestGetOrCreateUserSession(t *testing.T) {
+	dbtest.Open(t, &models.WhatsAppSession{})
+	useConfig(t, &config.Config{WahaSessionName: "lumi"})
+
+	sessions := NewSessionService()
+
+	first, err := sessions.GetOrCreateUserSession(1)
+	if err != nil {
+		t.Fatalf("GetOrCreateUserSession: %v", err)
+	}
+	if !strings.HasPrefix(first.WahaSessionName, "lumi_") || first.UserID != 1 {
+		t.Errorf("session = %+v, want a lumi_ session of user 1", first)
+	}
+
+	again, err := sessions.GetOrCreateUserSession(1)
+	if err != nil {
+		t.Fatalf("GetOrCreateUserSession again: %v", err)
+	}
+	if again.ID != first.ID || again.WahaSessionName != first.WahaSessionName {
+		t.Errorf("second call = %+v, want the session created first (%+v)", again, first)
+	}
+
+	other, err := sessions.GetOrCreateUserSession(2)
+	if err != nil {
+		t.Fatalf("GetOrCreateUserSession for user 2: %v", err)
+	}
+	if other.WahaSessionName == first.WahaSessionName {
+		t.Errorf("users 1 and 2 share session %s", other.WahaSessionName)
+	}
+
+	byName, err := sessions.GetSessionByName(other.WahaSessionName)
+	if err != nil || byName.UserID != 2 {
+		t.Errorf("GetSessionByName(%s) = %+v, %v; want user 2's session", other.WahaSessionName, byName, err)
+	}
+}
+
+func TestSessionClientIsCachedPerSession(t *testing.T) {
+	useConfig(t, &config.Config{WahaServiceURL: "http://waha.invalid"})
+
+	sessions := NewSessionService()
+
+	if sessions.Client("a") != sessions.Client("a") {
+		t.Errorf("Client(a) built a new client on the second call")
+	}
+	if sessions.Client("a") == sessions.Client("b") {
+		t.Errorf("sessions a and b share a client")
+	}
+}
+
+func TestRecordStatus(t *testing.T) {
+	dbtest.Open(t, &models.WhatsAppSession{}, &models.SessionStatusChange{})
+	useConfig(t, &config.Config{WahaSessionName: "lumi"})
+
+	sessions := NewSessionService()
+	session, err := sessions.GetOrCreateUserSession(1)
+	if err != nil {
+		t.Fatalf("GetOrCreateUserSession: %v", err)
+	}
+	name := session.WahaSessionName
+
+	events, unsubscribe := sessions.Subscribe(name)
+	defer unsubscribe()
+	others, unsubscribeOthers := sessions.Subscribe("someone-else")
+	defer unsubscribeOthers()
+
+	steps := []struct {
+		status  string
+		source  string
+		changed bool
+	}{
+		{"STARTING", StatusSourceWebhook, true},
+		{"SCAN_QR_CODE", StatusSourceWebhook, true},
+		{"SCAN_QR_CODE", StatusSourceSync, false},
+		{"WORKING", StatusSourceWebhook, true},
+	}
+	for _, step := range steps {
+		changed, err := sessions.RecordStatus(name, step.status, step.source)
+		if err != nil {
+			t.Fatalf("RecordStatus(%s): %v", step.status, err)
+		}
+		if changed != step.changed {
+			t.Errorf("RecordStatus(%s) changed = %v, want %v", step.status, changed, step.changed)
+		}
+	}
+
+	for _, want := range []string{"STARTING", "SCAN_QR_CODE", "WORKING"} {
+		select {
+		case event := <-events:
+			if event.Type != SessionEventStatus || event.Status != want || event.Session != name {
+				t.Errorf("event = %+v, want status %s", event, want)
+			}
+		default:
+			t.Fatalf("no event for %s", want)
+		}
+	}
+	if len(events) != 0 || len(others) != 0 {
+		t.Errorf("unexpected events: %d for the session, %d for another one", len(events), len(others))
+	}
+
+	history, err := sessions.StatusHistory(name, 2)
+	if err != nil {
+		t.Fatalf("StatusHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].FromStatus != "SCAN_QR_CODE" || history[0].ToStatus != "WORKING" || history[1].ToStatus != "SCAN_QR_CODE" {
+		t.Errorf("history = %+v, want the last two transitions newest first", history)
+	}
+
+	stored, _ := sessions.GetSessionByName(name)
+	if stored.Status != "WORKING" || stored.StatusAt == nil {
+		t.Errorf("stored session = %+v, want WORKING with a time", stored)
+	}
+
+	if _, err := sessions.RecordStatus("unknown", "WORKING", StatusSourceWebhook); err == nil {
+		t.Errorf("recording the status of an unknown session succeeded")
+	}
+}
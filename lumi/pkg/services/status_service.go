@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	statusMaxAttempts  = 5
+	statusPollInterval = 5 * time.Second
+	statusBatchSize    = 10
+
+	// DefaultStatusBackground is WhatsApp's green.
+	DefaultStatusBackground = "#38b42f"
+	// WhatsApp's status fonts are numbered 0 to 10.
+	statusMaxFont = 10
+
+	// WhatsApp acks a status with READ when a contact viewed it.
+	ackRead = 3
+)
+
+var (
+	ErrInvalidStatus      = errors.New("invalid status")
+	ErrStatusNotFound     = errors.New("status not found")
+	ErrStatusDeleted      = errors.New("status is already deleted")
+	ErrUnknownStatusView  = errors.New("view is for a status Lumi did not post")
+	statusBackgroundColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+type StatusService struct {
+	sessionService *SessionService
+	wake           chan struct{}
+}
+
+func NewStatusService(sessionService *SessionService) *StatusService {
+	return &StatusService{
+		sessionService: sessionService,
+		wake:           make(chan struct{}, 1),
+	}
+}
+
+// Schedule stores a status to be posted at its ScheduledAt, or right away
+// when that is zero or past.
+func (s *StatusService) Schedule(sessionName string, post models.StatusPost) (*models.StatusPost, error) {
+	if err := validateStatus(&post); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if post.ScheduledAt.IsZero() || post.ScheduledAt.Before(now) {
+		post.ScheduledAt = now
+	}
+
+	post.SessionName = sessionName
+	post.State = models.StatusPostScheduled
+	post.NextAttemptAt = post.ScheduledAt
+
+	if err := db.DB.Create(&post).Error; err != nil {
+		return nil, err
+	}
+
+	s.notify()
+	return &post, nil
+}
+
+func validateStatus(post *models.StatusPost) error {
+	post.Text = strings.TrimSpace(post.Text)
+
+	switch post.Kind {
+	case models.StatusKindText:
+		if post.Text == "" {
+			return fmt.Errorf("%w: a text status needs text", ErrInvalidStatus)
+		}
+		if post.BackgroundColor == "" {
+			post.BackgroundColor = DefaultStatusBackground
+		}
+		if !statusBackgroundColor.MatchString(post.BackgroundColor) {
+			return fmt.Errorf("%w: background colour must look like #38b42f", ErrInvalidStatus)
+		}
+		if post.Font < 0 || post.Font > statusMaxFont {
+			return fmt.Errorf("%w: font must be between 0 and %d", ErrInvalidStatus, statusMaxFont)
+		}
+		post.Media = nil
+
+	case models.StatusKindImage, models.StatusKindVideo:
+		if post.Media == nil || (post.Media.Url == "" && post.Media.Data == "") {
+			return fmt.Errorf("%w: an %s status needs a file url or data", ErrInvalidStatus, post.Kind)
+		}
+		post.BackgroundColor, post.Font = "", 0
+
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidStatus, post.Kind)
+	}
+	return nil
+}
+
+func (s *StatusService) List(sessionName, state string) ([]models.StatusPost, error) {
+	query := db.DB.Preload("Views").Where("session_name = ?", sessionName)
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+
+	var posts []models.StatusPost
+	if err := query.Order("scheduled_at desc").Limit(200).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (s *StatusService) Get(sessionName string, id uint) (*models.StatusPost, error) {
+	var post models.StatusPost
+	err := db.DB.Preload("Views").Where("id = ? AND session_name = ?", id, sessionName).First(&post).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrStatusNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// Delete removes a posted status from WhatsApp, or cancels one that is not
+// posted yet.
+func (s *StatusService) Delete(ctx context.Context, sessionName string, id uint) (*models.StatusPost, error) {
+	post, err := s.Get(sessionName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch post.State {
+	case models.StatusPostDeleted:
+		return nil, ErrStatusDeleted
+	case models.StatusPostPosted:
+		if err := s.sessionService.Client(sessionName).DeleteStatus(ctx, post.MessageID); err != nil {
+			return nil, err
+		}
+	}
+
+	// A scheduled post the worker picked up meanwhile is left alone; it is
+	// deleted once it shows up as posted.
+	now := time.Now()
+	update := db.DB.Model(&models.StatusPost{}).
+		Where("id = ? AND state = ?", post.ID, post.State).
+		Updates(map[string]any{"state": models.StatusPostDeleted, "removed_at": now})
+	if update.Error != nil {
+		return nil, update.Error
+	}
+	if update.RowsAffected == 0 {
+		return nil, fmt.Errorf("status %d changed while deleting it, try again", post.ID)
+	}
+
+	post.State = models.StatusPostDeleted
+	post.RemovedAt = &now
+	return post, nil
+}
+
+// RecordView stores a READ ack of a status Lumi posted. Acks of other
+// messages and weaker acks are ignored.
+func (s *StatusService) RecordView(sessionName string, payload connModel.MessageAckPayload) error {
+	if payload.Ack < ackRead || !strings.Contains(payload.ID.String(), connModel.StatusBroadcastID) {
+		return nil
+	}
+
+	// Status acks look like "true_status@broadcast_<id>[_<viewer>]".
+	parts := strings.SplitN(payload.ID.String(), "_", 4)
+	if len(parts) < 3 {
+		return nil
+	}
+
+	var post models.StatusPost
+	err := db.DB.Where("session_name = ? AND message_id = ?", sessionName, parts[2]).First(&post).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUnknownStatusView
+	}
+	if err != nil {
+		return err
+	}
+
+	viewer := payload.Participant
+	if viewer == "" {
+		viewer = payload.To
+	}
+	if viewer == "" || viewer == connModel.StatusBroadcastID {
+		return nil
+	}
+
+	view := models.StatusView{StatusPostID: post.ID, Viewer: viewer, ViewedAt: time.Now()}
+	return db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&view).Error
+}
+
+// Start runs the posting worker until ctx is cancelled.
+func (s *StatusService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(statusPollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.processDue(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+func (s *StatusService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *StatusService) processDue(ctx context.Context) {
+	var posts []models.StatusPost
+	err := db.DB.
+		Where("state = ? AND next_attempt_at <= ?", models.StatusPostScheduled, time.Now()).
+		Order("next_attempt_at asc").
+		Limit(statusBatchSize).
+		Find(&posts).Error
+	if err != nil {
+		log.Printf("Status: failed to load due statuses: %v", err)
+		return
+	}
+
+	for i := range posts {
+		if ctx.Err() != nil {
+			return
+		}
+		s.post(ctx, &posts[i])
+	}
+}
+
+func (s *StatusService) post(ctx context.Context, post *models.StatusPost) {
+	client := s.sessionService.Client(post.SessionName)
+
+	// The id is reserved once, so a retry after a lost response can't post
+	// a second copy under another id.
+	err := func() error {
+		if post.MessageID == "" {
+			id, err := client.NewStatusMessageID(ctx)
+			if err != nil {
+				return err
+			}
+			post.MessageID = id
+		}
+
+		switch post.Kind {
+		case models.StatusKindText:
+			return client.PostTextStatus(ctx, connModel.StatusTextRequest{
+				ID:              post.MessageID,
+				Text:            post.Text,
+				BackgroundColor: post.BackgroundColor,
+				Font:            post.Font,
+				LinkPreview:     true,
+			})
+		case models.StatusKindImage:
+			return client.PostImageStatus(ctx, connModel.StatusMediaRequest{ID: post.MessageID, File: *post.Media, Caption: post.Text})
+		case models.StatusKindVideo:
+			return client.PostVideoStatus(ctx, connModel.StatusMediaRequest{ID: post.MessageID, File: *post.Media, Caption: post.Text, Convert: true})
+		}
+		return fmt.Errorf("unknown status kind %q", post.Kind)
+	}()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	post.Attempts++
+	now := time.Now()
+
+	if err != nil {
+		post.LastError = err.Error()
+		if post.Attempts >= statusMaxAttempts {
+			post.State = models.StatusPostFailed
+			log.Printf("Status: giving up on status %d after %d attempts: %v", post.ID, post.Attempts, err)
+		} else {
+			post.NextAttemptAt = now.Add(outboxBackoff(post.Attempts))
+		}
+	} else {
+		post.State = models.StatusPostPosted
+		post.LastError = ""
+		post.PostedAt = &now
+	}
+
+	// Only a still-scheduled row is updated, so a post cancelled while it
+	// was being sent isn't revived.
+	update := db.DB.Model(&models.StatusPost{}).
+		Where("id = ? AND state = ?", post.ID, models.StatusPostScheduled).
+		Updates(map[string]any{
+			"state":           post.State,
+			"attempts":        post.Attempts,
+			"next_attempt_at": post.NextAttemptAt,
+			"last_error":      post.LastError,
+			"message_id":      post.MessageID,
+			"posted_at":       post.PostedAt,
+		})
+	if update.Error != nil {
+		log.Printf("Status: failed to update status %d: %v", post.ID, update.Error)
+		return
+	}
+
+	if update.RowsAffected == 0 && post.State == models.StatusPostPosted {
+		log.Printf("Status: status %d was cancelled while posting, deleting it", post.ID)
+		if err := client.DeleteStatus(ctx, post.MessageID); err != nil {
+			log.Printf("Status: failed to delete cancelled status %d: %v", post.ID, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/db"
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/connections/wahatest"
+)
+
+func newStatusTest(t *testing.T) (*StatusService, *wahatest.Server) {
+	t.Helper()
+
+	_, chats, srv := newOutboxTest(t)
+	if err := db.DB.AutoMigrate(&models.StatusPost{}, &models.StatusView{}); err != nil {
+		t.Fatalf("migrating statuses: %v", err)
+	}
+	return NewStatusService(chats.SessionService), srv
+}
+
+func TestScheduleStatus(t *testing.T) {
+	statuses, _ := newStatusTest(t)
+	image := &connModel.FileWrapper{Mimetype: "image/jpeg", Url: "https://example.com/a.jpg"}
+
+	tests := []struct {
+		name       string
+		post       models.StatusPost
+		wantErr    error
+		background string
+	}{
+		{"text with the default background", models.StatusPost{Kind: models.StatusKindText, Text: " Hi "}, nil, DefaultStatusBackground},
+		{"text with a background", models.StatusPost{Kind: models.StatusKindText, Text: "Hi", BackgroundColor: "#000000", Font: 3}, nil, "#000000"},
+		{"empty text", models.StatusPost{Kind: models.StatusKindText, Text: "  "}, ErrInvalidStatus, ""},
+		{"named colour", models.StatusPost{Kind: models.StatusKindText, Text: "Hi", BackgroundColor: "green"}, ErrInvalidStatus, ""},
+		{"unknown font", models.StatusPost{Kind: models.StatusKindText, Text: "Hi", Font: 11}, ErrInvalidStatus, ""},
+		{"image", models.StatusPost{Kind: models.StatusKindImage, Media: image, BackgroundColor: "#000000"}, nil, ""},
+		{"video without a file", models.StatusPost{Kind: models.StatusKindVideo}, ErrInvalidStatus, ""},
+		{"unknown kind", models.StatusPost{Kind: "voice", Text: "Hi"}, ErrInvalidStatus, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post, err := statuses.Schedule("default", tt.post)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Schedule err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if post.State != models.StatusPostScheduled || post.ScheduledAt.IsZero() || post.BackgroundColor != tt.background {
+				t.Errorf("scheduled %+v, want it due now with background %q", post, tt.background)
+			}
+		})
+	}
+}
+
+func TestPostStatus(t *testing.T) {
+	statuses, srv := newStatusTest(t)
+	ctx := context.Background()
+
+	due, err := statuses.Schedule("default", models.StatusPost{Kind: models.StatusKindText, Text: "Good morning"})
+	if err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	later, err := statuses.Schedule("default", models.StatusPost{Kind: models.StatusKindText, Text: "Good night", ScheduledAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+
+	// The first attempt fails after reserving the id; the retry posts the
+	// status under that same id.
+	srv.FailNext("/api/default/status/text", http.StatusInternalServerError)
+	statuses.processDue(ctx)
+	db.DB.Model(&models.StatusPost{}).Where("id = ?", due.ID).Update("next_attempt_at", time.Now())
+	statuses.processDue(ctx)
+
+	posted, err := statuses.Get("default", due.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	sent := srv.Statuses()
+	if posted.State != models.StatusPostPosted || posted.Attempts != 2 || len(sent) != 1 || sent[0].ID != posted.MessageID {
+		t.Fatalf("after a retry: %+v, WAHA has %+v; want one status posted on the second attempt", posted, sent)
+	}
+
+	viewID := func(viewer string) connModel.MessageAckPayload {
+		return connModel.MessageAckPayload{
+			ID:          connModel.WAMessageID(fmt.Sprintf("true_%s_%s_%s", connModel.StatusBroadcastID, posted.MessageID, viewer)),
+			Participant: viewer,
+			Ack:         ackRead,
+		}
+	}
+	tests := []struct {
+		name    string
+		ack     connModel.MessageAckPayload
+		wantErr error
+		views   int
+	}{
+		{"a contact views it", viewID("15552223333@c.us"), nil, 1},
+		{"the same contact again", viewID("15552223333@c.us"), nil, 1},
+		{"delivered is not a view", func() connModel.MessageAckPayload { a := viewID("15554445555@c.us"); a.Ack = 2; return a }(), nil, 1},
+		{"another contact", viewID("15554445555@c.us"), nil, 2},
+		{"a status Lumi did not post", connModel.MessageAckPayload{ID: "true_status@broadcast_OTHER_15552223333@c.us", Participant: "15552223333@c.us", Ack: ackRead}, ErrUnknownStatusView, 2},
+		{"an ordinary message", connModel.MessageAckPayload{ID: "true_15552223333@c.us_AAA", Ack: ackRead}, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := statuses.RecordView("default", tt.ack); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RecordView err = %v, want %v", err, tt.wantErr)
+			}
+			post, _ := statuses.Get("default", due.ID)
+			if len(post.Views) != tt.views {
+				t.Errorf("%d views, want %d", len(post.Views), tt.views)
+			}
+		})
+	}
+
+	// Deleting removes a posted status from WhatsApp and cancels a
+	// scheduled one without calling WAHA.
+	if _, err := statuses.Delete(ctx, "default", due.ID); err != nil {
+		t.Fatalf("Delete posted: %v", err)
+	}
+	if _, err := statuses.Delete(ctx, "default", later.ID); err != nil {
+		t.Fatalf("Delete scheduled: %v", err)
+	}
+	if _, err := statuses.Delete(ctx, "default", later.ID); !errors.Is(err, ErrStatusDeleted) {
+		t.Errorf("deleting twice: err = %v, want ErrStatusDeleted", err)
+	}
+	if sent := srv.Statuses(); len(sent) != 1 || !sent[0].Deleted {
+		t.Errorf("WAHA has %+v, want the one posted status deleted", sent)
+	}
+}
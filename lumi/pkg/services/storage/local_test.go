@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"session/123@c.us/abc.jpg", true},
+		{"a/../b.txt", true},
+		{"../outside.txt", false},
+		{"a/../../outside.txt", false},
+		{"/etc/passwd", false},
+		{".", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			n, err := store.Save(ctx, tt.key, strings.NewReader("data"))
+			if !tt.valid {
+				if err == nil {
+					t.Fatalf("saved %q outside the storage dir", tt.key)
+				}
+				return
+			}
+			if err != nil || n != 4 {
+				t.Fatalf("Save = %d, %v", n, err)
+			}
+
+			r, err := store.Open(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			data, _ := io.ReadAll(r)
+			r.Close()
+			if string(data) != "data" {
+				t.Errorf("read %q, want %q", data, "data")
+			}
+
+			if err := store.Delete(ctx, tt.key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := store.Open(ctx, tt.key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Open after Delete: %v, want ErrNotFound", err)
+			}
+			if err := store.Delete(ctx, tt.key); err != nil {
+				t.Errorf("deleting a missing key: %v", err)
+			}
+		})
+	}
+}
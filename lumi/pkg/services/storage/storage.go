@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage persists opaque blobs under string keys. Keys use forward slashes
+// and are relative, e.g. "default_abc/123@c.us/true_123@c.us_ABC.jpg".
+type Storage interface {
+	Save(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -3,16 +3,19 @@ package services
 import (
 	"errors"
 
-	"github.com/Mahaveer86619/lumi/pkg/config"
 	"github.com/Mahaveer86619/lumi/pkg/db"
 	"github.com/Mahaveer86619/lumi/pkg/models"
 	"github.com/Mahaveer86619/lumi/pkg/views"
 )
 
-type UserService struct{}
+type UserService struct {
+	sessionService *SessionService
+}
 
-func NewUserService() *UserService {
-	return &UserService{}
+func NewUserService(sessionService *SessionService) *UserService {
+	return &UserService{
+		sessionService: sessionService,
+	}
 }
 
 func (us UserService) GetUserDetails(id uint) (*views.UserDetailsResponse, error) {
@@ -21,7 +24,7 @@ func (us UserService) GetUserDetails(id uint) (*views.UserDetailsResponse, error
 		return nil, errors.New("invalid credentials")
 	}
 
-	waStatus := config.GetWhatsappConnectionStatus()
+	waStatus := us.whatsappStatus(user.ID)
 
 	response := views.NewUserDetailsResponse(user, waStatus)
 	return response, nil
@@ -40,7 +43,7 @@ func (us UserService) UpdateUserDetails(req views.UpdateUserRequest) (*views.Use
 		return nil, err
 	}
 
-	waStatus := config.GetWhatsappConnectionStatus()
+	waStatus := us.whatsappStatus(user.ID)
 
 	response := views.NewUserDetailsResponse(user, waStatus)
 	return response, nil
@@ -58,3 +61,11 @@ func (us UserService) DeleteUser(id uint) error {
 
 	return nil
 }
+
+func (us UserService) whatsappStatus(userID uint) string {
+	session, err := us.sessionService.GetUserSession(userID)
+	if err != nil {
+		return "unknown"
+	}
+	return session.Status
+}
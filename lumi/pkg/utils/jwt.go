@@ -46,6 +46,30 @@ func GenerateTokens(userID uint) (string, string, error) {
 	return accessString, refreshString, nil
 }
 
+// StreamTokenTTL is how long a stream token can be used to open an event
+// stream; the stream itself may stay open longer.
+const StreamTokenTTL = time.Minute
+
+// GenerateStreamToken returns a short-lived token for opening server-sent
+// event streams. Browsers' EventSource can't send an Authorization header,
+// so it goes in the URL, where it should not stay useful for long.
+func GenerateStreamToken(userID uint) (string, time.Time, error) {
+	expiresAt := time.Now().Add(StreamTokenTTL)
+	claims := &JwtCustomClaims{
+		UserID: userID,
+		Type:   "stream",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.GConfig.JWTSecret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
 func ValidateToken(tokenString string, expectedType string) (*JwtCustomClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -0,0 +1,101 @@
+package views
+
+import (
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+)
+
+type CampaignTarget struct {
+	ChatIDs []string `json:"chat_ids"`
+	Tags    []string `json:"tags"` // registered chats with any of these tags
+	Type    string   `json:"type"` // all registered chats of this type, e.g. "chat" or "group"
+}
+
+type CreateCampaignRequest struct {
+	Name            string         `json:"name"`
+	Template        string         `json:"template"` // e.g. "Hi {{.FirstName}}, ..."; also {{.Name}} and {{.ChatID}}
+	Target          CampaignTarget `json:"target"`
+	MinDelaySeconds int            `json:"min_delay_seconds"` // 30 when empty
+	MaxDelaySeconds int            `json:"max_delay_seconds"` // 90 when empty
+	DailyCap        int            `json:"daily_cap"`         // 100 when empty
+}
+
+type Campaign struct {
+	ID              utils.MaskedId `json:"id"`
+	Name            string         `json:"name"`
+	Template        string         `json:"template"`
+	Status          string         `json:"status"`
+	MinDelaySeconds int            `json:"min_delay_seconds"`
+	MaxDelaySeconds int            `json:"max_delay_seconds"`
+	DailyCap        int            `json:"daily_cap"`
+	NextSendAt      *time.Time     `json:"next_send_at,omitempty"` // while running
+	Recipients      int            `json:"recipients"`
+	Counts          map[string]int `json:"counts"` // recipients per status
+	CompletedAt     *time.Time     `json:"completed_at,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+type CampaignRecipient struct {
+	ID              utils.MaskedId  `json:"id"`
+	ChatID          string          `json:"chat_id"`
+	Name            string          `json:"name,omitempty"`
+	Status          string          `json:"status"`
+	Text            string          `json:"text,omitempty"`
+	OutboxMessageID *utils.MaskedId `json:"outbox_message_id,omitempty"`
+	LastError       string          `json:"last_error,omitempty"`
+	QueuedAt        *time.Time      `json:"queued_at,omitempty"`
+	SentAt          *time.Time      `json:"sent_at,omitempty"`
+}
+
+func NewCampaignResponse(progress models.CampaignProgress) Campaign {
+	campaign := progress.Campaign
+	resp := Campaign{
+		ID:              utils.Mask(campaign.ID),
+		Name:            campaign.Name,
+		Template:        campaign.Template,
+		Status:          campaign.Status,
+		MinDelaySeconds: campaign.MinDelay,
+		MaxDelaySeconds: campaign.MaxDelay,
+		DailyCap:        campaign.DailyCap,
+		Recipients:      progress.Total,
+		Counts:          progress.Counts,
+		CompletedAt:     campaign.CompletedAt,
+		CreatedAt:       campaign.CreatedAt,
+	}
+	if campaign.Status == models.CampaignRunning {
+		resp.NextSendAt = &campaign.NextSendAt
+	}
+	return resp
+}
+
+func NewCampaignListResponse(campaigns []models.CampaignProgress) []Campaign {
+	resp := []Campaign{}
+	for _, campaign := range campaigns {
+		resp = append(resp, NewCampaignResponse(campaign))
+	}
+	return resp
+}
+
+func NewCampaignRecipientListResponse(recipients []models.CampaignRecipient) []CampaignRecipient {
+	resp := []CampaignRecipient{}
+	for _, r := range recipients {
+		recipient := CampaignRecipient{
+			ID:        utils.Mask(r.ID),
+			ChatID:    r.ChatID,
+			Name:      r.Name,
+			Status:    r.Status,
+			Text:      r.Text,
+			LastError: r.LastError,
+			QueuedAt:  r.QueuedAt,
+			SentAt:    r.SentAt,
+		}
+		if r.OutboxMessageID != 0 {
+			id := utils.Mask(r.OutboxMessageID)
+			recipient.OutboxMessageID = &id
+		}
+		resp = append(resp, recipient)
+	}
+	return resp
+}
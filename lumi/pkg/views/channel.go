@@ -0,0 +1,109 @@
+package views
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+)
+
+type CreateChannelRequest struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Picture     *connModel.FileWrapper `json:"picture"`
+}
+
+type PublishChannelPostRequest struct {
+	Text  string                 `json:"text"` // caption when an image is attached
+	Image *connModel.FileWrapper `json:"image"`
+}
+
+type DraftChannelPostRequest struct {
+	Prompt string                 `json:"prompt"` // what Lumi should write about
+	Image  *connModel.FileWrapper `json:"image"`
+}
+
+type UpdateChannelDraftRequest struct {
+	Text string `json:"text"`
+}
+
+type Channel struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InviteLink  string `json:"invite_link,omitempty"`
+	Picture     string `json:"picture,omitempty"`
+	Verified    bool   `json:"verified"`
+	Role        string `json:"role"` // "owner", "admin", "subscriber" or "guest"
+	CanPost     bool   `json:"can_post"`
+	Subscribers int    `json:"subscribers"`
+}
+
+type ChannelPost struct {
+	ID              utils.MaskedId  `json:"id"`
+	ChannelID       string          `json:"channel_id"`
+	Prompt          string          `json:"prompt,omitempty"`
+	Text            string          `json:"text"`
+	ImageMimetype   string          `json:"image_mimetype,omitempty"`
+	Status          string          `json:"status"`
+	OutboxMessageID *utils.MaskedId `json:"outbox_message_id,omitempty"`
+	PublishedAt     *time.Time      `json:"published_at,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+type PublishChannelPostResponse struct {
+	Post   ChannelPost   `json:"post"`
+	Outbox OutboxMessage `json:"outbox"`
+}
+
+func NewChannelResponse(channel connModel.Channel) Channel {
+	return Channel{
+		ID:          channel.ID,
+		Name:        channel.Name,
+		Description: channel.Description,
+		InviteLink:  channel.Invite,
+		Picture:     channel.Picture,
+		Verified:    channel.Verified,
+		Role:        strings.ToLower(channel.Role),
+		CanPost:     channel.CanPost(),
+		Subscribers: channel.SubscribersCount,
+	}
+}
+
+func NewChannelListResponse(channels []connModel.Channel) []Channel {
+	resp := []Channel{}
+	for _, channel := range channels {
+		resp = append(resp, NewChannelResponse(channel))
+	}
+	return resp
+}
+
+func NewChannelPostResponse(post models.ChannelPost) ChannelPost {
+	resp := ChannelPost{
+		ID:          utils.Mask(post.ID),
+		ChannelID:   post.ChannelID,
+		Prompt:      post.Prompt,
+		Text:        post.Text,
+		Status:      post.Status,
+		PublishedAt: post.PublishedAt,
+		CreatedAt:   post.CreatedAt,
+	}
+	if post.Image != nil {
+		resp.ImageMimetype = post.Image.Mimetype
+	}
+	if post.OutboxMessageID != 0 {
+		id := utils.Mask(post.OutboxMessageID)
+		resp.OutboxMessageID = &id
+	}
+	return resp
+}
+
+func NewChannelPostListResponse(posts []models.ChannelPost) []ChannelPost {
+	resp := []ChannelPost{}
+	for _, post := range posts {
+		resp = append(resp, NewChannelPostResponse(post))
+	}
+	return resp
+}
@@ -1,6 +1,8 @@
 package views
 
 import (
+	"time"
+
 	"github.com/Mahaveer86619/lumi/pkg/models"
 	"github.com/Mahaveer86619/lumi/pkg/utils"
 )
@@ -15,32 +17,100 @@ type RemoteChatListResponse struct {
 }
 
 type RegisterChatRequest struct {
-	ChatID string `json:"chat_id"`
-	Name   string `json:"name"`
-	Type   string `json:"type"`
+	ChatID   string `json:"chat_id"`
+	Platform string `json:"platform"` // "whatsapp" (default) or "telegram"
+	Name     string `json:"name"`     // looked up for Telegram chats when empty
+	Type     string `json:"type"`
 }
 
 type SendTextChatRequest struct {
-	ChatID string `json:"chat_id"`
-	Text   string `json:"text"`
+	ChatID  string `json:"chat_id"`
+	Text    string `json:"text"`
+	ReplyTo string `json:"reply_to"` // optional message id to quote
+}
+
+type SendReactionRequest struct {
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+	Reaction  string `json:"reaction"` // emoji, empty removes the reaction
+}
+
+type EditMessageRequest struct {
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+	Text      string `json:"text"`
+}
+
+type RevokeMessageRequest struct {
+	ChatID    string `json:"chat_id"`
+	MessageID string `json:"message_id"`
+}
+
+type UpdateChatSettingsRequest struct {
+	ShowTyping       *bool `json:"show_typing"`
+	SendReadReceipts *bool `json:"send_read_receipts"`
+}
+
+type SetChatTagsRequest struct {
+	Tags []string `json:"tags"`
 }
 
 type RegisteredChat struct {
-	ID     utils.MaskedId `json:"id"`
-	ChatID string         `gorm:"uniqueIndex;not null" json:"chat_id"` // e.g. 123@c.us
-	Name   string         `json:"name"`                                // Friendly name
-	Type   string         `json:"type"`                                // "chat" or "group"
+	ID               utils.MaskedId `json:"id"`
+	ChatID           string         `gorm:"uniqueIndex;not null" json:"chat_id"` // e.g. 123@c.us
+	Platform         string         `json:"platform"`
+	Name             string         `json:"name"` // Friendly name
+	Type             string         `json:"type"` // "chat" or "group"
+	Tags             []string       `json:"tags"`
+	ShowTyping       bool           `json:"show_typing"`
+	SendReadReceipts bool           `json:"send_read_receipts"`
 }
 
 func NewRegisteredChatResponse(chat []models.RegisteredChat) *[]RegisteredChat {
 	var resp []RegisteredChat
 	for _, c := range chat {
 		resp = append(resp, RegisteredChat{
-			ID:     utils.Mask(c.ID),
-			ChatID: c.ChatID,
-			Name:   c.Name,
-			Type:   c.Type,
+			ID:               utils.Mask(c.ID),
+			ChatID:           c.ChatID,
+			Platform:         c.Platform,
+			Name:             c.Name,
+			Type:             c.Type,
+			Tags:             c.Tags,
+			ShowTyping:       c.ShowTyping,
+			SendReadReceipts: c.SendReadReceipts,
 		})
 	}
 	return &resp
 }
+
+type ChatMessage struct {
+	ID          utils.MaskedId `json:"id"`
+	Role        string         `json:"role"` // "user" or "model"
+	Content     string         `json:"content"`
+	WAMessageID string         `json:"wa_message_id,omitempty"`
+	SentAt      time.Time      `json:"sent_at"`
+}
+
+type SyncChatHistoryResponse struct {
+	ChatID   string `json:"chat_id"`
+	Imported int    `json:"imported"`
+}
+
+func NewChatMessageListResponse(messages []models.ChatMessage) []ChatMessage {
+	resp := []ChatMessage{}
+	for _, m := range messages {
+		sentAt := m.CreatedAt
+		if m.SentAt != nil {
+			sentAt = *m.SentAt
+		}
+
+		resp = append(resp, ChatMessage{
+			ID:          utils.Mask(m.ID),
+			Role:        m.Role,
+			Content:     m.Content,
+			WAMessageID: m.WAMessageID,
+			SentAt:      sentAt,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,67 @@
+package views
+
+import (
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+)
+
+type CheckNumbersRequest struct {
+	Phones []string `json:"phones"`
+}
+
+type Contact struct {
+	ID          string     `json:"id"` // e.g. 123@c.us
+	Number      string     `json:"number"`
+	Name        string     `json:"name"`
+	PushName    string     `json:"push_name"`
+	IsMyContact bool       `json:"is_my_contact"`
+	IsBlocked   bool       `json:"is_blocked"`
+	About       string     `json:"about,omitempty"`
+	PictureURL  string     `json:"picture_url,omitempty"`
+	SyncedAt    time.Time  `json:"synced_at"`
+	DetailsAt   *time.Time `json:"details_at,omitempty"`
+}
+
+type NumberCheck struct {
+	Phone     string    `json:"phone"`
+	Exists    bool      `json:"exists"`
+	ChatID    string    `json:"chat_id,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func NewContactResponse(contact models.Contact) Contact {
+	return Contact{
+		ID:          contact.ContactID,
+		Number:      contact.Number,
+		Name:        contact.Name,
+		PushName:    contact.PushName,
+		IsMyContact: contact.IsMyContact,
+		IsBlocked:   contact.IsBlocked,
+		About:       contact.About,
+		PictureURL:  contact.PictureURL,
+		SyncedAt:    contact.SyncedAt,
+		DetailsAt:   contact.DetailsAt,
+	}
+}
+
+func NewContactListResponse(contacts []models.Contact) []Contact {
+	resp := []Contact{}
+	for _, c := range contacts {
+		resp = append(resp, NewContactResponse(c))
+	}
+	return resp
+}
+
+func NewNumberCheckListResponse(checks []models.NumberCheck) []NumberCheck {
+	resp := []NumberCheck{}
+	for _, c := range checks {
+		resp = append(resp, NumberCheck{
+			Phone:     c.Phone,
+			Exists:    c.Exists,
+			ChatID:    c.ChatID,
+			CheckedAt: c.CheckedAt,
+		})
+	}
+	return resp
+}
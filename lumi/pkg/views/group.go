@@ -0,0 +1,79 @@
+package views
+
+import (
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+)
+
+type CreateGroupRequest struct {
+	Name         string   `json:"name"`
+	Participants []string `json:"participants"` // phone numbers or WhatsApp ids
+}
+
+type GroupParticipantsRequest struct {
+	Participants []string `json:"participants"`
+}
+
+type UpdateGroupRequest struct {
+	Subject     *string `json:"subject"`
+	Description *string `json:"description"`
+}
+
+type GroupParticipant struct {
+	ID    string `json:"id"`
+	Admin bool   `json:"admin"`
+	Owner bool   `json:"owner"`
+}
+
+type GroupSettings struct {
+	AdminsOnlyMessages bool `json:"admins_only_messages"`
+	AdminsOnlyInfo     bool `json:"admins_only_info"`
+}
+
+type Group struct {
+	ID           string             `json:"id"`
+	Subject      string             `json:"subject"`
+	Description  string             `json:"description"`
+	Owner        string             `json:"owner,omitempty"`
+	CreatedAt    int64              `json:"created_at,omitempty"`
+	Participants []GroupParticipant `json:"participants"`
+	Admins       []string           `json:"admins"`
+	Settings     GroupSettings      `json:"settings"`
+}
+
+type GroupInviteResponse struct {
+	GroupID    string `json:"group_id"`
+	InviteLink string `json:"invite_link"`
+}
+
+func NewGroupResponse(group connModel.GroupInfo) Group {
+	participants := []GroupParticipant{}
+	for _, p := range group.Participants {
+		participants = append(participants, GroupParticipant{
+			ID:    p.ID,
+			Admin: p.IsAdmin(),
+			Owner: p.Admin == "superadmin",
+		})
+	}
+
+	return Group{
+		ID:           group.ID,
+		Subject:      group.Subject,
+		Description:  group.Description,
+		Owner:        group.Owner,
+		CreatedAt:    group.Creation,
+		Participants: participants,
+		Admins:       group.Admins(),
+		Settings: GroupSettings{
+			AdminsOnlyMessages: group.Announce,
+			AdminsOnlyInfo:     group.Restrict,
+		},
+	}
+}
+
+func NewGroupListResponse(groups []connModel.GroupInfo) []Group {
+	resp := []Group{}
+	for _, g := range groups {
+		resp = append(resp, NewGroupResponse(g))
+	}
+	return resp
+}
@@ -0,0 +1,38 @@
+package views
+
+import (
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+)
+
+type MediaFile struct {
+	ID        utils.MaskedId `json:"id"`
+	ChatID    string         `json:"chat_id"`
+	MessageID string         `json:"message_id"`
+	Mimetype  string         `json:"mimetype"`
+	Filename  string         `json:"filename"`
+	Size      int64          `json:"size"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func NewMediaFileResponse(file models.MediaFile) MediaFile {
+	return MediaFile{
+		ID:        utils.Mask(file.ID),
+		ChatID:    file.ChatID,
+		MessageID: file.MessageID,
+		Mimetype:  file.Mimetype,
+		Filename:  file.Filename,
+		Size:      file.Size,
+		CreatedAt: file.CreatedAt,
+	}
+}
+
+func NewMediaFileListResponse(files []models.MediaFile) []MediaFile {
+	resp := []MediaFile{}
+	for _, f := range files {
+		resp = append(resp, NewMediaFileResponse(f))
+	}
+	return resp
+}
@@ -0,0 +1,46 @@
+package views
+
+import (
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+)
+
+type OutboxMessage struct {
+	ID            utils.MaskedId `json:"id"`
+	ChatID        string         `json:"chat_id"`
+	Kind          string         `json:"kind"`
+	Text          string         `json:"text,omitempty"`
+	Status        string         `json:"status"`
+	Attempts      int            `json:"attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	LastError     string         `json:"last_error,omitempty"`
+	WAMessageID   string         `json:"wa_message_id,omitempty"`
+	SentAt        *time.Time     `json:"sent_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+func NewOutboxMessageResponse(item models.OutboxMessage) OutboxMessage {
+	return OutboxMessage{
+		ID:            utils.Mask(item.ID),
+		ChatID:        item.ChatID,
+		Kind:          item.Kind,
+		Text:          item.Text,
+		Status:        item.Status,
+		Attempts:      item.Attempts,
+		NextAttemptAt: item.NextAttemptAt,
+		LastError:     item.LastError,
+		WAMessageID:   item.WAMessageID,
+		SentAt:        item.SentAt,
+		CreatedAt:     item.CreatedAt,
+	}
+}
+
+func NewOutboxMessageListResponse(items []models.OutboxMessage) []OutboxMessage {
+	resp := []OutboxMessage{}
+	for _, item := range items {
+		resp = append(resp, NewOutboxMessageResponse(item))
+	}
+	return resp
+}
@@ -0,0 +1,72 @@
+package views
+
+import (
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+)
+
+type SendPollRequest struct {
+	ChatID          string   `json:"chat_id"`
+	Question        string   `json:"question"`
+	Options         []string `json:"options"`
+	MultipleAnswers bool     `json:"multiple_answers"`
+}
+
+type Poll struct {
+	ID              utils.MaskedId `json:"id"`
+	ChatID          string         `json:"chat_id"`
+	Question        string         `json:"question"`
+	Options         []string       `json:"options"`
+	MultipleAnswers bool           `json:"multiple_answers"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+type PollResult struct {
+	Option string `json:"option"`
+	Votes  int    `json:"votes"`
+}
+
+type PollTally struct {
+	Poll
+	Results []PollResult `json:"results"`
+	Voters  int          `json:"voters"`
+}
+
+type SendPollResponse struct {
+	Poll   Poll          `json:"poll"`
+	Outbox OutboxMessage `json:"outbox"`
+}
+
+func NewPollResponse(poll models.Poll) Poll {
+	return Poll{
+		ID:              utils.Mask(poll.ID),
+		ChatID:          poll.ChatID,
+		Question:        poll.Question,
+		Options:         poll.Options,
+		MultipleAnswers: poll.MultipleAnswers,
+		CreatedAt:       poll.CreatedAt,
+	}
+}
+
+func NewPollListResponse(polls []models.Poll) []Poll {
+	resp := []Poll{}
+	for _, p := range polls {
+		resp = append(resp, NewPollResponse(p))
+	}
+	return resp
+}
+
+func NewPollTallyResponse(t *models.PollTally) PollTally {
+	results := []PollResult{}
+	for _, c := range t.Counts {
+		results = append(results, PollResult{Option: c.Option, Votes: c.Votes})
+	}
+
+	return PollTally{
+		Poll:    NewPollResponse(t.Poll),
+		Results: results,
+		Voters:  t.Voters,
+	}
+}
@@ -0,0 +1,76 @@
+package views
+
+import (
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+)
+
+type SessionStatusChange struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Source    string    `json:"source"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type SessionStatusResponse struct {
+	Session  string                `json:"session"`
+	Status   string                `json:"status"`
+	StatusAt *time.Time            `json:"status_at,omitempty"`
+	History  []SessionStatusChange `json:"history"`
+}
+
+// StreamTokenResponse is a token to open an event stream with, passed as
+// ?token= since EventSource can't send an Authorization header.
+type StreamTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Server-sent events of GET /whatsapp/events, named "status", "qr" and
+// "profile".
+
+type SessionStatusEvent struct {
+	Session string    `json:"session"`
+	Status  string    `json:"status"`
+	At      time.Time `json:"at"`
+}
+
+type SessionQREvent struct {
+	Session string `json:"session"`
+	QR      string `json:"qr"` // PNG as a data URL
+}
+
+type SessionProfileEvent struct {
+	Session  string `json:"session"`
+	ID       string `json:"id"`
+	PushName string `json:"push_name"`
+}
+
+func NewSessionStatusResponse(session models.WhatsAppSession, history []models.SessionStatusChange) SessionStatusResponse {
+	changes := make([]SessionStatusChange, len(history))
+	for i, change := range history {
+		changes[i] = SessionStatusChange{
+			From:      change.FromStatus,
+			To:        change.ToStatus,
+			Source:    change.Source,
+			ChangedAt: change.ChangedAt,
+		}
+	}
+
+	return SessionStatusResponse{
+		Session:  session.WahaSessionName,
+		Status:   session.Status,
+		StatusAt: session.StatusAt,
+		History:  changes,
+	}
+}
+
+// PairingQR is the pairing QR of GET /whatsapp/connect in the requested
+// format: Value for "raw", Mimetype and base64 Data for "base64".
+type PairingQR struct {
+	Format   string `json:"format"`
+	Value    string `json:"value,omitempty"`
+	Mimetype string `json:"mimetype,omitempty"`
+	Data     string `json:"data,omitempty"`
+}
@@ -0,0 +1,81 @@
+package views
+
+import (
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+	connModel "github.com/Mahaveer86619/lumi/pkg/models/connections"
+	"github.com/Mahaveer86619/lumi/pkg/utils"
+)
+
+type PostTextStatusRequest struct {
+	Text            string     `json:"text"`
+	BackgroundColor string     `json:"background_color"` // e.g. "#38b42f"
+	Font            int        `json:"font"`             // 0 to 10
+	ScheduledAt     *time.Time `json:"scheduled_at"`     // posted right away when empty
+}
+
+type PostMediaStatusRequest struct {
+	File        *connModel.FileWrapper `json:"file"`
+	Caption     string                 `json:"caption"`
+	ScheduledAt *time.Time             `json:"scheduled_at"`
+}
+
+type StatusViewer struct {
+	Viewer   string    `json:"viewer"`
+	ViewedAt time.Time `json:"viewed_at"`
+}
+
+type StatusPost struct {
+	ID              utils.MaskedId `json:"id"`
+	Kind            string         `json:"kind"`
+	Text            string         `json:"text,omitempty"`
+	BackgroundColor string         `json:"background_color,omitempty"`
+	Font            int            `json:"font,omitempty"`
+	MediaMimetype   string         `json:"media_mimetype,omitempty"`
+	State           string         `json:"state"`
+	ScheduledAt     time.Time      `json:"scheduled_at"`
+	Attempts        int            `json:"attempts"`
+	LastError       string         `json:"last_error,omitempty"`
+	MessageID       string         `json:"message_id,omitempty"`
+	PostedAt        *time.Time     `json:"posted_at,omitempty"`
+	RemovedAt       *time.Time     `json:"removed_at,omitempty"`
+	ViewCount       int            `json:"view_count"`
+	Viewers         []StatusViewer `json:"viewers"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+func NewStatusPostResponse(post models.StatusPost) StatusPost {
+	resp := StatusPost{
+		ID:              utils.Mask(post.ID),
+		Kind:            post.Kind,
+		Text:            post.Text,
+		BackgroundColor: post.BackgroundColor,
+		Font:            post.Font,
+		State:           post.State,
+		ScheduledAt:     post.ScheduledAt,
+		Attempts:        post.Attempts,
+		LastError:       post.LastError,
+		MessageID:       post.MessageID,
+		PostedAt:        post.PostedAt,
+		RemovedAt:       post.RemovedAt,
+		ViewCount:       len(post.Views),
+		Viewers:         []StatusViewer{},
+		CreatedAt:       post.CreatedAt,
+	}
+	if post.Media != nil {
+		resp.MediaMimetype = post.Media.Mimetype
+	}
+	for _, view := range post.Views {
+		resp.Viewers = append(resp.Viewers, StatusViewer{Viewer: view.Viewer, ViewedAt: view.ViewedAt})
+	}
+	return resp
+}
+
+func NewStatusPostListResponse(posts []models.StatusPost) []StatusPost {
+	resp := []StatusPost{}
+	for _, post := range posts {
+		resp = append(resp, NewStatusPostResponse(post))
+	}
+	return resp
+}
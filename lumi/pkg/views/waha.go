@@ -1,8 +1,62 @@
 package views
 
+import (
+	"time"
+
+	"github.com/Mahaveer86619/lumi/pkg/models"
+)
+
+// ErrorResponse is the WAHA side of a failed call. Message is Lumi's fixed
+// explanation of Code; WAHA's own text is never passed on.
 type ErrorResponse struct {
-	Message  string   `json:"error"`
-	Session  string   `json:"session,"`
-	Status   string   `json:"status"`
-	Expected []string `json:"expected"`
+	Code     string   `json:"code"`
+	Message  string   `json:"error,omitempty"`
+	Session  string   `json:"session,omitempty"`
+	Status   string   `json:"status,omitempty"`
+	Expected []string `json:"expected,omitempty"`
+}
+
+// WahaFailure is a Failure caused by WAHA.
+type WahaFailure struct {
+	StatusCode int           `json:"status_code"`
+	Message    string        `json:"message"`
+	Waha       ErrorResponse `json:"waha"`
+}
+
+type WebhookStatsResponse struct {
+	Since             time.Time  `json:"since"`
+	Events            int64      `json:"events"`
+	DuplicateEvents   int64      `json:"duplicate_events"`
+	DuplicateMessages int64      `json:"duplicate_messages"`
+	Queue             QueueStats `json:"queue"`
+}
+
+type QueueStats struct {
+	Workers   int   `json:"workers"`
+	Capacity  int   `json:"capacity"`
+	Depth     int64 `json:"depth"`
+	Enqueued  int64 `json:"enqueued"`
+	Processed int64 `json:"processed"`
+	Waited    int64 `json:"waited"`
+	AvgWaitMs int64 `json:"avg_wait_ms"`
+	Dropped   int64 `json:"dropped"`
+}
+
+func NewWebhookStatsResponse(stats models.DedupStats, queue models.QueueStats) WebhookStatsResponse {
+	return WebhookStatsResponse{
+		Since:             stats.Since,
+		Events:            stats.Events,
+		DuplicateEvents:   stats.DuplicateEvents,
+		DuplicateMessages: stats.DuplicateMessages,
+		Queue: QueueStats{
+			Workers:   queue.Workers,
+			Capacity:  queue.Capacity,
+			Depth:     queue.Depth,
+			Enqueued:  queue.Enqueued,
+			Processed: queue.Processed,
+			Waited:    queue.Waited,
+			AvgWaitMs: queue.AvgWait.Milliseconds(),
+			Dropped:   queue.Dropped,
+		},
+	}
 }
@@ -1,9 +1,13 @@
 package web
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Mahaveer86619/lumi/pkg/config"
@@ -13,52 +17,126 @@ import (
 	"github.com/Mahaveer86619/lumi/pkg/services"
 	"github.com/Mahaveer86619/lumi/pkg/services/bot"
 	"github.com/Mahaveer86619/lumi/pkg/services/connections"
+	"github.com/Mahaveer86619/lumi/pkg/services/events"
+	"github.com/Mahaveer86619/lumi/pkg/services/messenger"
+	"github.com/Mahaveer86619/lumi/pkg/services/storage"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
+const (
+	shutdownTimeout = 10 * time.Second
+
+	// drainTimeout bounds how long queued messages may still be answered
+	// after the server stopped taking new ones.
+	drainTimeout = 20 * time.Second
+)
+
 var (
-	authLimiter *mid.RateLimiter
-	apiLimiter  *mid.RateLimiter
+	authLimiter     *mid.RateLimiter
+	apiLimiter      *mid.RateLimiter
+	webhookVerifier *mid.WebhookVerifier
 )
 
 func initSystem() {
 	config.InitConfig()
-	config.InitConnectionsConfig()
 	db.InitDB()
 
 	authLimiter = mid.NewRateLimiter(10, 1*time.Minute) // 10 req in 1 min
 	apiLimiter = mid.NewRateLimiter(60, 1*time.Minute)  // 60 req in 1 min
+
+	webhookVerifier = mid.NewWebhookVerifier(config.GConfig.WahaWebhookSecret, mid.DefaultWebhookTolerance)
 }
 
 func StartServer() {
 	initSystem()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	e := echo.New()
 
-	e.Use(middleware.Logger())
+	e.Use(mid.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.Secure())
 	e.Use(middleware.CORS())
 
-	registerServices(e)
+	drain := registerServices(ctx, e)
+
+	go func() {
+		serverAddress := fmt.Sprintf(":%s", config.GConfig.Port)
+		if err := e.Start(serverAddress); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Error starting server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down server...")
 
-	serverAddress := fmt.Sprintf(":%s", config.GConfig.Port)
-	if err := e.Start(serverAddress); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Error starting server: %v", err)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error during server shutdown: %v", err)
+	}
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelDrain()
+
+	if err := drain(drainCtx); err != nil {
+		log.Printf("Error draining message queue: %v", err)
 	}
 }
 
-func registerServices(e *echo.Echo) {
+// registerServices wires services and routes. The returned func drains
+// background work once the server no longer accepts requests.
+func registerServices(ctx context.Context, e *echo.Echo) func(context.Context) error {
 	// --- Services Initialization ---
+	bus := events.NewBus()
 	avatarService := services.NewAvatarService()
 	authService := services.NewAuthService(avatarService)
-	wahaService := connections.NewWahaService()
-	userService := services.NewUserService()
-	healthService := services.NewHealthService(wahaService)
-	chatService := services.NewChatService(wahaService)
-	botService := bot.NewBotService(wahaService, chatService)
+	sessionService := services.NewSessionService()
+	userService := services.NewUserService(sessionService)
+	healthService := services.NewHealthService(connections.NewWahaService(config.GConfig.WahaSessionName))
+	chatService := services.NewChatService(sessionService)
+	groupService := services.NewGroupService(sessionService)
+	contactService := services.NewContactService(sessionService)
+	historyService := services.NewHistoryService(sessionService, chatService)
+
+	mediaStorage, err := storage.NewLocalStorage(config.GConfig.MediaStorageDir)
+	if err != nil {
+		log.Fatalf("Error initializing media storage: %v", err)
+	}
+	mediaService := services.NewMediaService(mediaStorage, sessionService)
+	outboxService := services.NewOutboxService(sessionService, chatService)
+	pollService := services.NewPollService(outboxService)
+	channelService := services.NewChannelService(sessionService, outboxService)
+	statusService := services.NewStatusService(sessionService)
+	campaignService := services.NewCampaignService(chatService, outboxService)
+	dedupService := services.NewDedupService()
+	botService := bot.NewBotService(sessionService, chatService, historyService, mediaService, outboxService, pollService)
+	dispatcher := services.NewEventDispatcher(bus, sessionService, dedupService)
+
+	outboxService.Start(ctx)
+	statusService.Start(ctx)
+	campaignService.Start(ctx)
+	historyService.Start(ctx)
+	dedupService.Start(ctx)
+	botService.Start()
+	go sessionService.SyncStatuses(ctx)
+
+	// Telegram, when a bot token is set
+	var telegram messenger.Messenger
+	if config.GConfig.TelegramBotToken != "" {
+		tg := messenger.NewTelegram(connections.NewTelegramService())
+		telegram = tg
+		go tg.Listen(ctx, func(ctx context.Context, msg messenger.Message) {
+			if err := botService.EnqueueMessage(ctx, tg, msg); err != nil {
+				log.Printf("Telegram: dropped message %s: %v", msg.ID, err)
+			}
+		})
+	}
 
 	// --- Route Groups & Middleware ---
 	authGroup := e.Group("/auth")
@@ -83,17 +161,46 @@ func registerServices(e *echo.Echo) {
 	protectedGroup.Use(mid.JWTMiddleware)
 
 	wahaGroup := protectedGroup.Group("/whatsapp")
+	// Event streams authenticate themselves, see mid.StreamToken.
+	streamsGroup := apiGroup.Group("/whatsapp")
+	outboxGroup := wahaGroup.Group("/outbox")
+	groupsGroup := wahaGroup.Group("/groups")
+	contactsGroup := wahaGroup.Group("/contacts")
+	pollsGroup := wahaGroup.Group("/polls")
+	channelsGroup := wahaGroup.Group("/channels")
+	statusGroup := wahaGroup.Group("/status")
+	campaignsGroup := wahaGroup.Group("/campaigns")
 	chatGroup := protectedGroup.Group("/chats")
+	mediaGroup := protectedGroup.Group("/media")
 
 	// Handlers
 	handlers.NewHealthHandler(apiGroup, healthService)
 	handlers.NewAvatarHandler(apiGroup, avatarService)
 	handlers.NewAuthHandler(authGroup, authService)
 	handlers.NewUserHandler(protectedGroup, userService)
-	handlers.NewChatHandler(chatGroup, chatService)
-
-	wahaHandler := handlers.NewWahaHandler(wahaGroup, wahaService, chatService, botService)
+	handlers.NewChatHandler(chatGroup, chatService, historyService, sessionService, telegram)
+	handlers.NewMediaHandler(mediaGroup, mediaService, sessionService)
+	handlers.NewOutboxHandler(outboxGroup, outboxService, sessionService)
+	handlers.NewGroupHandler(groupsGroup, groupService)
+	handlers.NewContactHandler(contactsGroup, contactService)
+	handlers.NewPollHandler(pollsGroup, pollService, sessionService)
+	handlers.NewChannelHandler(channelsGroup, channelService, sessionService, botService)
+	handlers.NewStatusHandler(statusGroup, bus, statusService, sessionService)
+	handlers.NewCampaignHandler(campaignsGroup, campaignService, sessionService)
+	handlers.NewSessionHandler(ctx, wahaGroup, streamsGroup, sessionService)
+
+	wahaHandler := handlers.NewWahaHandler(ctx, wahaGroup, streamsGroup, bus, dispatcher, sessionService, chatService, outboxService, pollService, botService, dedupService)
+
+	// WAHA events
+	switch config.GConfig.WahaEventMode {
+	case config.EventModeWebhook:
+		apiGroup.POST("/webhook", wahaHandler.HandleWebhook, webhookVerifier.Verify)
+	case config.EventModeWebsocket:
+		stream := connections.NewEventStream(config.GConfig.WahaServiceURL, config.GConfig.WahaAPIKey)
+		go stream.Run(ctx, dispatcher.Dispatch)
+	default:
+		log.Fatalf("Unknown WAHA_EVENT_MODE %q, expected %q or %q", config.GConfig.WahaEventMode, config.EventModeWebhook, config.EventModeWebsocket)
+	}
 
-	// Webhook
-	apiGroup.POST("/webhook", wahaHandler.HandleWebhook)
+	return botService.Shutdown
 }